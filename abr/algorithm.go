@@ -0,0 +1,120 @@
+package abr
+
+import (
+	"time"
+
+	"github.com/go-webdl/hls"
+)
+
+// Download is a completed segment download.
+type Download struct {
+	Segment  int           // index of the segment in its media playlist
+	Variant  int           // index of the variant in State.Variants
+	Bytes    int64         // size of the segment
+	Start    time.Duration // when the request was sent
+	Duration time.Duration // time until the last byte was received, including latency
+	Latency  time.Duration // time until the first byte was received
+}
+
+// Sample converts the download to an estimator sample.
+func (d Download) Sample() Sample {
+	return Sample{Bytes: d.Bytes, Duration: d.Duration - d.Latency, Latency: d.Latency}
+}
+
+// Throughput returns the measured throughput of the download in bits per
+// second.
+func (d Download) Throughput() float64 {
+	if d.Duration <= 0 {
+		return 0
+	}
+	return float64(d.Bytes*8) / d.Duration.Seconds()
+}
+
+// State is what an Algorithm sees before each segment request.
+type State struct {
+	Variants  []*hls.VariantStream // candidate variants, by ascending BANDWIDTH
+	Segment   int                  // index of the segment about to be requested
+	Time      time.Duration        // current time since the start of the simulation
+	Buffer    time.Duration        // media buffered ahead of the playhead
+	MaxBuffer time.Duration        // the buffer capacity
+	Current   int                  // index of the variant of the previous segment, -1 before the first one
+	Downloads []Download           // completed downloads, oldest first
+}
+
+// Algorithm decides which variant to request next.
+type Algorithm interface {
+	// Choose returns the index into State.Variants of the variant to request.
+	Choose(state *State) int
+}
+
+// highestFitting returns the index of the highest variant whose BANDWIDTH
+// does not exceed the given bit rate, or 0.
+func highestFitting(variants []*hls.VariantStream, bitrate float64) (index int) {
+	for i, v := range variants {
+		if float64(v.Bandwidth) <= bitrate {
+			index = i
+		}
+	}
+	return
+}
+
+// ThroughputRule picks the highest variant fitting the throughput estimate,
+// scaled by a safety factor. The lowest variant is used until the estimator
+// has a usable sample.
+type ThroughputRule struct {
+	Estimator Estimator // [DEFAULT=SlidingWindow of 3 samples] fed with every completed download
+	Safety    float64   // [DEFAULT=0.9] fraction of the estimate that may be used
+
+	fed int // number of State.Downloads already added to Estimator
+}
+
+func (rule *ThroughputRule) Choose(state *State) int {
+	safety := rule.Safety
+	if safety <= 0 {
+		safety = 0.9
+	}
+	if rule.Estimator == nil {
+		rule.Estimator = &SlidingWindow{}
+	}
+	if rule.fed > len(state.Downloads) {
+		// a new simulation started
+		rule.fed = 0
+	}
+	for _, d := range state.Downloads[rule.fed:] {
+		rule.Estimator.Add(d.Sample())
+	}
+	rule.fed = len(state.Downloads)
+	estimate := rule.Estimator.Estimate()
+	if estimate <= 0 {
+		return 0
+	}
+	return highestFitting(state.Variants, estimate*safety)
+}
+
+// BufferRule is a buffer-based rule in the manner of BBA-0: below Reservoir
+// the lowest variant is used, above Reservoir+Cushion the highest, and in
+// between the bit rate grows linearly with the buffer level.
+type BufferRule struct {
+	Reservoir time.Duration // [DEFAULT=5s]
+	Cushion   time.Duration // [DEFAULT=10s]
+}
+
+func (rule *BufferRule) Choose(state *State) int {
+	reservoir, cushion := rule.Reservoir, rule.Cushion
+	if reservoir <= 0 {
+		reservoir = 5 * time.Second
+	}
+	if cushion <= 0 {
+		cushion = 10 * time.Second
+	}
+	last := len(state.Variants) - 1
+	switch {
+	case state.Buffer <= reservoir:
+		return 0
+	case state.Buffer >= reservoir+cushion:
+		return last
+	}
+	low, high := float64(state.Variants[0].Bandwidth), float64(state.Variants[last].Bandwidth)
+	fraction := (state.Buffer - reservoir).Seconds() / cushion.Seconds()
+	return highestFitting(state.Variants, low+(high-low)*fraction)
+}
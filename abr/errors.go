@@ -0,0 +1,8 @@
+package abr
+
+import "errors"
+
+var (
+	ErrNoVariants     = errors.New("no variant stream with a media playlist to simulate")
+	ErrTraceExhausted = errors.New("bandwidth trace has no throughput left")
+)
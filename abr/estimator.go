@@ -0,0 +1,203 @@
+package abr
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-webdl/hls"
+)
+
+// Sample is a measured segment download.
+type Sample struct {
+	Bytes    int64
+	Duration time.Duration // time from the first to the last byte
+	Latency  time.Duration // time from the request to the first byte
+}
+
+// Throughput returns the throughput of the sample in bits per second, 0 if
+// Duration is not positive. Latency is excluded so that small segments do not
+// underestimate the link.
+func (s Sample) Throughput() float64 {
+	if s.Duration <= 0 {
+		return 0
+	}
+	return float64(s.Bytes*8) / s.Duration.Seconds()
+}
+
+// Estimator estimates the available throughput from download samples. All
+// implementations are safe for concurrent use.
+type Estimator interface {
+	Add(sample Sample)
+	// Estimate returns the estimated throughput in bits per second, 0 until
+	// a usable sample was added.
+	Estimate() float64
+}
+
+// SlidingWindow estimates the throughput as the harmonic mean of the last
+// samples, which weighs slow downloads more than the arithmetic mean.
+type SlidingWindow struct {
+	Size int // [DEFAULT=3] number of samples kept
+
+	mu          sync.Mutex
+	throughputs []float64
+}
+
+func (w *SlidingWindow) Add(sample Sample) {
+	throughput := sample.Throughput()
+	if throughput <= 0 {
+		return
+	}
+	size := w.Size
+	if size <= 0 {
+		size = 3
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.throughputs = append(w.throughputs, throughput)
+	if len(w.throughputs) > size {
+		w.throughputs = w.throughputs[len(w.throughputs)-size:]
+	}
+}
+
+func (w *SlidingWindow) Estimate() float64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.throughputs) == 0 {
+		return 0
+	}
+	var inverse float64
+	for _, throughput := range w.throughputs {
+		inverse += 1 / throughput
+	}
+	return float64(len(w.throughputs)) / inverse
+}
+
+// EWMA keeps a fast and a slow exponentially weighted moving average, each
+// sample weighted by its duration, and estimates the lower of both: drops
+// are followed quickly while spikes are not trusted.
+type EWMA struct {
+	FastHalfLife time.Duration // [DEFAULT=2s]
+	SlowHalfLife time.Duration // [DEFAULT=5s]
+
+	mu         sync.Mutex
+	fast, slow ewma
+}
+
+type ewma struct {
+	estimate    float64
+	totalWeight float64
+}
+
+func (e *ewma) add(halfLife time.Duration, weight, value float64) {
+	alpha := math.Pow(0.5, weight/halfLife.Seconds())
+	e.estimate = value*(1-alpha) + alpha*e.estimate
+	e.totalWeight += weight
+}
+
+// get corrects the bias toward the initial zero estimate.
+func (e *ewma) get(halfLife time.Duration) float64 {
+	zeroFactor := 1 - math.Pow(0.5, e.totalWeight/halfLife.Seconds())
+	return e.estimate / zeroFactor
+}
+
+func (e *EWMA) halfLives() (fast, slow time.Duration) {
+	if fast = e.FastHalfLife; fast <= 0 {
+		fast = 2 * time.Second
+	}
+	if slow = e.SlowHalfLife; slow <= 0 {
+		slow = 5 * time.Second
+	}
+	return
+}
+
+func (e *EWMA) Add(sample Sample) {
+	throughput := sample.Throughput()
+	if throughput <= 0 {
+		return
+	}
+	fast, slow := e.halfLives()
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.fast.add(fast, sample.Duration.Seconds(), throughput)
+	e.slow.add(slow, sample.Duration.Seconds(), throughput)
+}
+
+func (e *EWMA) Estimate() float64 {
+	fast, slow := e.halfLives()
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.fast.totalWeight == 0 {
+		return 0
+	}
+	return math.Min(e.fast.get(fast), e.slow.get(slow))
+}
+
+// Percentile estimates the throughput as a percentile of the last samples,
+// such as the 20th percentile for a conservative estimate.
+type Percentile struct {
+	Size       int     // [DEFAULT=20] number of samples kept
+	Percentile float64 // [DEFAULT=0.5] between 0 and 1
+
+	mu          sync.Mutex
+	throughputs []float64
+}
+
+func (p *Percentile) Add(sample Sample) {
+	throughput := sample.Throughput()
+	if throughput <= 0 {
+		return
+	}
+	size := p.Size
+	if size <= 0 {
+		size = 20
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.throughputs = append(p.throughputs, throughput)
+	if len(p.throughputs) > size {
+		p.throughputs = p.throughputs[len(p.throughputs)-size:]
+	}
+}
+
+func (p *Percentile) Estimate() float64 {
+	percentile := p.Percentile
+	if percentile <= 0 || percentile > 1 {
+		percentile = 0.5
+	}
+	p.mu.Lock()
+	sorted := append([]float64(nil), p.throughputs...)
+	p.mu.Unlock()
+	if len(sorted) == 0 {
+		return 0
+	}
+	sort.Float64s(sorted)
+	// nearest rank
+	rank := int(math.Ceil(percentile*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	return sorted[rank]
+}
+
+// FitVariant returns the Variant Stream with the highest BANDWIDTH not
+// exceeding the estimate scaled by the safety factor, such as 0.8, or the one
+// with the lowest BANDWIDTH if none fits. It returns nil if the playlist has
+// no Variant Streams.
+func FitVariant(master *hls.MasterPlaylist, estimate, safety float64) (fit *hls.VariantStream) {
+	var lowest *hls.VariantStream
+	budget := estimate * safety
+	for _, s := range master.VariantStreams {
+		if lowest == nil || s.Bandwidth < lowest.Bandwidth {
+			lowest = s
+		}
+		if float64(s.Bandwidth) <= budget && (fit == nil || s.Bandwidth > fit.Bandwidth) {
+			fit = s
+		}
+	}
+	if fit == nil {
+		fit = lowest
+	}
+	return
+}
@@ -0,0 +1,95 @@
+package abr
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// mbps builds a one second sample of the given throughput in megabits per
+// second.
+func mbps(value float64) Sample {
+	return Sample{Bytes: int64(value * 1000000 / 8), Duration: time.Second, Latency: 100 * time.Millisecond}
+}
+
+func TestSlidingWindow(t *testing.T) {
+	w := &SlidingWindow{Size: 2}
+	assert.Equal(t, 0.0, w.Estimate())
+	w.Add(Sample{Bytes: 1000})
+	assert.Equal(t, 0.0, w.Estimate())
+	w.Add(mbps(100))
+	w.Add(mbps(1))
+	w.Add(mbps(3))
+	assert.InDelta(t, 1500000, w.Estimate(), 1)
+}
+
+func TestEWMA(t *testing.T) {
+	e := &EWMA{}
+	assert.Equal(t, 0.0, e.Estimate())
+	e.Add(mbps(4))
+	assert.InDelta(t, 4000000, e.Estimate(), 1)
+	for i := 0; i < 3; i++ {
+		e.Add(mbps(1))
+	}
+	drop := e.Estimate()
+	assert.Less(t, drop, 2000000.0)
+	for i := 0; i < 3; i++ {
+		e.Add(mbps(10))
+	}
+	spike := e.Estimate()
+	// the slow average holds the estimate back after a spike
+	assert.Less(t, spike, 8000000.0)
+	assert.Greater(t, spike, drop)
+}
+
+func TestPercentile(t *testing.T) {
+	p := &Percentile{Size: 5, Percentile: 0.4}
+	for _, v := range []float64{9, 1, 5, 3, 7, 2} {
+		p.Add(mbps(v))
+	}
+	assert.InDelta(t, 2000000, p.Estimate(), 1)
+	p.Percentile = 1
+	assert.InDelta(t, 7000000, p.Estimate(), 1)
+}
+
+func TestEstimatorConcurrency(t *testing.T) {
+	for _, e := range []Estimator{&SlidingWindow{}, &EWMA{}, &Percentile{}} {
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 100; j++ {
+					e.Add(mbps(2))
+					e.Estimate()
+				}
+			}()
+		}
+		wg.Wait()
+		assert.InDelta(t, 2000000, e.Estimate(), 1)
+	}
+}
+
+func TestFitVariant(t *testing.T) {
+	master := parseMaster(t, testMaster)
+	assert.EqualValues(t, 1500000, FitVariant(master, 2000000, 0.8).Bandwidth)
+	assert.EqualValues(t, 4000000, FitVariant(master, 5000000, 0.8).Bandwidth)
+	assert.EqualValues(t, 500000, FitVariant(master, 100000, 0.8).Bandwidth)
+
+	rule := &ThroughputRule{Estimator: &Percentile{}}
+	master = parseMaster(t, testMaster)
+	sim := &Simulator{
+		Master:    master,
+		Playlists: testPlaylists(t, master, 6),
+		Trace:     Trace{{0, 8000000}},
+		Algorithm: rule,
+		Latency:   50 * time.Millisecond,
+	}
+	report, err := sim.Run()
+	if assert.NoError(t, err) {
+		assert.EqualValues(t, 4000000, report.Segments[5].VariantStream.Bandwidth)
+		assert.InDelta(t, 8000000, rule.Estimator.Estimate(), 100)
+	}
+}
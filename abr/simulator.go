@@ -0,0 +1,168 @@
+// Package abr simulates and supports adaptive bitrate selection over HLS
+// variant streams.
+package abr
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/go-webdl/hls"
+)
+
+// Simulator plays a presentation over a bandwidth trace in-process, asking
+// an Algorithm for the variant of every segment. Variants are assumed to be
+// segmented alike, so segment i of one variant is interchangeable with
+// segment i of another.
+type Simulator struct {
+	Master        *hls.MasterPlaylist                       // [REQUIRED]
+	Playlists     map[*hls.VariantStream]*hls.MediaPlaylist // [REQUIRED] the loaded media playlist of each variant, variants without one are skipped
+	Trace         Trace                                     // [REQUIRED]
+	Algorithm     Algorithm                                 // [REQUIRED]
+	MaxBuffer     time.Duration                             // [DEFAULT=30s] downloads pause while the buffer is full
+	StartupBuffer time.Duration                             // [DEFAULT=one segment] buffer needed before playback starts
+	Latency       time.Duration                             // [DEFAULT=0] added to every download
+}
+
+// SegmentResult records the download of one segment.
+type SegmentResult struct {
+	Download
+	VariantStream *hls.VariantStream
+	MediaDuration time.Duration
+}
+
+// Switch records a change of variant.
+type Switch struct {
+	Time    time.Duration
+	Segment int
+	From    *hls.VariantStream
+	To      *hls.VariantStream
+}
+
+// Stall records playback stopping on an empty buffer after startup.
+type Stall struct {
+	Start    time.Duration
+	Duration time.Duration
+}
+
+// BufferSample is the buffer level at a point in time, sampled after every
+// download.
+type BufferSample struct {
+	Time  time.Duration
+	Level time.Duration
+}
+
+// Report is the outcome of a simulation.
+type Report struct {
+	Segments       []SegmentResult
+	Switches       []Switch
+	Stalls         []Stall
+	BufferLevels   []BufferSample
+	StartupDelay   time.Duration // time until playback started
+	StallDuration  time.Duration // total duration of the stalls
+	AverageBitrate float64       // delivered bits per second of media
+}
+
+// SegmentSize estimates the size of a segment of a variant in bytes, from
+// its byte range, else its EXT-X-BITRATE, else the BANDWIDTH of the variant.
+func SegmentSize(segment *hls.MediaSegment, variant *hls.VariantStream) int64 {
+	if segment.ByteRange != nil {
+		return int64(segment.ByteRange.Length)
+	}
+	bitrate := float64(variant.Bandwidth)
+	if segment.Bitrate != nil {
+		bitrate = float64(*segment.Bitrate) * 1000
+	}
+	return int64(bitrate * segment.Duration.Seconds() / 8)
+}
+
+func (sim *Simulator) Run() (report *Report, err error) {
+	var variants []*hls.VariantStream
+	segmentCount := -1
+	for _, v := range sim.Master.VariantStreams {
+		playlist := sim.Playlists[v]
+		if playlist == nil {
+			continue
+		}
+		variants = append(variants, v)
+		if segmentCount < 0 || len(playlist.MediaSegments) < segmentCount {
+			segmentCount = len(playlist.MediaSegments)
+		}
+	}
+	if len(variants) == 0 {
+		err = ErrNoVariants
+		return
+	}
+	sort.SliceStable(variants, func(i, j int) bool {
+		return variants[i].Bandwidth < variants[j].Bandwidth
+	})
+	maxBuffer := sim.MaxBuffer
+	if maxBuffer <= 0 {
+		maxBuffer = 30 * time.Second
+	}
+
+	report = &Report{}
+	state := &State{Variants: variants, MaxBuffer: maxBuffer, Current: -1}
+	playing := false
+	var totalBits float64
+	var totalMedia time.Duration
+	for i := 0; i < segmentCount; i++ {
+		state.Segment = i
+		// the next segment duration is only known once a variant is chosen,
+		// so wait for room using the one of the current or lowest variant
+		peek := variants[0]
+		if state.Current >= 0 {
+			peek = variants[state.Current]
+		}
+		if room := maxBuffer - sim.Playlists[peek].MediaSegments[i].Duration; playing && state.Buffer > room {
+			wait := state.Buffer - room
+			state.Time += wait
+			state.Buffer -= wait
+		}
+
+		chosen := sim.Algorithm.Choose(state)
+		if chosen < 0 || chosen >= len(variants) {
+			err = fmt.Errorf("algorithm chose variant %d out of %d", chosen, len(variants))
+			return
+		}
+		variant := variants[chosen]
+		segment := sim.Playlists[variant].MediaSegments[i]
+		size := SegmentSize(segment, variant)
+		var transfer time.Duration
+		if transfer, err = sim.Trace.TransferTime(state.Time+sim.Latency, float64(size*8)); err != nil {
+			return
+		}
+		download := Download{Segment: i, Variant: chosen, Bytes: size, Start: state.Time, Duration: sim.Latency + transfer, Latency: sim.Latency}
+
+		if playing {
+			if state.Buffer >= download.Duration {
+				state.Buffer -= download.Duration
+			} else {
+				stall := Stall{Start: state.Time + state.Buffer, Duration: download.Duration - state.Buffer}
+				report.Stalls = append(report.Stalls, stall)
+				report.StallDuration += stall.Duration
+				state.Buffer = 0
+			}
+		}
+		state.Time += download.Duration
+		state.Buffer += segment.Duration
+		if !playing && state.Buffer >= sim.StartupBuffer {
+			playing = true
+			report.StartupDelay = state.Time
+		}
+
+		if state.Current >= 0 && state.Current != chosen {
+			report.Switches = append(report.Switches, Switch{state.Time, i, variants[state.Current], variant})
+		}
+		state.Current = chosen
+		state.Downloads = append(state.Downloads, download)
+		report.Segments = append(report.Segments, SegmentResult{download, variant, segment.Duration})
+		report.BufferLevels = append(report.BufferLevels, BufferSample{state.Time, state.Buffer})
+		totalBits += float64(size * 8)
+		totalMedia += segment.Duration
+	}
+	if totalMedia > 0 {
+		report.AverageBitrate = totalBits / totalMedia.Seconds()
+	}
+	return
+}
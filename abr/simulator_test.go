@@ -0,0 +1,136 @@
+package abr
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-webdl/hls"
+	"github.com/stretchr/testify/assert"
+)
+
+var testBaseURL, _ = url.Parse("https://example.com/live/index.m3u8")
+
+const testMaster = "#EXTM3U\n" +
+	"#EXT-X-STREAM-INF:BANDWIDTH=4000000\nhigh.m3u8\n" +
+	"#EXT-X-STREAM-INF:BANDWIDTH=500000\nlow.m3u8\n" +
+	"#EXT-X-STREAM-INF:BANDWIDTH=1500000\nmid.m3u8\n"
+
+func parseMaster(t *testing.T, input string) (playlist *hls.MasterPlaylist) {
+	err := hls.Parse(strings.NewReader(input), testBaseURL, &hls.ParserHandler{
+		HandleMasterPlaylist: func(p *hls.MasterPlaylist) { playlist = p },
+	})
+	assert.NoError(t, err)
+	return
+}
+
+func parseMedia(t *testing.T, input string) (playlist *hls.MediaPlaylist) {
+	err := hls.Parse(strings.NewReader(input), testBaseURL, &hls.ParserHandler{
+		HandleMediaPlaylist: func(p *hls.MediaPlaylist) { playlist = p },
+	})
+	assert.NoError(t, err)
+	return
+}
+
+// testPlaylists gives every variant count segments of 4 seconds.
+func testPlaylists(t *testing.T, master *hls.MasterPlaylist, count int) map[*hls.VariantStream]*hls.MediaPlaylist {
+	playlists := map[*hls.VariantStream]*hls.MediaPlaylist{}
+	for _, v := range master.VariantStreams {
+		var b strings.Builder
+		b.WriteString("#EXTM3U\n#EXT-X-TARGETDURATION:4\n")
+		for i := 0; i < count; i++ {
+			fmt.Fprintf(&b, "#EXTINF:4.0,\n%d.ts\n", i)
+		}
+		b.WriteString("#EXT-X-ENDLIST\n")
+		playlists[v] = parseMedia(t, b.String())
+	}
+	return playlists
+}
+
+func TestTraceTransferTime(t *testing.T) {
+	trace := Trace{{0, 1000000}, {2 * time.Second, 0}, {3 * time.Second, 2000000}}
+	d, err := trace.TransferTime(0, 1000000)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Second, d)
+	d, err = trace.TransferTime(time.Second, 3000000)
+	assert.NoError(t, err)
+	assert.Equal(t, 3*time.Second, d)
+
+	_, err = Trace{{0, 1000000}, {time.Second, 0}}.TransferTime(0, 2000000)
+	assert.ErrorIs(t, err, ErrTraceExhausted)
+}
+
+func TestSegmentSize(t *testing.T) {
+	playlist := parseMedia(t, "#EXTM3U\n#EXT-X-TARGETDURATION:4\n"+
+		"#EXTINF:4.0,\n0.ts\n"+
+		"#EXT-X-BITRATE:800\n#EXTINF:4.0,\n1.ts\n"+
+		"#EXT-X-BYTERANGE:1000@0\n#EXTINF:4.0,\n2.ts\n")
+	variant := &hls.VariantStream{BaseStream: hls.BaseStream{Bandwidth: 2000000}}
+	assert.Nil(t, playlist.MediaSegments[0].Bitrate)
+	assert.EqualValues(t, 1000000, SegmentSize(playlist.MediaSegments[0], variant))
+	assert.EqualValues(t, 800, *playlist.MediaSegments[1].Bitrate)
+	assert.EqualValues(t, 400000, SegmentSize(playlist.MediaSegments[1], variant))
+	assert.EqualValues(t, 1000, SegmentSize(playlist.MediaSegments[2], variant))
+}
+
+func TestSimulatorThroughputRule(t *testing.T) {
+	master := parseMaster(t, testMaster)
+	sim := &Simulator{
+		Master:    master,
+		Playlists: testPlaylists(t, master, 20),
+		Trace:     Trace{{0, 8000000}, {40 * time.Second, 1000000}},
+		Algorithm: &ThroughputRule{},
+	}
+	report, err := sim.Run()
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Len(t, report.Segments, 20)
+	assert.Len(t, report.BufferLevels, 20)
+	assert.EqualValues(t, 500000, report.Segments[0].VariantStream.Bandwidth)
+	assert.EqualValues(t, 4000000, report.Segments[1].VariantStream.Bandwidth)
+	assert.EqualValues(t, 500000, report.Segments[19].VariantStream.Bandwidth)
+	if assert.GreaterOrEqual(t, len(report.Switches), 2) {
+		assert.EqualValues(t, 500000, report.Switches[0].From.Bandwidth)
+		assert.EqualValues(t, 4000000, report.Switches[0].To.Bandwidth)
+	}
+	assert.Equal(t, 250*time.Millisecond, report.StartupDelay)
+	assert.Greater(t, report.AverageBitrate, 500000.0)
+	assert.Less(t, report.AverageBitrate, 4000000.0)
+	for _, sample := range report.BufferLevels {
+		assert.LessOrEqual(t, sample.Level, 30*time.Second)
+	}
+}
+
+func TestSimulatorStalls(t *testing.T) {
+	master := parseMaster(t, testMaster)
+	sim := &Simulator{
+		Master:    master,
+		Playlists: testPlaylists(t, master, 5),
+		Trace:     Trace{{0, 250000}},
+		Algorithm: &BufferRule{},
+	}
+	report, err := sim.Run()
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Empty(t, report.Switches)
+	assert.Equal(t, 8*time.Second, report.StartupDelay)
+	if assert.Len(t, report.Stalls, 4) {
+		assert.Equal(t, 12*time.Second, report.Stalls[0].Start)
+		assert.Equal(t, 4*time.Second, report.Stalls[0].Duration)
+	}
+	assert.Equal(t, 16*time.Second, report.StallDuration)
+	assert.Equal(t, 500000.0, report.AverageBitrate)
+}
+
+func TestBufferRule(t *testing.T) {
+	master := parseMaster(t, testMaster)
+	variants := []*hls.VariantStream{master.VariantStreams[1], master.VariantStreams[2], master.VariantStreams[0]}
+	rule := &BufferRule{Reservoir: 5 * time.Second, Cushion: 10 * time.Second}
+	assert.Equal(t, 0, rule.Choose(&State{Variants: variants, Buffer: 4 * time.Second}))
+	assert.Equal(t, 1, rule.Choose(&State{Variants: variants, Buffer: 10 * time.Second}))
+	assert.Equal(t, 2, rule.Choose(&State{Variants: variants, Buffer: 16 * time.Second}))
+}
@@ -0,0 +1,66 @@
+package abr
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// TracePoint sets the available throughput from Time on.
+type TracePoint struct {
+	Time       time.Duration // offset from the start of the simulation
+	Throughput float64       // bits per second
+}
+
+// Trace is a bandwidth trace: a step function of the throughput over time,
+// sorted by Time. The throughput before the first point is the one of the
+// first point, and the last point holds forever.
+type Trace []TracePoint
+
+// Throughput returns the throughput at the given time.
+func (trace Trace) Throughput(t time.Duration) float64 {
+	if len(trace) == 0 {
+		return 0
+	}
+	throughput := trace[0].Throughput
+	for _, p := range trace {
+		if p.Time > t {
+			break
+		}
+		throughput = p.Throughput
+	}
+	return throughput
+}
+
+// TransferTime returns how long transferring the given number of bits takes
+// when starting at the given time.
+func (trace Trace) TransferTime(start time.Duration, bits float64) (d time.Duration, err error) {
+	t := start
+	for bits > 0 {
+		throughput := trace.Throughput(t)
+		next := time.Duration(math.MaxInt64)
+		for _, p := range trace {
+			if p.Time > t {
+				next = p.Time
+				break
+			}
+		}
+		if throughput <= 0 {
+			if next == time.Duration(math.MaxInt64) {
+				err = fmt.Errorf("no throughput after %s: %w", t, ErrTraceExhausted)
+				return
+			}
+			t = next
+			continue
+		}
+		needed := time.Duration(bits / throughput * float64(time.Second))
+		if next == time.Duration(math.MaxInt64) || t+needed <= next {
+			t += needed
+			break
+		}
+		bits -= throughput * (next - t).Seconds()
+		t = next
+	}
+	d = t - start
+	return
+}
@@ -10,15 +10,33 @@ import (
 type Attribute struct {
 	Name string
 	Value
+	Raw string // the original NAME=VALUE source text, empty if the attribute was not parsed
+
+	// the following are recorded by ParseAttributeList to reproduce the source
+	index    int    // position in the parsed list
+	sep      string // separator text between the previous attribute and this one
+	rawName  string
+	rawValue Value
+}
+
+// Modified reports whether the name or value differs from the parsed source,
+// always true for attributes that were not parsed.
+func (attr *Attribute) Modified() bool {
+	return attr.Raw == "" || attr.Name != attr.rawName || !attr.Value.Equal(&attr.rawValue)
 }
 
+// Format returns the original source text if the attribute is unmodified.
 func (attr *Attribute) Format() string {
+	if !attr.Modified() {
+		return attr.Raw
+	}
 	return fmt.Sprintf("%s=%s", attr.Name, attr.Value.Format())
 }
 
 type AttributeList struct {
 	attrs   []*Attribute
 	mapping map[string][]*Attribute
+	trailer string // text after the last parsed attribute
 }
 
 func (attrs AttributeList) List() []*Attribute {
@@ -51,6 +69,17 @@ func (attrs AttributeList) GetLast(name string) *Attribute {
 	return nil
 }
 
+// Duplicates returns the names that appear more than once, in the order of
+// their first appearance.
+func (attrs AttributeList) Duplicates() (names []string) {
+	for _, attr := range attrs.attrs {
+		if list := attrs.mapping[attr.Name]; len(list) > 1 && list[0] == attr {
+			names = append(names, attr.Name)
+		}
+	}
+	return
+}
+
 func (attrs *AttributeList) Remove(name string) {
 	newAttrs := []*Attribute{}
 	for _, attr := range attrs.attrs {
@@ -75,18 +104,35 @@ func (attrs *AttributeList) Set(name string, value *Value) {
 		}
 	}
 	if found == nil {
-		newAttrs = append(newAttrs, &Attribute{name, *value})
+		found = &Attribute{Name: name, Value: *value}
+		newAttrs = append(newAttrs, found)
 	}
 	attrs.attrs = newAttrs
+	if attrs.mapping == nil {
+		attrs.mapping = make(map[string][]*Attribute)
+	}
 	attrs.mapping[name] = []*Attribute{found}
 }
 
+// Format reuses the original spelling, including the whitespace around
+// commas, of every attribute that has not been modified since parsing.
 func (attrs AttributeList) Format() string {
-	var attrStrings []string
-	for _, attr := range attrs.attrs {
-		attrStrings = append(attrStrings, attr.Format())
+	var b strings.Builder
+	for i, attr := range attrs.attrs {
+		sep := attr.sep
+		if i == 0 {
+			if attr.index > 0 {
+				// the attribute has moved to the front of the list
+				sep = ""
+			}
+		} else if !strings.Contains(sep, ",") {
+			sep = "," + sep
+		}
+		b.WriteString(sep)
+		b.WriteString(attr.Format())
 	}
-	return strings.Join(attrStrings, ",")
+	b.WriteString(attrs.trailer)
+	return b.String()
 }
 
 func isNumericChar(c byte) bool {
@@ -118,27 +164,47 @@ const (
 	attrStateValueEnd
 )
 
+// ParseAttributeList parses an attribute list without any limit, see
+// ParseAttributeListWithLimits.
 func ParseAttributeList(listStr string) (attrs *AttributeList, err error) {
+	return ParseAttributeListWithLimits(listStr, &NoParseLimits)
+}
+
+func ParseAttributeListWithLimits(listStr string, limits *ParseLimits) (attrs *AttributeList, err error) {
 	var (
-		pos    int
-		start  int
-		signed bool
+		pos       int
+		start     int
+		nameStart int
+		prevEnd   int
+		signed    bool
 	)
 	attr := &Attribute{}
 	state := attrStateStart
 	attrs = &AttributeList{}
 
-	appendAttr := func(t Type) {
+	appendAttr := func(t Type, end int) (err error) {
+		if err = limits.checkAttributes(len(attrs.attrs) + 1); err != nil {
+			return
+		}
 		attr.Type = t
+		attr.Raw = listStr[nameStart:end]
+		attr.index = len(attrs.attrs)
+		attr.sep = listStr[prevEnd:nameStart]
+		attr.rawName = attr.Name
+		attr.rawValue = attr.Value.clone()
+		prevEnd = end
 		attrs.Append(attr)
 		attr = &Attribute{}
 		signed = false
+		return
 	}
 
 	finishEnum := func(c byte) (err error) {
 		value := listStr[start:pos]
 		attr.EnumValue = &value
-		appendAttr(EnumType)
+		if err = appendAttr(EnumType, pos); err != nil {
+			return
+		}
 		if c == ',' {
 			state = attrStateStart
 		} else {
@@ -154,7 +220,9 @@ func ParseAttributeList(listStr string) (attrs *AttributeList, err error) {
 		} else {
 			attr.IntegerValue = &value
 		}
-		appendAttr(IntegerType)
+		if err = appendAttr(IntegerType, pos); err != nil {
+			return
+		}
 		if c == ',' {
 			state = attrStateStart
 		} else {
@@ -170,7 +238,9 @@ func ParseAttributeList(listStr string) (attrs *AttributeList, err error) {
 		} else {
 			attr.FloatValue = &value
 		}
-		appendAttr(FloatType)
+		if err = appendAttr(FloatType, pos); err != nil {
+			return
+		}
 		if c == ',' {
 			state = attrStateStart
 		} else {
@@ -190,7 +260,9 @@ func ParseAttributeList(listStr string) (attrs *AttributeList, err error) {
 		if err != nil {
 			return
 		}
-		appendAttr(BytesType)
+		if err = appendAttr(BytesType, pos); err != nil {
+			return
+		}
 		if c == ',' {
 			state = attrStateStart
 		} else {
@@ -210,7 +282,9 @@ func ParseAttributeList(listStr string) (attrs *AttributeList, err error) {
 		} else {
 			attr.ResolutionValue.Height = int(height)
 		}
-		appendAttr(ResolutionType)
+		if err = appendAttr(ResolutionType, pos); err != nil {
+			return
+		}
 		if c == ',' {
 			state = attrStateStart
 		} else {
@@ -222,10 +296,16 @@ func ParseAttributeList(listStr string) (attrs *AttributeList, err error) {
 	var c byte
 	for pos = 0; pos < len(listStr); pos++ {
 		c = listStr[pos]
+		if state >= attrStateString && state <= attrStateResolution {
+			if err = limits.checkAttributeValueLength(pos - start); err != nil {
+				return
+			}
+		}
 		switch state {
 		case attrStateStart:
 			if isSpaceChar(c) || c == ',' {
 			} else if isAttributeNameChar(c) {
+				nameStart = pos
 				start = pos
 				state = attrStateName
 			} else {
@@ -267,8 +347,9 @@ func ParseAttributeList(listStr string) (attrs *AttributeList, err error) {
 			if c == '"' {
 				value := listStr[start:pos]
 				attr.StringValue = &value
-				appendAttr(StringType)
-				state = attrStateValueEnd
+				if err = appendAttr(StringType, pos+1); err == nil {
+					state = attrStateValueEnd
+				}
 			}
 		case attrStateEnum:
 			if c == ',' || isSpaceChar(c) {
@@ -372,6 +453,9 @@ func ParseAttributeList(listStr string) (attrs *AttributeList, err error) {
 	default:
 		err = fmt.Errorf("invalid attribute list format:\n%s\nError: %w", listStr, ErrFormat)
 	}
+	if err == nil {
+		attrs.trailer = listStr[prevEnd:]
+	}
 
 	return
 }
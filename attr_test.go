@@ -1,6 +1,7 @@
 package hls
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -58,3 +59,38 @@ func TestParseAttributeList(t *testing.T) {
 		t.Error("formatted attributes doesn't match original line")
 	}
 }
+
+func TestAttributeListRoundTrip(t *testing.T) {
+	lineStr := `METHOD=AES-128, URI="key.bin" ,IV=0x00000000000000000000000000abcdef, SCORE=1.000,`
+	attrs, err := ParseAttributeList(lineStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, lineStr, attrs.Format())
+	assert.Equal(t, "SCORE=1.000", attrs.GetLast("SCORE").Raw)
+	assert.False(t, attrs.GetLast("IV").Modified())
+
+	*attrs.GetLast("SCORE").FloatValue = 2.5
+	assert.Equal(t, `METHOD=AES-128, URI="key.bin" ,IV=0x00000000000000000000000000abcdef, SCORE=2.5,`, attrs.Format())
+
+	attrs.Set("URI", String("other.bin"))
+	attrs.Remove("METHOD")
+	attrs.Set("KEYFORMAT", String("identity"))
+	assert.Equal(t, `URI="other.bin" ,IV=0x00000000000000000000000000abcdef, SCORE=2.5,KEYFORMAT="identity",`, attrs.Format())
+
+	var empty AttributeList
+	empty.Set("METHOD", Enum("NONE"))
+	assert.Equal(t, "METHOD=NONE", empty.Format())
+	assert.Equal(t, "NONE", *empty.GetLast("METHOD").EnumValue)
+}
+
+func TestParseAttributeListLimits(t *testing.T) {
+	lineStr := `URI="data:application/octet-stream;base64,` + strings.Repeat("A", 40<<10) + `"`
+	attrs, err := ParseAttributeList(lineStr)
+	if assert.NoError(t, err) {
+		value, _ := attrs.GetLast("URI").String()
+		assert.Equal(t, len(lineStr)-len(`URI=""`), len(value))
+	}
+	_, err = ParseAttributeListWithLimits(lineStr, &DefaultParseLimits)
+	assert.ErrorIs(t, err, ErrAttributeValueTooLong)
+}
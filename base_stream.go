@@ -3,23 +3,28 @@ package hls
 import (
 	"fmt"
 	"net/url"
+	"strings"
+
+	"github.com/go-webdl/hls/codecs"
 )
 
 type BaseStream struct {
-	Tag              *Tag        // The EXT-X-STREAM-INF or EXT-X-I-FRAME-STREAM-INF tag
-	TagLine          *Line       // The Line for the EXT-X-STREAM-INF or EXT-X-I-FRAME-STREAM-INF tag
-	URI              *url.URL    // [REQUIRED] stream URI
-	URILine          *Line       // [OPTIONAL] for EXT-X-STREAM-INF, the Line for the URL
-	Bandwidth        uint64      // [REQUIRED] peak segment bit rate of the Variant Stream, in bits per second
-	AverageBandwidth *uint64     // [OPTIONAL] average segment bit rate of the Variant Stream, in bits per second
-	Score            *float64    // [OPTIONAL] abstract, relative measure of the playback quality-of-experience of the Variant Stream
-	Codecs           *string     // [OPTIONAL] comma-separated list of formats, where each format specifies a media sample type that is present in one or more Renditions specified by the Variant Stream
-	Resolution       *Resolution // [OPTIONAL] pixel resolution at which to display all the video in the Variant Stream
-	HDCPLevel        *string     // [OPTIONAL] valid strings are TYPE-0, TYPE-1, and NONE
-	AllowedCPC       *string     // [OPTIONAL] indicate that the playback of a Variant Stream containing encrypted Media Segments is to be restricted to devices that guarantee a certain level of content protection robustness
-	VideoRange       *string     // [OPTIONAL] valid strings are SDR, HLG and PQ
-	StableVariantID  *string     // [OPTIONAL] stable identifier for the URI within the Master Playlist
-	Video            *string     // [OPTIONAL] indicates the set of video Renditions that SHOULD be used when playing the presentation
+	Tag                *Tag                // The EXT-X-STREAM-INF or EXT-X-I-FRAME-STREAM-INF tag
+	TagLine            *Line               // The Line for the EXT-X-STREAM-INF or EXT-X-I-FRAME-STREAM-INF tag
+	URI                *url.URL            // [REQUIRED] stream URI
+	URILine            *Line               // [OPTIONAL] for EXT-X-STREAM-INF, the Line for the URL
+	Bandwidth          uint64              // [REQUIRED] peak segment bit rate of the Variant Stream, in bits per second
+	AverageBandwidth   *uint64             // [OPTIONAL] average segment bit rate of the Variant Stream, in bits per second
+	Score              *float64            // [OPTIONAL] abstract, relative measure of the playback quality-of-experience of the Variant Stream
+	Codecs             *string             // [OPTIONAL] comma-separated list of formats, where each format specifies a media sample type that is present in one or more Renditions specified by the Variant Stream
+	SupplementalCodecs []SupplementalCodec // [OPTIONAL] formats of media samples that enhance the ones listed in CODECS, such as Dolby Vision over HEVC
+	Resolution         *Resolution         // [OPTIONAL] pixel resolution at which to display all the video in the Variant Stream
+	HDCPLevel          *string             // [OPTIONAL] valid strings are TYPE-0, TYPE-1, and NONE
+	AllowedCPC         *string             // [OPTIONAL] indicate that the playback of a Variant Stream containing encrypted Media Segments is to be restricted to devices that guarantee a certain level of content protection robustness
+	VideoRange         *string             // [OPTIONAL] valid strings are SDR, HLG and PQ
+	StableVariantID    *string             // [OPTIONAL] stable identifier for the URI within the Master Playlist
+	ReqVideoLayout     *VideoLayout        // [OPTIONAL] the video layout a client must support to play the Variant Stream, such as stereoscopic video
+	PathwayID          *string             // [OPTIONAL] the Content Steering Pathway the Variant Stream belongs to
 }
 
 func (s *BaseStream) ParseAttributeList(attrs *AttributeList) (err error) {
@@ -50,6 +55,21 @@ func (s *BaseStream) ParseAttributeList(attrs *AttributeList) (err error) {
 			return
 		}
 	}
+	if attr := attrs.GetLast("SUPPLEMENTAL-CODECS"); attr != nil {
+		var value string
+		if value, err = attr.String(); err != nil {
+			err = fmt.Errorf("failed getting SUPPLEMENTAL-CODECS attribute: %w", err)
+			return
+		}
+		for _, part := range strings.Split(value, ",") {
+			var codec SupplementalCodec
+			if err = codec.ParseString(part); err != nil {
+				err = fmt.Errorf("failed parsing SUPPLEMENTAL-CODECS attribute: %w", err)
+				return
+			}
+			s.SupplementalCodecs = append(s.SupplementalCodecs, codec)
+		}
+	}
 	if attr := attrs.GetLast("RESOLUTION"); attr != nil {
 		if s.Resolution, err = attr.ResolutionPtr(); err != nil {
 			err = fmt.Errorf("failed getting RESOLUTION attribute: %w", err)
@@ -80,5 +100,55 @@ func (s *BaseStream) ParseAttributeList(attrs *AttributeList) (err error) {
 			return
 		}
 	}
+	if attr := attrs.GetLast("REQ-VIDEO-LAYOUT"); attr != nil {
+		var value string
+		if value, err = attr.String(); err != nil {
+			err = fmt.Errorf("failed getting REQ-VIDEO-LAYOUT attribute: %w", err)
+			return
+		}
+		s.ReqVideoLayout = &VideoLayout{}
+		if err = s.ReqVideoLayout.ParseString(value); err != nil {
+			err = fmt.Errorf("failed parsing REQ-VIDEO-LAYOUT attribute value as VideoLayout: %w", err)
+			return
+		}
+	}
+	if attr := attrs.GetLast("PATHWAY-ID"); attr != nil {
+		if s.PathwayID, err = attr.StringPtr(); err != nil {
+			err = fmt.Errorf("failed getting PATHWAY-ID attribute: %w", err)
+			return
+		}
+	}
+	return
+}
+
+// ParsedCodecs parses the CODECS attribute, returning nil if it is absent.
+func (s *BaseStream) ParsedCodecs() (list codecs.List, err error) {
+	if s.Codecs == nil {
+		return
+	}
+	if list, err = codecs.ParseList(*s.Codecs); err != nil {
+		err = fmt.Errorf("failed parsing CODECS attribute: %w", err)
+	}
+	return
+}
+
+// SupplementalCodec is an entry of the SUPPLEMENTAL-CODECS attribute, a
+// format optionally followed by slash-separated compatibility brands, such as
+// "dvh1.08.07/db4h".
+type SupplementalCodec struct {
+	codecs.Codec
+	Brands []string
+}
+
+func (c *SupplementalCodec) ParseString(value string) (err error) {
+	slashParts := strings.Split(strings.TrimSpace(value), "/")
+	if c.Codec, err = codecs.Parse(slashParts[0]); err != nil {
+		return
+	}
+	c.Brands = slashParts[1:]
 	return
 }
+
+func (c SupplementalCodec) String() string {
+	return strings.Join(append([]string{c.Codec.String()}, c.Brands...), "/")
+}
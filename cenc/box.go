@@ -0,0 +1,144 @@
+package cenc
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// box is an ISO BMFF box within a buffer.
+type box struct {
+	typ    string
+	start  int // offset of the box header in the buffer
+	offset int // offset of the payload in the buffer
+	end    int // offset after the box in the buffer
+}
+
+// boxes lists the boxes of buf[start:end].
+func boxes(buf []byte, start, end int) (list []box, err error) {
+	for pos := start; pos < end; {
+		if pos+8 > end {
+			return nil, fmt.Errorf("truncated box header at offset %d: %w", pos, ErrInvalidMP4)
+		}
+		b := box{typ: string(buf[pos+4 : pos+8]), start: pos, offset: pos + 8}
+		size := uint64(binary.BigEndian.Uint32(buf[pos:]))
+		switch size {
+		case 0:
+			size = uint64(end - pos)
+		case 1:
+			if pos+16 > end {
+				return nil, fmt.Errorf("truncated %s box header at offset %d: %w", b.typ, pos, ErrInvalidMP4)
+			}
+			size = binary.BigEndian.Uint64(buf[pos+8:])
+			b.offset += 8
+		}
+		if size < uint64(b.offset-pos) || size > uint64(end-pos) {
+			return nil, fmt.Errorf("%s box at offset %d has invalid size %d: %w", b.typ, pos, size, ErrInvalidMP4)
+		}
+		b.end = pos + int(size)
+		list = append(list, b)
+		pos = b.end
+	}
+	return
+}
+
+// children lists the boxes in the payload of b, after skip bytes.
+func (b box) children(buf []byte, skip int) ([]box, error) {
+	if b.offset+skip > b.end {
+		return nil, fmt.Errorf("truncated %s box: %w", b.typ, ErrInvalidMP4)
+	}
+	return boxes(buf, b.offset+skip, b.end)
+}
+
+func (b box) payload(buf []byte) []byte {
+	return buf[b.offset:b.end]
+}
+
+// find returns the first box of the given type, or false.
+func find(list []box, typ string) (box, bool) {
+	for _, b := range list {
+		if b.typ == typ {
+			return b, true
+		}
+	}
+	return box{}, false
+}
+
+// findPath follows a path of box types from the boxes of buf[start:end],
+// returning the last one.
+func findPath(buf []byte, list []box, path ...string) (b box, ok bool, err error) {
+	for i, typ := range path {
+		if b, ok = find(list, typ); !ok {
+			return
+		}
+		if i < len(path)-1 {
+			if list, err = b.children(buf, 0); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+// reader reads the big-endian fields of a box payload.
+type reader struct {
+	buf []byte
+	pos int
+	err error
+}
+
+func (r *reader) need(n int) bool {
+	if r.err != nil {
+		return false
+	}
+	if r.pos+n > len(r.buf) {
+		r.err = fmt.Errorf("truncated box payload: %w", ErrInvalidMP4)
+		return false
+	}
+	return true
+}
+
+func (r *reader) u8() (v uint8) {
+	if r.need(1) {
+		v = r.buf[r.pos]
+		r.pos++
+	}
+	return
+}
+
+func (r *reader) u16() (v uint16) {
+	if r.need(2) {
+		v = binary.BigEndian.Uint16(r.buf[r.pos:])
+		r.pos += 2
+	}
+	return
+}
+
+func (r *reader) u32() (v uint32) {
+	if r.need(4) {
+		v = binary.BigEndian.Uint32(r.buf[r.pos:])
+		r.pos += 4
+	}
+	return
+}
+
+func (r *reader) u64() (v uint64) {
+	if r.need(8) {
+		v = binary.BigEndian.Uint64(r.buf[r.pos:])
+		r.pos += 8
+	}
+	return
+}
+
+func (r *reader) bytes(n int) (v []byte) {
+	if r.need(n) {
+		v = r.buf[r.pos : r.pos+n]
+		r.pos += n
+	}
+	return
+}
+
+// fullBox reads the version and flags of a full box.
+func (r *reader) fullBox() (version uint8, flags uint32) {
+	v := r.u32()
+	return uint8(v >> 24), v & 0xFFFFFF
+}
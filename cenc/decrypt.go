@@ -0,0 +1,357 @@
+package cenc
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"fmt"
+)
+
+// tfhd and trun flags
+const (
+	tfhdBaseDataOffset         = 0x000001
+	tfhdSampleDescriptionIndex = 0x000002
+	tfhdDefaultSampleDuration  = 0x000008
+	tfhdDefaultSampleSize      = 0x000010
+
+	trunDataOffset       = 0x000001
+	trunFirstSampleFlags = 0x000004
+	trunSampleDuration   = 0x000100
+	trunSampleSize       = 0x000200
+	trunSampleFlags      = 0x000400
+	trunSampleCTO        = 0x000800
+
+	sencUseSubsamples = 0x000002
+)
+
+// sample is the location of a sample in the segment.
+type sample struct {
+	offset int
+	size   int
+}
+
+type subsample struct {
+	clear     int
+	protected int
+}
+
+// sampleInfo is the sample auxiliary information of a sample.
+type sampleInfo struct {
+	iv         []byte
+	subsamples []subsample
+}
+
+// DecryptSegment decrypts the samples of a media segment in place. The
+// tracks, their scheme and default KIDs come from the initialization
+// segment, the keys from keys, by KID. The cenc and cbcs schemes are
+// supported. Sample auxiliary information is read from the senc box, or
+// else from the data pointed to by the saiz and saio boxes. Samples of
+// tracks whose sample entry is not encv or enca are left as they are, and so
+// are the encryption boxes, so ClearInit should be used with the
+// initialization segment.
+func DecryptSegment(init *Init, segment []byte, keys map[KeyID][]byte) (err error) {
+	top, err := boxes(segment, 0, len(segment))
+	if err != nil {
+		return
+	}
+	for _, moof := range top {
+		if moof.typ != "moof" {
+			continue
+		}
+		var trafs []box
+		if trafs, err = moof.children(segment, 0); err != nil {
+			return
+		}
+		for _, traf := range trafs {
+			if traf.typ != "traf" {
+				continue
+			}
+			if err = decryptTraf(init, segment, moof, traf, keys); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+func decryptTraf(init *Init, segment []byte, moof, traf box, keys map[KeyID][]byte) (err error) {
+	children, err := traf.children(segment, 0)
+	if err != nil {
+		return
+	}
+	tfhd, ok := find(children, "tfhd")
+	if !ok {
+		return fmt.Errorf("missing tfhd box: %w", ErrInvalidMP4)
+	}
+	r := &reader{buf: tfhd.payload(segment)}
+	_, flags := r.fullBox()
+	trackID := r.u32()
+	track := init.Tracks[trackID]
+	if track == nil {
+		return fmt.Errorf("fragment of unknown track %d: %w", trackID, ErrInvalidMP4)
+	}
+	if !track.Protected {
+		return
+	}
+	// only the first track fragment of a moof may rely on the implicit base,
+	// which is the moof itself both then and with default-base-is-moof
+	base := uint64(moof.start)
+	if flags&tfhdBaseDataOffset != 0 {
+		base = r.u64()
+	}
+	if flags&tfhdSampleDescriptionIndex != 0 {
+		r.u32()
+	}
+	if flags&tfhdDefaultSampleDuration != 0 {
+		r.u32()
+	}
+	defaultSize := track.DefaultSampleSize
+	if flags&tfhdDefaultSampleSize != 0 {
+		defaultSize = r.u32()
+	}
+	if r.err != nil {
+		return r.err
+	}
+
+	samples, err := trafSamples(segment, children, base, defaultSize)
+	if err != nil {
+		return
+	}
+	infos, err := trafSampleInfos(segment, children, base, track)
+	if err != nil {
+		return
+	}
+	if infos == nil {
+		if track.DefaultIsProtected {
+			return fmt.Errorf("track %d: missing sample auxiliary information: %w", track.ID, ErrInvalidMP4)
+		}
+		return
+	}
+	if len(infos) != len(samples) {
+		return fmt.Errorf("track %d: %d samples but auxiliary information for %d: %w", track.ID, len(samples), len(infos), ErrInvalidMP4)
+	}
+
+	key, ok := keys[track.DefaultKID]
+	if !ok {
+		return fmt.Errorf("track %d: %s: %w", track.ID, track.DefaultKID, ErrMissingKey)
+	}
+	if len(key) != aes.BlockSize {
+		return ErrInvalidKey
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return
+	}
+	for i, s := range samples {
+		if s.offset < 0 || s.offset+s.size > len(segment) {
+			return fmt.Errorf("track %d: sample %d is outside of the segment: %w", track.ID, i, ErrInvalidMP4)
+		}
+		if err = decryptSample(track, block, segment[s.offset:s.offset+s.size], infos[i]); err != nil {
+			return fmt.Errorf("track %d: sample %d: %w", track.ID, i, err)
+		}
+	}
+	return
+}
+
+// trafSamples locates the samples of the track runs of a track fragment.
+func trafSamples(segment []byte, children []box, base uint64, defaultSize uint32) (samples []sample, err error) {
+	next := base
+	for _, trun := range children {
+		if trun.typ != "trun" {
+			continue
+		}
+		r := &reader{buf: trun.payload(segment)}
+		_, flags := r.fullBox()
+		count := r.u32()
+		offset := next
+		if flags&trunDataOffset != 0 {
+			offset = base + uint64(int64(int32(r.u32())))
+		}
+		if flags&trunFirstSampleFlags != 0 {
+			r.u32()
+		}
+		fields := 0
+		for _, f := range []uint32{trunSampleDuration, trunSampleSize, trunSampleFlags, trunSampleCTO} {
+			if flags&f != 0 {
+				fields++
+			}
+		}
+		if r.err == nil && (uint64(count)*uint64(fields)*4 > uint64(len(r.buf)) || uint64(count) > uint64(len(segment))) {
+			return nil, fmt.Errorf("trun box of %d samples is truncated: %w", count, ErrInvalidMP4)
+		}
+		for i := uint32(0); i < count && r.err == nil; i++ {
+			if flags&trunSampleDuration != 0 {
+				r.u32()
+			}
+			size := defaultSize
+			if flags&trunSampleSize != 0 {
+				size = r.u32()
+			}
+			if flags&trunSampleFlags != 0 {
+				r.u32()
+			}
+			if flags&trunSampleCTO != 0 {
+				r.u32()
+			}
+			if offset+uint64(size) > uint64(len(segment)) {
+				return nil, fmt.Errorf("trun sample %d is outside of the segment: %w", i, ErrInvalidMP4)
+			}
+			samples = append(samples, sample{offset: int(offset), size: int(size)})
+			offset += uint64(size)
+		}
+		if r.err != nil {
+			return nil, r.err
+		}
+		next = offset
+	}
+	return
+}
+
+// trafSampleInfos reads the sample auxiliary information of a track
+// fragment, or returns nil if there is none.
+func trafSampleInfos(segment []byte, children []box, base uint64, track *Track) (infos []sampleInfo, err error) {
+	if senc, ok := find(children, "senc"); ok {
+		r := &reader{buf: senc.payload(segment)}
+		_, flags := r.fullBox()
+		count := r.u32()
+		if r.err == nil && uint64(count) > uint64(len(r.buf)) {
+			return nil, fmt.Errorf("senc box of %d samples is truncated: %w", count, ErrInvalidMP4)
+		}
+		for i := uint32(0); i < count && r.err == nil; i++ {
+			infos = append(infos, readSampleInfo(r, track, flags&sencUseSubsamples != 0))
+		}
+		return infos, r.err
+	}
+
+	saiz, ok := find(children, "saiz")
+	if !ok {
+		return
+	}
+	saio, ok := find(children, "saio")
+	if !ok {
+		return nil, fmt.Errorf("saiz box without saio box: %w", ErrInvalidMP4)
+	}
+	r := &reader{buf: saiz.payload(segment)}
+	_, flags := r.fullBox()
+	if flags&1 != 0 {
+		r.u64() // aux_info_type and aux_info_type_parameter
+	}
+	defaultSize := r.u8()
+	count := r.u32()
+	var sizes []byte
+	if defaultSize == 0 {
+		sizes = r.bytes(int(count))
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	r = &reader{buf: saio.payload(segment)}
+	version, flags := r.fullBox()
+	if flags&1 != 0 {
+		r.u64()
+	}
+	if entries := r.u32(); r.err == nil && entries != 1 {
+		return nil, fmt.Errorf("saio box with %d offsets: %w", entries, ErrUnsupportedScheme)
+	}
+	offset := uint64(r.u32())
+	if version == 1 {
+		offset = offset<<32 | uint64(r.u32())
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	offset += base
+
+	for i := 0; i < int(count); i++ {
+		size := uint64(defaultSize)
+		if sizes != nil {
+			size = uint64(sizes[i])
+		}
+		if offset+size > uint64(len(segment)) {
+			return nil, fmt.Errorf("sample auxiliary information %d is outside of the segment: %w", i, ErrInvalidMP4)
+		}
+		r = &reader{buf: segment[offset : offset+size]}
+		info := readSampleInfo(r, track, int(size) > int(track.DefaultPerSampleIVSize))
+		if r.err != nil {
+			return nil, r.err
+		}
+		infos = append(infos, info)
+		offset += size
+	}
+	return
+}
+
+// readSampleInfo reads the IV and the subsamples of a sample, the constant
+// IV being used when there is no per-sample one.
+func readSampleInfo(r *reader, track *Track, hasSubsamples bool) (info sampleInfo) {
+	if track.DefaultPerSampleIVSize > 0 {
+		info.iv = r.bytes(int(track.DefaultPerSampleIVSize))
+	} else {
+		info.iv = track.DefaultConstantIV
+	}
+	if hasSubsamples {
+		count := int(r.u16())
+		for i := 0; i < count && r.err == nil; i++ {
+			info.subsamples = append(info.subsamples, subsample{clear: int(r.u16()), protected: int(r.u32())})
+		}
+	}
+	return
+}
+
+// decryptSample decrypts the protected ranges of a sample in place. With
+// cenc, the counter runs on across the protected ranges of the sample. With
+// cbcs, the CBC chain restarts from the IV for every protected range, in
+// which only the crypt blocks of the pattern are encrypted, and a trailing
+// partial block is clear.
+func decryptSample(track *Track, block cipher.Block, data []byte, info sampleInfo) (err error) {
+	var ranges [][]byte
+	if info.subsamples == nil {
+		ranges = [][]byte{data}
+	} else {
+		pos := 0
+		for _, s := range info.subsamples {
+			pos += s.clear
+			if pos+s.protected > len(data) {
+				return fmt.Errorf("subsamples exceed the sample of %d bytes: %w", len(data), ErrInvalidMP4)
+			}
+			ranges = append(ranges, data[pos:pos+s.protected])
+			pos += s.protected
+		}
+	}
+	iv := make([]byte, aes.BlockSize)
+	switch track.Scheme {
+	case SchemeCENC, "":
+		if len(info.iv) != 8 && len(info.iv) != 16 {
+			return fmt.Errorf("cenc IV of %d bytes: %w", len(info.iv), ErrInvalidMP4)
+		}
+		// 8-byte IVs are followed by the block counter
+		copy(iv, info.iv)
+		stream := cipher.NewCTR(block, iv)
+		for _, p := range ranges {
+			stream.XORKeyStream(p, p)
+		}
+	case SchemeCBCS:
+		if len(info.iv) != aes.BlockSize {
+			return fmt.Errorf("cbcs IV of %d bytes: %w", len(info.iv), ErrInvalidMP4)
+		}
+		copy(iv, info.iv)
+		crypt, skip := int(track.DefaultCryptByteBlock), int(track.DefaultSkipByteBlock)
+		if crypt == 0 && skip == 0 {
+			// no pattern: every block is encrypted
+			crypt = 1
+		}
+		for _, p := range ranges {
+			mode := cipher.NewCBCDecrypter(block, iv)
+			for pos := 0; pos+aes.BlockSize <= len(p); pos += (crypt + skip) * aes.BlockSize {
+				n := crypt * aes.BlockSize
+				if remaining := (len(p) - pos) / aes.BlockSize * aes.BlockSize; n > remaining {
+					n = remaining
+				}
+				mode.CryptBlocks(p[pos:pos+n], p[pos:pos+n])
+			}
+		}
+	default:
+		return fmt.Errorf("scheme %q: %w", track.Scheme, ErrUnsupportedScheme)
+	}
+	return
+}
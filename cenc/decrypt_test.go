@@ -0,0 +1,267 @@
+package cenc
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	testKID = KeyID{0x10, 0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18, 0x19, 0x1a, 0x1b, 0x1c, 0x1d, 0x1e, 0x1f}
+	testKey = []byte("0123456789abcdef")
+)
+
+const testTrackID = 1
+
+func mp4Box(typ string, payloads ...[]byte) []byte {
+	b := make([]byte, 8)
+	copy(b[4:], typ)
+	for _, p := range payloads {
+		b = append(b, p...)
+	}
+	binary.BigEndian.PutUint32(b, uint32(len(b)))
+	return b
+}
+
+func fullBox(typ string, version byte, flags uint32, payloads ...[]byte) []byte {
+	header := []byte{version, byte(flags >> 16), byte(flags >> 8), byte(flags)}
+	return mp4Box(typ, append([][]byte{header}, payloads...)...)
+}
+
+func u16(v uint16) []byte {
+	b := make([]byte, 2)
+	binary.BigEndian.PutUint16(b, v)
+	return b
+}
+
+func u32(v uint32) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, v)
+	return b
+}
+
+// testInit builds an initialization segment of one encv track.
+func testInit(scheme string, ivSize byte, constantIV []byte, crypt, skip byte) []byte {
+	tenc := []byte{0, crypt<<4 | skip, 1, ivSize}
+	tenc = append(tenc, testKID[:]...)
+	if ivSize == 0 {
+		tenc = append(append(tenc, byte(len(constantIV))), constantIV...)
+	}
+	sinf := mp4Box("sinf",
+		mp4Box("frma", []byte("avc1")),
+		fullBox("schm", 0, 0, []byte(scheme), u32(0x10000)),
+		mp4Box("schi", fullBox("tenc", 1, 0, tenc)),
+	)
+	encv := mp4Box("encv", make([]byte, visualSampleEntrySize), mp4Box("avcC", []byte{1, 2, 3}), sinf)
+	stsd := fullBox("stsd", 0, 0, u32(1), encv)
+	trak := mp4Box("trak",
+		fullBox("tkhd", 0, 0, u32(0), u32(0), u32(testTrackID), make([]byte, 68)),
+		mp4Box("mdia", mp4Box("minf", mp4Box("stbl", stsd))),
+	)
+	mvex := mp4Box("mvex", fullBox("trex", 0, 0, u32(testTrackID), u32(1), u32(0), u32(0), u32(0)))
+	return append(mp4Box("ftyp", []byte("iso6"), u32(0)), mp4Box("moov", trak, mvex)...)
+}
+
+type testSample struct {
+	data       []byte
+	iv         []byte
+	subsamples []subsample
+}
+
+// testSegment builds a segment of the samples, with their auxiliary
+// information in a senc box, or in saiz and saio boxes.
+func testSegment(samples []testSample, ivSize int, useSaio bool) []byte {
+	var trun, aux, sizes []byte
+	trun = append(trun, u32(uint32(len(samples)))...)
+	trun = append(trun, u32(0)...) // data offset, patched below
+	var mdat []byte
+	for _, s := range samples {
+		trun = append(trun, u32(uint32(len(s.data)))...)
+		mdat = append(mdat, s.data...)
+		info := append([]byte(nil), s.iv[:ivSize]...)
+		if s.subsamples != nil {
+			info = append(info, u16(uint16(len(s.subsamples)))...)
+			for _, sub := range s.subsamples {
+				info = append(append(info, u16(uint16(sub.clear))...), u32(uint32(sub.protected))...)
+			}
+		}
+		aux = append(aux, info...)
+		sizes = append(sizes, byte(len(info)))
+	}
+	build := func(dataOffset, auxOffset uint32) []byte {
+		binary.BigEndian.PutUint32(trun[4:], dataOffset)
+		children := [][]byte{
+			fullBox("tfhd", 0, 0x020000, u32(testTrackID)),
+			fullBox("trun", 0, trunDataOffset|trunSampleSize, trun),
+		}
+		if useSaio {
+			children = append(children,
+				fullBox("saiz", 0, 0, []byte{0}, u32(uint32(len(samples))), sizes),
+				fullBox("saio", 0, 0, u32(1), u32(auxOffset)),
+			)
+		} else {
+			flags := uint32(0)
+			if samples[0].subsamples != nil {
+				flags = sencUseSubsamples
+			}
+			children = append(children, fullBox("senc", 0, flags, u32(uint32(len(samples))), aux))
+		}
+		return mp4Box("moof", fullBox("mfhd", 0, 0, u32(1)), mp4Box("traf", children...))
+	}
+	// the auxiliary information is stored at the start of the mdat
+	moofSize := uint32(len(build(0, 0)))
+	segment := build(moofSize+8+uint32(len(aux)), moofSize+8)
+	return append(segment, mp4Box("mdat", aux, mdat)...)
+}
+
+func encryptCENC(block cipher.Block, s testSample) []byte {
+	enc := append([]byte(nil), s.data...)
+	iv := make([]byte, 16)
+	copy(iv, s.iv)
+	stream := cipher.NewCTR(block, iv)
+	pos := 0
+	for _, sub := range s.subsamples {
+		pos += sub.clear
+		stream.XORKeyStream(enc[pos:pos+sub.protected], enc[pos:pos+sub.protected])
+		pos += sub.protected
+	}
+	return enc
+}
+
+func encryptCBCS(block cipher.Block, s testSample, crypt, skip int) []byte {
+	enc := append([]byte(nil), s.data...)
+	pos := 0
+	for _, sub := range s.subsamples {
+		pos += sub.clear
+		p := enc[pos : pos+sub.protected]
+		mode := cipher.NewCBCEncrypter(block, s.iv)
+		for i := 0; i+aes.BlockSize <= len(p); i += (crypt + skip) * aes.BlockSize {
+			for j := 0; j < crypt && i+(j+1)*aes.BlockSize <= len(p); j++ {
+				mode.CryptBlocks(p[i+j*aes.BlockSize:i+(j+1)*aes.BlockSize], p[i+j*aes.BlockSize:i+(j+1)*aes.BlockSize])
+			}
+		}
+		pos += sub.protected
+	}
+	return enc
+}
+
+func testSamples(r *rand.Rand, iv func() []byte) (samples []testSample) {
+	for _, sizes := range [][]subsample{
+		{{5, 300}},
+		{{20, 17}, {3, 400}},
+		{{100, 0}},
+		{{7, 1000}, {0, 33}},
+	} {
+		s := testSample{iv: iv(), subsamples: sizes}
+		for _, sub := range sizes {
+			s.data = append(s.data, make([]byte, sub.clear+sub.protected)...)
+		}
+		r.Read(s.data)
+		samples = append(samples, s)
+	}
+	return
+}
+
+func TestParseInit(t *testing.T) {
+	init, err := ParseInit(testInit(SchemeCBCS, 0, []byte("fedcba9876543210"), 1, 9))
+	if !assert.NoError(t, err) {
+		return
+	}
+	if assert.Contains(t, init.Tracks, uint32(testTrackID)) {
+		track := init.Tracks[testTrackID]
+		assert.True(t, track.Protected)
+		assert.Equal(t, "avc1", track.Format)
+		assert.Equal(t, SchemeCBCS, track.Scheme)
+		assert.Equal(t, testKID, track.DefaultKID)
+		assert.Equal(t, []byte("fedcba9876543210"), track.DefaultConstantIV)
+		assert.Equal(t, uint8(1), track.DefaultCryptByteBlock)
+		assert.Equal(t, uint8(9), track.DefaultSkipByteBlock)
+	}
+	assert.Equal(t, "101112131415161718191a1b1c1d1e1f", testKID.String())
+}
+
+func TestDecryptSegment(t *testing.T) {
+	block, _ := aes.NewCipher(testKey)
+	keys := map[KeyID][]byte{testKID: testKey}
+
+	for _, c := range []struct {
+		name    string
+		scheme  string
+		ivSize  int
+		useSaio bool
+	}{
+		{"cenc senc", SchemeCENC, 8, false},
+		{"cenc saio", SchemeCENC, 16, true},
+		{"cbcs senc", SchemeCBCS, 0, false},
+		{"cbcs saio", SchemeCBCS, 16, true},
+	} {
+		r := rand.New(rand.NewSource(1))
+		constantIV := []byte("fedcba9876543210")
+		iv := func() []byte {
+			if c.ivSize == 0 {
+				return constantIV
+			}
+			b := make([]byte, 16)
+			r.Read(b[:c.ivSize])
+			return b
+		}
+		samples := testSamples(r, iv)
+		encrypted := make([]testSample, len(samples))
+		for i, s := range samples {
+			encrypted[i] = s
+			if c.scheme == SchemeCENC {
+				encrypted[i].data = encryptCENC(block, s)
+			} else {
+				encrypted[i].data = encryptCBCS(block, s, 1, 9)
+			}
+		}
+		init, err := ParseInit(testInit(c.scheme, byte(c.ivSize), constantIV, 1, 9))
+		if !assert.NoError(t, err, c.name) {
+			continue
+		}
+		segment := testSegment(encrypted, c.ivSize, c.useSaio)
+		expected := testSegment(samples, c.ivSize, c.useSaio)
+		assert.False(t, bytes.Equal(expected, segment), c.name)
+		assert.NoError(t, DecryptSegment(init, segment, keys), c.name)
+		assert.True(t, bytes.Equal(expected, segment), c.name)
+	}
+}
+
+func TestDecryptSegmentErrors(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	samples := testSamples(r, func() []byte { return make([]byte, 16) })
+	segment := testSegment(samples, 8, false)
+
+	init, _ := ParseInit(testInit(SchemeCENC, 8, nil, 0, 0))
+	err := DecryptSegment(init, segment, map[KeyID][]byte{})
+	assert.ErrorIs(t, err, ErrMissingKey)
+
+	init, _ = ParseInit(testInit("cbc1", 8, nil, 0, 0))
+	err = DecryptSegment(init, segment, map[KeyID][]byte{testKID: testKey})
+	assert.ErrorIs(t, err, ErrUnsupportedScheme)
+
+	err = DecryptSegment(init, segment[:len(segment)-10], map[KeyID][]byte{testKID: testKey})
+	assert.ErrorIs(t, err, ErrInvalidMP4)
+}
+
+func TestClearInit(t *testing.T) {
+	data := testInit(SchemeCENC, 8, nil, 0, 0)
+	clear, err := ClearInit(data)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, len(data), len(clear))
+	assert.True(t, bytes.Contains(data, []byte("encv")))
+	assert.False(t, bytes.Contains(clear, []byte("encv")))
+	assert.False(t, bytes.Contains(clear, []byte("sinf")))
+	init, err := ParseInit(clear)
+	if assert.NoError(t, err) {
+		assert.False(t, init.Tracks[testTrackID].Protected)
+		assert.Equal(t, "avc1", init.Tracks[testTrackID].Format)
+	}
+}
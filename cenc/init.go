@@ -0,0 +1,280 @@
+// Package cenc decrypts fragmented MP4 segments protected with ISO/IEC
+// 23001-7 Common Encryption, as used by the SAMPLE-AES-CTR and ISO-23001-7
+// key methods of HLS.
+package cenc
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrInvalidMP4        = errors.New("invalid fragmented MP4")
+	ErrUnsupportedScheme = errors.New("unsupported protection scheme")
+	ErrMissingKey        = errors.New("no key for key ID")
+	ErrInvalidKey        = errors.New("CENC key must be 16 bytes")
+)
+
+// Protection schemes of Common Encryption.
+const (
+	SchemeCENC = "cenc" // AES-CTR, full sample
+	SchemeCBCS = "cbcs" // AES-CBC, pattern encryption and constant IV
+	SchemeCENS = "cens" // AES-CTR, pattern encryption
+	SchemeCBC1 = "cbc1" // AES-CBC, full sample
+)
+
+// KeyID is the 16-byte KID of a content key.
+type KeyID [16]byte
+
+func (id KeyID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// Track is the protection of a track, from its initialization segment.
+type Track struct {
+	ID        uint32
+	Format    string // sample entry format, the original one of protected tracks
+	Protected bool   // the sample entry is encv or enca
+	Scheme    string // [OPTIONAL] scheme_type of the schm box
+
+	// tenc defaults
+	DefaultIsProtected     bool
+	DefaultPerSampleIVSize uint8
+	DefaultKID             KeyID
+	DefaultConstantIV      []byte
+	DefaultCryptByteBlock  uint8
+	DefaultSkipByteBlock   uint8
+
+	// trex defaults
+	DefaultSampleSize uint32
+}
+
+// Init is the initialization segment of a fragmented MP4.
+type Init struct {
+	Tracks map[uint32]*Track
+}
+
+// sample entry header sizes, before the child boxes
+const (
+	visualSampleEntrySize = 78
+	audioSampleEntrySize  = 28
+)
+
+// ParseInit reads the tracks of an initialization segment and their
+// protection.
+func ParseInit(data []byte) (init *Init, err error) {
+	top, err := boxes(data, 0, len(data))
+	if err != nil {
+		return
+	}
+	moov, ok := find(top, "moov")
+	if !ok {
+		return nil, fmt.Errorf("missing moov box: %w", ErrInvalidMP4)
+	}
+	children, err := moov.children(data, 0)
+	if err != nil {
+		return
+	}
+	init = &Init{Tracks: make(map[uint32]*Track)}
+	for _, b := range children {
+		if b.typ != "trak" {
+			continue
+		}
+		var track *Track
+		if track, err = parseTrak(data, b); err != nil {
+			return nil, err
+		}
+		init.Tracks[track.ID] = track
+	}
+	var trexes []box
+	if mvex, ok := find(children, "mvex"); ok {
+		if trexes, err = mvex.children(data, 0); err != nil {
+			return nil, err
+		}
+	}
+	for _, b := range trexes {
+		if b.typ != "trex" {
+			continue
+		}
+		r := &reader{buf: b.payload(data)}
+		r.fullBox()
+		id := r.u32()
+		r.u32() // default_sample_description_index
+		r.u32() // default_sample_duration
+		size := r.u32()
+		if r.err != nil {
+			return nil, r.err
+		}
+		if track := init.Tracks[id]; track != nil {
+			track.DefaultSampleSize = size
+		}
+	}
+	return
+}
+
+func parseTrak(data []byte, trak box) (track *Track, err error) {
+	children, err := trak.children(data, 0)
+	if err != nil {
+		return
+	}
+	tkhd, ok := find(children, "tkhd")
+	if !ok {
+		return nil, fmt.Errorf("missing tkhd box: %w", ErrInvalidMP4)
+	}
+	r := &reader{buf: tkhd.payload(data)}
+	track = &Track{}
+	if version, _ := r.fullBox(); version == 1 {
+		r.u64() // creation_time
+		r.u64() // modification_time
+	} else {
+		r.u32()
+		r.u32()
+	}
+	track.ID = r.u32()
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	stsd, ok, err := findPath(data, children, "mdia", "minf", "stbl", "stsd")
+	if err != nil || !ok {
+		return
+	}
+	entries, err := stsd.children(data, 8)
+	if err != nil || len(entries) == 0 {
+		return
+	}
+	// fragments are expected to use the first sample description
+	entry := entries[0]
+	track.Format = entry.typ
+	var headerSize int
+	switch entry.typ {
+	case "encv":
+		headerSize = visualSampleEntrySize
+	case "enca":
+		headerSize = audioSampleEntrySize
+	default:
+		return
+	}
+	track.Protected = true
+	entryChildren, err := entry.children(data, headerSize)
+	if err != nil {
+		return
+	}
+	sinf, ok := find(entryChildren, "sinf")
+	if !ok {
+		return nil, fmt.Errorf("track %d: missing sinf box: %w", track.ID, ErrInvalidMP4)
+	}
+	err = parseSinf(data, sinf, track)
+	return
+}
+
+func parseSinf(data []byte, sinf box, track *Track) (err error) {
+	children, err := sinf.children(data, 0)
+	if err != nil {
+		return
+	}
+	if frma, ok := find(children, "frma"); ok {
+		if frma.end-frma.offset < 4 {
+			return fmt.Errorf("truncated frma box: %w", ErrInvalidMP4)
+		}
+		track.Format = string(data[frma.offset : frma.offset+4])
+	}
+	if schm, ok := find(children, "schm"); ok {
+		r := &reader{buf: schm.payload(data)}
+		r.fullBox()
+		track.Scheme = string(r.bytes(4))
+		if r.err != nil {
+			return r.err
+		}
+	}
+	tenc, ok, err := findPath(data, children, "schi", "tenc")
+	if err != nil {
+		return
+	}
+	if !ok {
+		return fmt.Errorf("track %d: missing tenc box: %w", track.ID, ErrInvalidMP4)
+	}
+	r := &reader{buf: tenc.payload(data)}
+	version, _ := r.fullBox()
+	r.u8() // reserved
+	pattern := r.u8()
+	if version > 0 {
+		track.DefaultCryptByteBlock = pattern >> 4
+		track.DefaultSkipByteBlock = pattern & 0x0F
+	}
+	track.DefaultIsProtected = r.u8() != 0
+	track.DefaultPerSampleIVSize = r.u8()
+	copy(track.DefaultKID[:], r.bytes(16))
+	if track.DefaultIsProtected && track.DefaultPerSampleIVSize == 0 {
+		track.DefaultConstantIV = append([]byte(nil), r.bytes(int(r.u8()))...)
+	}
+	return r.err
+}
+
+// ClearInit returns a copy of an initialization segment describing its
+// tracks as clear: protected sample entries get their original format back
+// and their sinf boxes become free boxes, so that decrypted segments can be
+// played without a CDM.
+func ClearInit(data []byte) (clear []byte, err error) {
+	clear = append([]byte(nil), data...)
+	top, err := boxes(clear, 0, len(clear))
+	if err != nil {
+		return
+	}
+	moov, ok := find(top, "moov")
+	if !ok {
+		return nil, fmt.Errorf("missing moov box: %w", ErrInvalidMP4)
+	}
+	traks, err := moov.children(clear, 0)
+	if err != nil {
+		return
+	}
+	for _, trak := range traks {
+		if trak.typ != "trak" {
+			continue
+		}
+		var children []box
+		if children, err = trak.children(clear, 0); err != nil {
+			return nil, err
+		}
+		stsd, ok, err := findPath(clear, children, "mdia", "minf", "stbl", "stsd")
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		entries, err := stsd.children(clear, 8)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			headerSize := visualSampleEntrySize
+			if entry.typ == "enca" {
+				headerSize = audioSampleEntrySize
+			} else if entry.typ != "encv" {
+				continue
+			}
+			entryChildren, err := entry.children(clear, headerSize)
+			if err != nil {
+				return nil, err
+			}
+			sinf, ok := find(entryChildren, "sinf")
+			if !ok {
+				continue
+			}
+			sinfChildren, err := sinf.children(clear, 0)
+			if err != nil {
+				return nil, err
+			}
+			frma, ok := find(sinfChildren, "frma")
+			if !ok || frma.end-frma.offset < 4 {
+				return nil, fmt.Errorf("missing frma box: %w", ErrInvalidMP4)
+			}
+			copy(clear[entry.start+4:entry.start+8], clear[frma.offset:frma.offset+4])
+			copy(clear[sinf.start+4:sinf.start+8], "free")
+		}
+	}
+	return
+}
@@ -0,0 +1,198 @@
+package cenc
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf16"
+)
+
+// SystemID identifies a DRM system in pssh boxes.
+type SystemID [16]byte
+
+var (
+	SystemIDWidevine  = SystemID{0xed, 0xef, 0x8b, 0xa9, 0x79, 0xd6, 0x4a, 0xce, 0xa3, 0xc8, 0x27, 0xdc, 0xd5, 0x1d, 0x21, 0xed}
+	SystemIDPlayReady = SystemID{0x9a, 0x04, 0xf0, 0x79, 0x98, 0x40, 0x42, 0x86, 0xab, 0x92, 0xe6, 0x5b, 0xe0, 0x88, 0x5f, 0x95}
+	SystemIDFairPlay  = SystemID{0x94, 0xce, 0x86, 0xfb, 0x07, 0xff, 0x4f, 0x43, 0xad, 0xb8, 0x93, 0xd2, 0xfa, 0x96, 0x8c, 0xa2}
+)
+
+// String formats the system ID as a UUID.
+func (id SystemID) String() string {
+	s := hex.EncodeToString(id[:])
+	return s[:8] + "-" + s[8:12] + "-" + s[12:16] + "-" + s[16:20] + "-" + s[20:]
+}
+
+// ParseUUID parses a UUID, with or without dashes.
+func ParseUUID(s string) (id [16]byte, err error) {
+	b, err := hex.DecodeString(strings.ReplaceAll(s, "-", ""))
+	if err != nil || len(b) != len(id) {
+		return id, fmt.Errorf("invalid UUID %q", s)
+	}
+	copy(id[:], b)
+	return
+}
+
+// PSSH is a Protection System Specific Header box.
+type PSSH struct {
+	Version  uint8
+	SystemID SystemID
+	KeyIDs   []KeyID // the KIDs of a version 1 box, or those found in the Widevine or PlayReady data of a version 0 one
+	Data     []byte  // the DRM system specific data
+}
+
+// ParsePSSH parses the pssh box starting data.
+func ParsePSSH(data []byte) (pssh *PSSH, err error) {
+	list, err := boxes(data, 0, len(data))
+	if err != nil {
+		return
+	}
+	if len(list) == 0 || list[0].typ != "pssh" {
+		return nil, fmt.Errorf("not a pssh box: %w", ErrInvalidMP4)
+	}
+	r := &reader{buf: list[0].payload(data)}
+	pssh = &PSSH{}
+	pssh.Version, _ = r.fullBox()
+	copy(pssh.SystemID[:], r.bytes(16))
+	if pssh.Version > 0 {
+		count := r.u32()
+		if r.err == nil && uint64(count)*16 > uint64(len(r.buf)) {
+			return nil, fmt.Errorf("pssh box of %d KIDs is truncated: %w", count, ErrInvalidMP4)
+		}
+		for i := uint32(0); i < count && r.err == nil; i++ {
+			var kid KeyID
+			copy(kid[:], r.bytes(16))
+			pssh.KeyIDs = append(pssh.KeyIDs, kid)
+		}
+	}
+	pssh.Data = r.bytes(int(r.u32()))
+	if r.err != nil {
+		return nil, r.err
+	}
+	// the KIDs of the system specific data are best effort
+	if len(pssh.KeyIDs) == 0 {
+		switch pssh.SystemID {
+		case SystemIDWidevine:
+			pssh.KeyIDs, _ = WidevineKeyIDs(pssh.Data)
+		case SystemIDPlayReady:
+			pssh.KeyIDs, _ = PlayReadyKeyIDs(pssh.Data)
+		}
+	}
+	return
+}
+
+// WidevineKeyIDs returns the key_id fields of a Widevine PSSH data
+// protobuf message.
+func WidevineKeyIDs(data []byte) (kids []KeyID, err error) {
+	const keyIDField = 2
+	for pos := 0; pos < len(data); {
+		var tag, n uint64
+		if tag, pos, err = protobufVarint(data, pos); err != nil {
+			return
+		}
+		switch tag & 7 {
+		case 0:
+			_, pos, err = protobufVarint(data, pos)
+		case 1:
+			pos += 8
+		case 2:
+			if n, pos, err = protobufVarint(data, pos); err == nil && n > uint64(len(data)-pos) {
+				err = fmt.Errorf("truncated protobuf field: %w", ErrInvalidMP4)
+			}
+			if err == nil && tag>>3 == keyIDField && n == 16 {
+				var kid KeyID
+				copy(kid[:], data[pos:])
+				kids = append(kids, kid)
+			}
+			pos += int(n)
+		case 5:
+			pos += 4
+		default:
+			err = fmt.Errorf("invalid protobuf wire type %d: %w", tag&7, ErrInvalidMP4)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if pos > len(data) {
+			return nil, fmt.Errorf("truncated protobuf field: %w", ErrInvalidMP4)
+		}
+	}
+	return
+}
+
+func protobufVarint(data []byte, pos int) (v uint64, next int, err error) {
+	for shift := uint(0); shift < 64; shift += 7 {
+		if pos >= len(data) {
+			break
+		}
+		b := data[pos]
+		pos++
+		v |= uint64(b&0x7F) << shift
+		if b < 0x80 {
+			return v, pos, nil
+		}
+	}
+	return 0, pos, fmt.Errorf("invalid protobuf varint: %w", ErrInvalidMP4)
+}
+
+const playReadyHeaderRecord = 1
+
+// KID elements carry their value as text in WRM headers 4.0, and in the
+// VALUE attribute since 4.1.
+var (
+	playReadyKIDPattern   = regexp.MustCompile(`<KID\b([^>]*)>([^<]*)`)
+	playReadyValuePattern = regexp.MustCompile(`\bVALUE="([^"]*)"`)
+)
+
+// PlayReadyKeyIDs returns the KIDs of the WRM header of a PlayReady Object.
+// PlayReady KIDs are GUIDs, whose first three fields are little-endian, so
+// they are reordered into the big-endian KIDs of Common Encryption.
+func PlayReadyKeyIDs(pro []byte) (kids []KeyID, err error) {
+	if len(pro) < 6 || int(binary.LittleEndian.Uint32(pro)) != len(pro) {
+		return nil, fmt.Errorf("invalid PlayReady Object length: %w", ErrInvalidMP4)
+	}
+	count := int(binary.LittleEndian.Uint16(pro[4:]))
+	pos := 6
+	for i := 0; i < count; i++ {
+		if pos+4 > len(pro) {
+			return nil, fmt.Errorf("truncated PlayReady Object record: %w", ErrInvalidMP4)
+		}
+		typ, length := binary.LittleEndian.Uint16(pro[pos:]), int(binary.LittleEndian.Uint16(pro[pos+2:]))
+		pos += 4
+		if pos+length > len(pro) {
+			return nil, fmt.Errorf("truncated PlayReady Object record: %w", ErrInvalidMP4)
+		}
+		if typ == playReadyHeaderRecord {
+			units := make([]uint16, length/2)
+			for j := range units {
+				units[j] = binary.LittleEndian.Uint16(pro[pos+2*j:])
+			}
+			header := string(utf16.Decode(units))
+			for _, m := range playReadyKIDPattern.FindAllStringSubmatch(header, -1) {
+				value := m[2]
+				if attr := playReadyValuePattern.FindStringSubmatch(m[1]); attr != nil {
+					value = attr[1]
+				}
+				var kid KeyID
+				if kid, err = playReadyKeyID(strings.TrimSpace(value)); err != nil {
+					return nil, err
+				}
+				kids = append(kids, kid)
+			}
+		}
+		pos += length
+	}
+	return
+}
+
+func playReadyKeyID(value string) (kid KeyID, err error) {
+	guid, err := base64.StdEncoding.DecodeString(value)
+	if err != nil || len(guid) != len(kid) {
+		return kid, fmt.Errorf("invalid PlayReady KID %q: %w", value, ErrInvalidMP4)
+	}
+	kid = KeyID{guid[3], guid[2], guid[1], guid[0], guid[5], guid[4], guid[7], guid[6]}
+	copy(kid[8:], guid[8:])
+	return
+}
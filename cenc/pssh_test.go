@@ -0,0 +1,36 @@
+package cenc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePSSH(t *testing.T) {
+	other := KeyID{0x20}
+	data := fullBox("pssh", 1, 0, SystemIDPlayReady[:], u32(2), testKID[:], other[:], u32(3), []byte{1, 2, 3})
+	pssh, err := ParsePSSH(data)
+	if assert.NoError(t, err) {
+		assert.Equal(t, uint8(1), pssh.Version)
+		assert.Equal(t, SystemIDPlayReady, pssh.SystemID)
+		assert.Equal(t, []KeyID{testKID, other}, pssh.KeyIDs)
+		assert.Equal(t, []byte{1, 2, 3}, pssh.Data)
+	}
+	assert.Equal(t, "9a04f079-9840-4286-ab92-e65be0885f95", SystemIDPlayReady.String())
+
+	_, err = ParsePSSH(data[:len(data)-1])
+	assert.ErrorIs(t, err, ErrInvalidMP4)
+	_, err = ParsePSSH(fullBox("pssh", 1, 0, SystemIDPlayReady[:], u32(1000)))
+	assert.ErrorIs(t, err, ErrInvalidMP4)
+}
+
+func TestWidevineKeyIDs(t *testing.T) {
+	data := append([]byte{0x08, 0x01, 0x12, 0x10}, testKID[:]...)
+	data = append(data, 0x22, 0x03, 'a', 'b', 'c', 0x12, 0x10)
+	data = append(data, testKID[:]...)
+	kids, err := WidevineKeyIDs(data)
+	assert.NoError(t, err)
+	assert.Equal(t, []KeyID{testKID, testKID}, kids)
+	_, err = WidevineKeyIDs(data[:len(data)-1])
+	assert.ErrorIs(t, err, ErrInvalidMP4)
+}
@@ -0,0 +1,138 @@
+package codecs
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MPEG-4 object type indications used by mp4a codec strings.
+const (
+	ObjectTypeMPEG4Audio   uint8 = 0x40
+	ObjectTypeMPEG2AACMain uint8 = 0x66
+	ObjectTypeMPEG2AACLC   uint8 = 0x67
+	ObjectTypeMPEG2AACSSR  uint8 = 0x68
+	ObjectTypeMPEG2Audio   uint8 = 0x69
+	ObjectTypeMPEG1Audio   uint8 = 0x6B
+	ObjectTypeAC3          uint8 = 0xA5
+	ObjectTypeEC3          uint8 = 0xA6
+)
+
+// MPEG-4 audio object types used by "mp4a.40.x" codec strings.
+const (
+	AudioObjectTypeAACMain uint8 = 1
+	AudioObjectTypeAACLC   uint8 = 2
+	AudioObjectTypeSBR     uint8 = 5  // HE-AAC
+	AudioObjectTypePS      uint8 = 29 // HE-AACv2
+	AudioObjectTypeMP3     uint8 = 34 // MPEG-1/2 Layer-3
+	AudioObjectTypeUSAC    uint8 = 42 // xHE-AAC
+)
+
+// MP4A holds the parameters of an mp4a codec string, such as "mp4a.40.2".
+type MP4A struct {
+	ObjectType      uint8 // the hexadecimal object type indication, such as 0x40
+	AudioObjectType uint8 // the decimal MPEG-4 audio object type, 0 if absent
+}
+
+func (c *MP4A) parse(params string) (err error) {
+	parts := strings.Split(params, ".")
+	if len(parts) > 2 || parts[0] == "" {
+		return fmt.Errorf("mp4a parameters must have 1 or 2 parts: %w", ErrInvalidCodec)
+	}
+	value, err := strconv.ParseUint(parts[0], 16, 8)
+	if err != nil {
+		return fmt.Errorf("invalid mp4a object type indication: %w", ErrInvalidCodec)
+	}
+	c.ObjectType = uint8(value)
+	if len(parts) == 2 {
+		if c.AudioObjectType, err = parseDecimal(parts[1], "mp4a audio object type"); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (c MP4A) String() string {
+	if c.AudioObjectType == 0 {
+		return fmt.Sprintf("%02x", c.ObjectType)
+	}
+	return fmt.Sprintf("%02x.%d", c.ObjectType, c.AudioObjectType)
+}
+
+// ProfileName returns a human-readable name such as "AAC-LC", "HE-AAC" or
+// "xHE-AAC".
+func (c MP4A) ProfileName() string {
+	switch c.ObjectType {
+	case ObjectTypeMPEG4Audio:
+		switch c.AudioObjectType {
+		case AudioObjectTypeAACMain:
+			return "AAC Main"
+		case AudioObjectTypeAACLC:
+			return "AAC-LC"
+		case AudioObjectTypeSBR:
+			return "HE-AAC"
+		case AudioObjectTypePS:
+			return "HE-AACv2"
+		case AudioObjectTypeMP3:
+			return "MP3"
+		case AudioObjectTypeUSAC:
+			return "xHE-AAC"
+		}
+		return fmt.Sprintf("MPEG-4 Audio Object Type %d", c.AudioObjectType)
+	case ObjectTypeMPEG2AACMain:
+		return "AAC Main"
+	case ObjectTypeMPEG2AACLC:
+		return "AAC-LC"
+	case ObjectTypeMPEG2AACSSR:
+		return "AAC SSR"
+	case ObjectTypeMPEG2Audio, ObjectTypeMPEG1Audio:
+		return "MP3"
+	case ObjectTypeAC3:
+		return "AC-3"
+	case ObjectTypeEC3:
+		return "E-AC-3"
+	}
+	return fmt.Sprintf("Object Type 0x%02x", c.ObjectType)
+}
+
+// IsAAC reports whether the codec is any of the AAC family, including HE-AAC
+// and xHE-AAC.
+func (c MP4A) IsAAC() bool {
+	switch c.ObjectType {
+	case ObjectTypeMPEG4Audio:
+		switch c.AudioObjectType {
+		case AudioObjectTypeAACMain, AudioObjectTypeAACLC, AudioObjectTypeSBR, AudioObjectTypePS, AudioObjectTypeUSAC:
+			return true
+		}
+	case ObjectTypeMPEG2AACMain, ObjectTypeMPEG2AACLC, ObjectTypeMPEG2AACSSR:
+		return true
+	}
+	return false
+}
+
+// AC4 holds the parameters of an AC-4 codec string, such as "ac-4.02.01.01".
+type AC4 struct {
+	BitstreamVersion    uint8
+	PresentationVersion uint8
+	MDCompat            uint8 // the presentation level
+}
+
+func (c *AC4) parse(params string) (err error) {
+	parts := strings.Split(params, ".")
+	if len(parts) != 3 {
+		return fmt.Errorf("AC-4 parameters must have 3 parts: %w", ErrInvalidCodec)
+	}
+	values := []*uint8{&c.BitstreamVersion, &c.PresentationVersion, &c.MDCompat}
+	for i, part := range parts {
+		value, e := strconv.ParseUint(part, 16, 8)
+		if e != nil {
+			return fmt.Errorf("invalid AC-4 parameter %q: %w", part, ErrInvalidCodec)
+		}
+		*values[i] = uint8(value)
+	}
+	return
+}
+
+func (c AC4) String() string {
+	return fmt.Sprintf("%02x.%02x.%02x", c.BitstreamVersion, c.PresentationVersion, c.MDCompat)
+}
@@ -0,0 +1,232 @@
+// Package codecs parses and formats the RFC 6381 codec strings carried by the
+// CODECS and SUPPLEMENTAL-CODECS attributes of HLS playlists.
+package codecs
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrInvalidCodec = errors.New("invalid RFC 6381 codec string")
+
+// Kind classifies the media sample type of a codec.
+type Kind int
+
+const (
+	Unknown Kind = iota
+	Video
+	Audio
+	Text
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Video:
+		return "video"
+	case Audio:
+		return "audio"
+	case Text:
+		return "text"
+	}
+	return "unknown"
+}
+
+// Sample entry codes of the recognized codecs.
+const (
+	FourCCAVC1 = "avc1"
+	FourCCAVC3 = "avc3"
+	FourCCHVC1 = "hvc1"
+	FourCCHEV1 = "hev1"
+	FourCCDVH1 = "dvh1"
+	FourCCDVHE = "dvhe"
+	FourCCDVA1 = "dva1"
+	FourCCDVAV = "dvav"
+	FourCCDAV1 = "dav1"
+	FourCCAV01 = "av01"
+	FourCCVP09 = "vp09"
+	FourCCMP4A = "mp4a"
+	FourCCAC3  = "ac-3"
+	FourCCEC3  = "ec-3"
+	FourCCAC4  = "ac-4"
+	FourCCOpus = "Opus"
+	FourCCFLAC = "fLaC"
+	FourCCSTPP = "stpp"
+	FourCCWVTT = "wvtt"
+)
+
+var fourCCKinds = map[string]Kind{
+	FourCCAVC1: Video,
+	FourCCAVC3: Video,
+	FourCCHVC1: Video,
+	FourCCHEV1: Video,
+	FourCCDVH1: Video,
+	FourCCDVHE: Video,
+	FourCCDVA1: Video,
+	FourCCDVAV: Video,
+	FourCCDAV1: Video,
+	FourCCAV01: Video,
+	FourCCVP09: Video,
+	"vp08":     Video,
+	"mp4v":     Video,
+	FourCCMP4A: Audio,
+	FourCCAC3:  Audio,
+	FourCCEC3:  Audio,
+	FourCCAC4:  Audio,
+	FourCCOpus: Audio,
+	FourCCFLAC: Audio,
+	"mhm1":     Audio,
+	"mha1":     Audio,
+	FourCCSTPP: Text,
+	FourCCWVTT: Text,
+	"tx3g":     Text,
+	"c608":     Text,
+}
+
+// Codec is a single entry of a codecs list. The typed field matching FourCC
+// is set for the codecs whose parameters are understood; the others only
+// carry FourCC and Params.
+type Codec struct {
+	FourCC string // sample entry code, such as "avc1"
+	Params string // the text after the first ".", such as "64001f"
+
+	AVC         *AVC         // avc1, avc3
+	HEVC        *HEVC        // hvc1, hev1
+	DolbyVision *DolbyVision // dvh1, dvhe, dva1, dvav, dav1
+	AV1         *AV1         // av01
+	VP9         *VP9         // vp09
+	MP4A        *MP4A        // mp4a
+	AC4         *AC4         // ac-4
+}
+
+// Parse parses a single codec such as "avc1.64001f". Unknown sample entry
+// codes are accepted and classified as Unknown.
+func Parse(s string) (c Codec, err error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		err = fmt.Errorf("empty codec: %w", ErrInvalidCodec)
+		return
+	}
+	c.FourCC = s
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		c.FourCC, c.Params = s[:i], s[i+1:]
+	}
+	switch c.canonicalFourCC() {
+	case FourCCAVC1, FourCCAVC3:
+		c.AVC = &AVC{}
+		err = c.AVC.parse(c.Params)
+	case FourCCHVC1, FourCCHEV1:
+		c.HEVC = &HEVC{}
+		err = c.HEVC.parse(c.Params)
+	case FourCCDVH1, FourCCDVHE, FourCCDVA1, FourCCDVAV, FourCCDAV1:
+		c.DolbyVision = &DolbyVision{}
+		err = c.DolbyVision.parse(c.Params)
+	case FourCCAV01:
+		c.AV1 = &AV1{}
+		err = c.AV1.parse(c.Params)
+	case FourCCVP09:
+		c.VP9 = &VP9{}
+		err = c.VP9.parse(c.Params)
+	case FourCCMP4A:
+		c.MP4A = &MP4A{}
+		err = c.MP4A.parse(c.Params)
+	case FourCCAC4:
+		c.AC4 = &AC4{}
+		err = c.AC4.parse(c.Params)
+	}
+	if err != nil {
+		err = fmt.Errorf("%s: %w", s, err)
+	}
+	return
+}
+
+// canonicalFourCC folds the case of sample entry codes that are commonly
+// written in a different case than registered, such as "opus" and "flac".
+func (c Codec) canonicalFourCC() string {
+	switch strings.ToLower(c.FourCC) {
+	case "opus":
+		return FourCCOpus
+	case "flac":
+		return FourCCFLAC
+	}
+	return c.FourCC
+}
+
+func (c Codec) Kind() Kind {
+	if c.MP4A != nil {
+		return Audio
+	}
+	return fourCCKinds[c.canonicalFourCC()]
+}
+
+// String formats the codec from its typed parameters, so a Codec built by
+// hand produces a valid codec string.
+func (c Codec) String() string {
+	switch {
+	case c.AVC != nil:
+		return c.FourCC + "." + c.AVC.String()
+	case c.HEVC != nil:
+		return c.FourCC + "." + c.HEVC.String()
+	case c.DolbyVision != nil:
+		return c.FourCC + "." + c.DolbyVision.String()
+	case c.AV1 != nil:
+		return c.FourCC + "." + c.AV1.String()
+	case c.VP9 != nil:
+		return c.FourCC + "." + c.VP9.String()
+	case c.MP4A != nil:
+		return c.FourCC + "." + c.MP4A.String()
+	case c.AC4 != nil:
+		return c.FourCC + "." + c.AC4.String()
+	case c.Params != "":
+		return c.FourCC + "." + c.Params
+	}
+	return c.FourCC
+}
+
+// List is the parsed value of a CODECS attribute.
+type List []Codec
+
+// ParseList parses a comma-separated list of codecs, such as the value of the
+// CODECS attribute.
+func ParseList(s string) (list List, err error) {
+	for _, part := range strings.Split(s, ",") {
+		var c Codec
+		if c, err = Parse(part); err != nil {
+			return nil, err
+		}
+		list = append(list, c)
+	}
+	return
+}
+
+func (list List) String() string {
+	strs := make([]string, len(list))
+	for i, c := range list {
+		strs[i] = c.String()
+	}
+	return strings.Join(strs, ",")
+}
+
+// Filter returns the codecs of the given kind.
+func (list List) Filter(kind Kind) (filtered List) {
+	for _, c := range list {
+		if c.Kind() == kind {
+			filtered = append(filtered, c)
+		}
+	}
+	return
+}
+
+func (list List) Has(kind Kind) bool {
+	for _, c := range list {
+		if c.Kind() == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// Format formats a codecs list, the reverse of ParseList.
+func Format(codecs ...Codec) string {
+	return List(codecs).String()
+}
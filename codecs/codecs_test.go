@@ -0,0 +1,53 @@
+package codecs
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseList(t *testing.T) {
+	list, err := ParseList("avc1.64001F, hvc1.2.4.L153.B0,dvh1.08.07,av01.0.04M.10.0.112.09.16.09.0,vp09.02.10.10.01.09.16.09.01,mp4a.40.5,ec-3,ac-4.02.01.01,fLaC,Opus,stpp.ttml.im1t,wvtt,xyz1.2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, &AVC{Profile: 100, Constraints: 0, Level: 31}, list[0].AVC)
+	assert.Equal(t, "High", list[0].AVC.ProfileName())
+	assert.Equal(t, 3.1, list[0].AVC.LevelNumber())
+
+	hevc := list[1].HEVC
+	assert.EqualValues(t, 2, hevc.Profile)
+	assert.EqualValues(t, 1<<2, hevc.CompatibilityFlags&0xFF)
+	assert.False(t, hevc.HighTier)
+	assert.EqualValues(t, 153, hevc.Level)
+	assert.Equal(t, [6]uint8{0xB0}, hevc.Constraints)
+
+	assert.Equal(t, &DolbyVision{Profile: 8, Level: 7}, list[2].DolbyVision)
+	assert.True(t, list[3].AV1.HasColorInfo)
+	assert.EqualValues(t, 10, list[3].AV1.BitDepth)
+	assert.EqualValues(t, 16, list[4].VP9.TransferCharacteristics)
+	assert.Equal(t, "HE-AAC", list[5].MP4A.ProfileName())
+	assert.Equal(t, &AC4{2, 1, 1}, list[7].AC4)
+
+	kinds := []Kind{Video, Video, Video, Video, Video, Audio, Audio, Audio, Audio, Audio, Text, Text, Unknown}
+	for i, c := range list {
+		assert.Equal(t, kinds[i], c.Kind(), c.String())
+	}
+	assert.Len(t, list.Filter(Audio), 5)
+	assert.Equal(t, "avc1.64001f,hvc1.2.4.L153.B0,dvh1.08.07,av01.0.04M.10.0.112.09.16.09.0,vp09.02.10.10.01.09.16.09.01,mp4a.40.5,ec-3,ac-4.02.01.01,fLaC,Opus,stpp.ttml.im1t,wvtt,xyz1.2", list.String())
+}
+
+func TestFormat(t *testing.T) {
+	assert.Equal(t, "avc1.4d401e,mp4a.40.2", Format(
+		Codec{FourCC: FourCCAVC1, AVC: &AVC{Profile: 77, Constraints: 0x40, Level: 30}},
+		Codec{FourCC: FourCCMP4A, MP4A: &MP4A{ObjectType: ObjectTypeMPEG4Audio, AudioObjectType: AudioObjectTypeAACLC}},
+	))
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, s := range []string{"", "avc1.6400", "hvc1.1.6", "dvh1.5", "av01.0.04X.10", "mp4a.zz"} {
+		_, err := Parse(s)
+		assert.True(t, errors.Is(err, ErrInvalidCodec), s)
+	}
+}
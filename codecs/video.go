@@ -0,0 +1,348 @@
+package codecs
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// AVC holds the profile_idc, constraint flags and level_idc of an H.264
+// codec string, either in the "PPCCLL" hexadecimal form or in the legacy
+// "profile.level" decimal form.
+type AVC struct {
+	Profile     uint8 // profile_idc, such as 100 for High
+	Constraints uint8 // the byte holding constraint_set0_flag to constraint_set5_flag
+	Level       uint8 // level_idc, ten times the level number
+}
+
+func (c *AVC) parse(params string) (err error) {
+	if dot := strings.IndexByte(params, '.'); dot >= 0 {
+		var profile, level uint64
+		if profile, err = strconv.ParseUint(params[:dot], 10, 8); err != nil {
+			return fmt.Errorf("invalid AVC profile: %w", ErrInvalidCodec)
+		}
+		if level, err = strconv.ParseUint(params[dot+1:], 10, 8); err != nil {
+			return fmt.Errorf("invalid AVC level: %w", ErrInvalidCodec)
+		}
+		c.Profile, c.Level = uint8(profile), uint8(level)
+		return
+	}
+	if len(params) != 6 {
+		return fmt.Errorf("AVC parameters must be 6 hexadecimal digits: %w", ErrInvalidCodec)
+	}
+	value, err := strconv.ParseUint(params, 16, 32)
+	if err != nil {
+		return fmt.Errorf("invalid AVC parameters: %w", ErrInvalidCodec)
+	}
+	c.Profile, c.Constraints, c.Level = uint8(value>>16), uint8(value>>8), uint8(value)
+	return
+}
+
+func (c AVC) String() string {
+	return fmt.Sprintf("%02x%02x%02x", c.Profile, c.Constraints, c.Level)
+}
+
+func (c AVC) ProfileName() string {
+	switch c.Profile {
+	case 66:
+		if c.Constraints&0x40 != 0 {
+			return "Constrained Baseline"
+		}
+		return "Baseline"
+	case 77:
+		return "Main"
+	case 88:
+		return "Extended"
+	case 100:
+		return "High"
+	case 110:
+		return "High 10"
+	case 122:
+		return "High 4:2:2"
+	case 244:
+		return "High 4:4:4 Predictive"
+	}
+	return fmt.Sprintf("Profile %d", c.Profile)
+}
+
+// LevelNumber returns the level as written in the specification, such as 3.1.
+func (c AVC) LevelNumber() float64 {
+	return float64(c.Level) / 10
+}
+
+// HEVC holds the parameters of an H.265 codec string as defined by ISO/IEC
+// 14496-15 Annex E, such as "hvc1.2.4.L153.B0".
+type HEVC struct {
+	ProfileSpace       uint8    // general_profile_space, 0 to 3, written as no prefix or A, B, C
+	Profile            uint8    // general_profile_idc, such as 2 for Main 10
+	CompatibilityFlags uint32   // bit j holds general_profile_compatibility_flag[j], the reverse of the bitstream order
+	HighTier           bool     // general_tier_flag
+	Level              uint8    // general_level_idc, thirty times the level number
+	Constraints        [6]uint8 // the six bytes of general constraint indicator flags
+}
+
+func (c *HEVC) parse(params string) (err error) {
+	parts := strings.Split(params, ".")
+	if len(parts) < 3 || len(parts) > 9 {
+		return fmt.Errorf("HEVC parameters must have 3 to 9 parts: %w", ErrInvalidCodec)
+	}
+
+	profile := parts[0]
+	if profile != "" && profile[0] >= 'A' && profile[0] <= 'C' {
+		c.ProfileSpace = profile[0] - 'A' + 1
+		profile = profile[1:]
+	}
+	value, err := strconv.ParseUint(profile, 10, 8)
+	if err != nil {
+		return fmt.Errorf("invalid HEVC profile: %w", ErrInvalidCodec)
+	}
+	c.Profile = uint8(value)
+
+	if value, err = strconv.ParseUint(parts[1], 16, 32); err != nil {
+		return fmt.Errorf("invalid HEVC compatibility flags: %w", ErrInvalidCodec)
+	}
+	c.CompatibilityFlags = uint32(value)
+
+	tier := parts[2]
+	if tier == "" || (tier[0] != 'L' && tier[0] != 'H') {
+		return fmt.Errorf("invalid HEVC tier: %w", ErrInvalidCodec)
+	}
+	c.HighTier = tier[0] == 'H'
+	if value, err = strconv.ParseUint(tier[1:], 10, 8); err != nil {
+		return fmt.Errorf("invalid HEVC level: %w", ErrInvalidCodec)
+	}
+	c.Level = uint8(value)
+
+	for i, constraint := range parts[3:] {
+		if value, err = strconv.ParseUint(constraint, 16, 8); err != nil {
+			return fmt.Errorf("invalid HEVC constraint flags: %w", ErrInvalidCodec)
+		}
+		c.Constraints[i] = uint8(value)
+	}
+	return
+}
+
+func (c HEVC) String() string {
+	var b strings.Builder
+	if c.ProfileSpace > 0 {
+		b.WriteByte('A' + c.ProfileSpace - 1)
+	}
+	fmt.Fprintf(&b, "%d.%X.", c.Profile, c.CompatibilityFlags)
+	if c.HighTier {
+		b.WriteByte('H')
+	} else {
+		b.WriteByte('L')
+	}
+	fmt.Fprintf(&b, "%d", c.Level)
+	// trailing bytes that are zero are omitted
+	last := len(c.Constraints)
+	for last > 0 && c.Constraints[last-1] == 0 {
+		last--
+	}
+	for _, constraint := range c.Constraints[:last] {
+		fmt.Fprintf(&b, ".%X", constraint)
+	}
+	return b.String()
+}
+
+func (c HEVC) ProfileName() string {
+	switch c.Profile {
+	case 1:
+		return "Main"
+	case 2:
+		return "Main 10"
+	case 3:
+		return "Main Still Picture"
+	case 4:
+		return "Range Extensions"
+	}
+	return fmt.Sprintf("Profile %d", c.Profile)
+}
+
+// LevelNumber returns the level as written in the specification, such as 5.1.
+func (c HEVC) LevelNumber() float64 {
+	return float64(c.Level) / 30
+}
+
+// DolbyVision holds the parameters of a Dolby Vision codec string, such as
+// "dvh1.05.06".
+type DolbyVision struct {
+	Profile uint8 // bitstream profile, such as 5 or 8
+	Level   uint8
+}
+
+func (c *DolbyVision) parse(params string) (err error) {
+	parts := strings.Split(params, ".")
+	if len(parts) != 2 {
+		return fmt.Errorf("Dolby Vision parameters must have 2 parts: %w", ErrInvalidCodec)
+	}
+	profile, err := strconv.ParseUint(parts[0], 10, 8)
+	if err != nil {
+		return fmt.Errorf("invalid Dolby Vision profile: %w", ErrInvalidCodec)
+	}
+	level, err := strconv.ParseUint(parts[1], 10, 8)
+	if err != nil {
+		return fmt.Errorf("invalid Dolby Vision level: %w", ErrInvalidCodec)
+	}
+	c.Profile, c.Level = uint8(profile), uint8(level)
+	return
+}
+
+func (c DolbyVision) String() string {
+	return fmt.Sprintf("%02d.%02d", c.Profile, c.Level)
+}
+
+// AV1 holds the parameters of an AV1 codec string as defined by the AV1
+// Codec ISO Media File Format Binding, such as "av01.0.04M.10.0.112.09.16.09.0".
+type AV1 struct {
+	Profile  uint8 // seq_profile
+	Level    uint8 // seq_level_idx
+	HighTier bool  // seq_tier
+	BitDepth uint8
+
+	HasColorInfo            bool   // whether the optional fields below are present
+	Monochrome              bool   // mono_chrome
+	ChromaSubsampling       string // subsampling_x, subsampling_y and chroma_sample_position digits, such as "110"
+	ColorPrimaries          uint8
+	TransferCharacteristics uint8
+	MatrixCoefficients      uint8
+	VideoFullRange          bool
+}
+
+func (c *AV1) parse(params string) (err error) {
+	parts := strings.Split(params, ".")
+	if len(parts) != 3 && len(parts) != 9 {
+		return fmt.Errorf("AV1 parameters must have 3 or 9 parts: %w", ErrInvalidCodec)
+	}
+	if c.Profile, err = parseDecimal(parts[0], "AV1 profile"); err != nil {
+		return
+	}
+	levelTier := parts[1]
+	if len(levelTier) != 3 || (levelTier[2] != 'M' && levelTier[2] != 'H') {
+		return fmt.Errorf("invalid AV1 level and tier: %w", ErrInvalidCodec)
+	}
+	if c.Level, err = parseDecimal(levelTier[:2], "AV1 level"); err != nil {
+		return
+	}
+	c.HighTier = levelTier[2] == 'H'
+	if c.BitDepth, err = parseDecimal(parts[2], "AV1 bit depth"); err != nil {
+		return
+	}
+	if len(parts) == 3 {
+		return
+	}
+	c.HasColorInfo = true
+	var flag uint8
+	if flag, err = parseDecimal(parts[3], "AV1 monochrome flag"); err != nil {
+		return
+	}
+	c.Monochrome = flag == 1
+	if len(parts[4]) != 3 {
+		return fmt.Errorf("invalid AV1 chroma subsampling: %w", ErrInvalidCodec)
+	}
+	c.ChromaSubsampling = parts[4]
+	if c.ColorPrimaries, err = parseDecimal(parts[5], "AV1 color primaries"); err != nil {
+		return
+	}
+	if c.TransferCharacteristics, err = parseDecimal(parts[6], "AV1 transfer characteristics"); err != nil {
+		return
+	}
+	if c.MatrixCoefficients, err = parseDecimal(parts[7], "AV1 matrix coefficients"); err != nil {
+		return
+	}
+	if flag, err = parseDecimal(parts[8], "AV1 full range flag"); err != nil {
+		return
+	}
+	c.VideoFullRange = flag == 1
+	return
+}
+
+func (c AV1) String() string {
+	tier := 'M'
+	if c.HighTier {
+		tier = 'H'
+	}
+	s := fmt.Sprintf("%d.%02d%c.%02d", c.Profile, c.Level, tier, c.BitDepth)
+	if c.HasColorInfo {
+		s += fmt.Sprintf(".%d.%s.%02d.%02d.%02d.%d", boolDigit(c.Monochrome), c.ChromaSubsampling,
+			c.ColorPrimaries, c.TransferCharacteristics, c.MatrixCoefficients, boolDigit(c.VideoFullRange))
+	}
+	return s
+}
+
+// VP9 holds the parameters of a VP9 codec string as defined by the VP Codec
+// ISO Media File Format Binding, such as "vp09.02.10.10.01.09.16.09.01".
+type VP9 struct {
+	Profile  uint8
+	Level    uint8 // ten times the level number
+	BitDepth uint8
+
+	HasColorInfo            bool // whether the optional fields below are present
+	ChromaSubsampling       uint8
+	ColorPrimaries          uint8
+	TransferCharacteristics uint8
+	MatrixCoefficients      uint8
+	VideoFullRange          bool
+}
+
+func (c *VP9) parse(params string) (err error) {
+	parts := strings.Split(params, ".")
+	if len(parts) < 3 || len(parts) > 8 {
+		return fmt.Errorf("VP9 parameters must have 3 to 8 parts: %w", ErrInvalidCodec)
+	}
+	if c.Profile, err = parseDecimal(parts[0], "VP9 profile"); err != nil {
+		return
+	}
+	if c.Level, err = parseDecimal(parts[1], "VP9 level"); err != nil {
+		return
+	}
+	if c.BitDepth, err = parseDecimal(parts[2], "VP9 bit depth"); err != nil {
+		return
+	}
+	if len(parts) == 3 {
+		return
+	}
+	// omitted trailing fields take their default values
+	c.HasColorInfo = true
+	c.ChromaSubsampling, c.ColorPrimaries, c.TransferCharacteristics, c.MatrixCoefficients = 1, 1, 1, 1
+	fields := []*uint8{&c.ChromaSubsampling, &c.ColorPrimaries, &c.TransferCharacteristics, &c.MatrixCoefficients}
+	for i, part := range parts[3:] {
+		if i == len(fields) {
+			var flag uint8
+			if flag, err = parseDecimal(part, "VP9 full range flag"); err != nil {
+				return
+			}
+			c.VideoFullRange = flag == 1
+			break
+		}
+		if *fields[i], err = parseDecimal(part, "VP9 color parameter"); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (c VP9) String() string {
+	s := fmt.Sprintf("%02d.%02d.%02d", c.Profile, c.Level, c.BitDepth)
+	if c.HasColorInfo {
+		s += fmt.Sprintf(".%02d.%02d.%02d.%02d.%02d", c.ChromaSubsampling, c.ColorPrimaries,
+			c.TransferCharacteristics, c.MatrixCoefficients, boolDigit(c.VideoFullRange))
+	}
+	return s
+}
+
+func parseDecimal(s string, what string) (value uint8, err error) {
+	v, e := strconv.ParseUint(s, 10, 8)
+	if e != nil {
+		err = fmt.Errorf("invalid %s %q: %w", what, s, ErrInvalidCodec)
+		return
+	}
+	return uint8(v), nil
+}
+
+func boolDigit(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,188 @@
+package hls
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+type DateRange struct {
+	Tag              *Tag
+	ID               string         // [REQUIRED] uniquely identifies a Date Range in the Playlist
+	Class            *string        // [OPTIONAL] client-defined string that specifies some set of attributes and their associated value semantics
+	StartDate        time.Time      // [REQUIRED] date/time at which the Date Range begins
+	Cue              []string       // [OPTIONAL] when to trigger an action associated with the Date Range, valid values are PRE, POST and ONCE
+	EndDate          *time.Time     // [OPTIONAL] date/time at which the Date Range ends
+	Duration         *time.Duration // [OPTIONAL] the duration of the Date Range
+	PlannedDuration  *time.Duration // [OPTIONAL] the expected duration of the Date Range
+	ClientAttributes []*Attribute   // [OPTIONAL] the X-<client-attribute> attributes
+	SCTE35Cmd        []byte         // [OPTIONAL] SCTE-35 splice_info_section carrying a command other than splice_insert
+	SCTE35Out        []byte         // [OPTIONAL] SCTE-35 splice_info_section carrying a splice out
+	SCTE35In         []byte         // [OPTIONAL] SCTE-35 splice_info_section carrying a splice in
+	EndOnNext        bool           // [OPTIONAL][DEFAULT=false] indicates that the end of the range is the start of the next range with the same CLASS
+}
+
+// dateTimeLayouts are the ISO/IEC 8601:2004 layouts accepted for date/time
+// values. Playlists in the wild omit the colon in the time zone offset.
+var dateTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999Z0700",
+}
+
+func parseDateTime(value string) (t time.Time, err error) {
+	for _, layout := range dateTimeLayouts {
+		if t, err = time.Parse(layout, value); err == nil {
+			return
+		}
+	}
+	err = fmt.Errorf("invalid ISO 8601 date/time: %s: %w", value, ErrFormat)
+	return
+}
+
+func parseSeconds(seconds float64) (d time.Duration, err error) {
+	if seconds < 0 {
+		err = fmt.Errorf("negative duration: %w", ErrFormat)
+		return
+	}
+	if !(seconds < math.MaxInt64/float64(time.Second)) {
+		err = fmt.Errorf("duration of %g seconds is out of range: %w", seconds, ErrFormat)
+		return
+	}
+	d = time.Duration(seconds * float64(time.Second))
+	return
+}
+
+func (r *DateRange) ParseTag(tag *Tag) (err error) {
+	if tag.Name != "EXT-X-DATERANGE" {
+		err = fmt.Errorf("parsing date range using the wrong tag: %s: %w", tag.Name, ErrFormat)
+		return
+	}
+	r.Tag = tag
+	if _, err = tag.ParseAttributeList(); err != nil {
+		err = fmt.Errorf("failed parsing date range attribute list: %w", err)
+		return
+	}
+	return r.ParseAttributeList(tag.AttributeList)
+}
+
+func (r *DateRange) ParseAttributeList(attrs *AttributeList) (err error) {
+	if attr := attrs.GetLast("ID"); attr == nil {
+		err = fmt.Errorf("%s tag is missing ID attribute: %w", r.Tag.Name, ErrFormat)
+		return
+	} else {
+		if r.ID, err = attr.String(); err != nil {
+			err = fmt.Errorf("failed getting ID attribute: %w", err)
+			return
+		}
+	}
+	if attr := attrs.GetLast("CLASS"); attr != nil {
+		if r.Class, err = attr.StringPtr(); err != nil {
+			err = fmt.Errorf("failed getting CLASS attribute: %w", err)
+			return
+		}
+	}
+	if attr := attrs.GetLast("START-DATE"); attr == nil {
+		err = fmt.Errorf("%s tag is missing START-DATE attribute: %w", r.Tag.Name, ErrFormat)
+		return
+	} else {
+		var value string
+		if value, err = attr.String(); err != nil {
+			err = fmt.Errorf("failed getting START-DATE attribute: %w", err)
+			return
+		}
+		if r.StartDate, err = parseDateTime(value); err != nil {
+			err = fmt.Errorf("failed parsing START-DATE attribute: %w", err)
+			return
+		}
+	}
+	if attr := attrs.GetLast("CUE"); attr != nil {
+		var value string
+		if value, err = attr.String(); err != nil {
+			err = fmt.Errorf("failed getting CUE attribute: %w", err)
+			return
+		}
+		r.Cue = strings.Split(value, ",")
+	}
+	if attr := attrs.GetLast("END-DATE"); attr != nil {
+		var value string
+		if value, err = attr.String(); err != nil {
+			err = fmt.Errorf("failed getting END-DATE attribute: %w", err)
+			return
+		}
+		var endDate time.Time
+		if endDate, err = parseDateTime(value); err != nil {
+			err = fmt.Errorf("failed parsing END-DATE attribute: %w", err)
+			return
+		}
+		r.EndDate = &endDate
+	}
+	if attr := attrs.GetLast("DURATION"); attr != nil {
+		var value float64
+		if value, err = attr.Number(); err != nil {
+			err = fmt.Errorf("failed getting DURATION attribute: %w", err)
+			return
+		}
+		var duration time.Duration
+		if duration, err = parseSeconds(value); err != nil {
+			err = fmt.Errorf("failed parsing DURATION attribute: %w", err)
+			return
+		}
+		r.Duration = &duration
+	}
+	if attr := attrs.GetLast("PLANNED-DURATION"); attr != nil {
+		var value float64
+		if value, err = attr.Number(); err != nil {
+			err = fmt.Errorf("failed getting PLANNED-DURATION attribute: %w", err)
+			return
+		}
+		var duration time.Duration
+		if duration, err = parseSeconds(value); err != nil {
+			err = fmt.Errorf("failed parsing PLANNED-DURATION attribute: %w", err)
+			return
+		}
+		r.PlannedDuration = &duration
+	}
+	if attr := attrs.GetLast("SCTE35-CMD"); attr != nil {
+		if r.SCTE35Cmd, err = attr.Bytes(); err != nil {
+			err = fmt.Errorf("failed getting SCTE35-CMD attribute: %w", err)
+			return
+		}
+	}
+	if attr := attrs.GetLast("SCTE35-OUT"); attr != nil {
+		if r.SCTE35Out, err = attr.Bytes(); err != nil {
+			err = fmt.Errorf("failed getting SCTE35-OUT attribute: %w", err)
+			return
+		}
+	}
+	if attr := attrs.GetLast("SCTE35-IN"); attr != nil {
+		if r.SCTE35In, err = attr.Bytes(); err != nil {
+			err = fmt.Errorf("failed getting SCTE35-IN attribute: %w", err)
+			return
+		}
+	}
+	if attr := attrs.GetLast("END-ON-NEXT"); attr != nil {
+		if r.EndOnNext, err = attr.YesNo(); err != nil {
+			err = fmt.Errorf("failed getting END-ON-NEXT attribute: %w", err)
+			return
+		}
+	}
+	for _, attr := range attrs.List() {
+		if strings.HasPrefix(attr.Name, "X-") {
+			r.ClientAttributes = append(r.ClientAttributes, attr)
+		}
+	}
+	return
+}
+
+// End returns the end date of the range, derived from END-DATE or DURATION.
+func (r *DateRange) End() *time.Time {
+	if r.EndDate != nil {
+		return r.EndDate
+	}
+	if r.Duration != nil {
+		end := r.StartDate.Add(*r.Duration)
+		return &end
+	}
+	return nil
+}
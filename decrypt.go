@@ -0,0 +1,203 @@
+package hls
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/go-webdl/hls/sampleaes"
+)
+
+// tsSyncByte starts every MPEG-TS packet.
+const tsSyncByte = 0x47
+
+// IsIdentity reports whether the key is in the identity format, the key
+// itself rather than a DRM system specific object.
+func (k *Key) IsIdentity() bool {
+	return k.KeyFormat == nil || *k.KeyFormat == "identity"
+}
+
+// KeyProvider obtains the key of an EXT-X-KEY tag.
+type KeyProvider interface {
+	Key(ctx context.Context, key *Key) ([]byte, error)
+}
+
+// HTTPKeyProvider fetches keys from their URI over HTTP. Keys are not
+// cached, see CachingKeyProvider.
+type HTTPKeyProvider struct {
+	Client     Doer                                                     // [OPTIONAL][DEFAULT=http.DefaultClient]
+	Header     http.Header                                              // [OPTIONAL] added to every request
+	HeaderFunc func(ctx context.Context, key *Key) (http.Header, error) // [OPTIONAL] called for every request, its headers being added to Header, such as short-lived authorization tokens
+}
+
+func (p *HTTPKeyProvider) Key(ctx context.Context, key *Key) (data []byte, err error) {
+	if key.URI == nil {
+		err = fmt.Errorf("%s key has no URI: %w", key.Method, ErrFormat)
+		return
+	}
+	header := p.Header
+	if p.HeaderFunc != nil {
+		var extra http.Header
+		if extra, err = p.HeaderFunc(ctx, key); err != nil {
+			return
+		}
+		header = header.Clone()
+		if header == nil {
+			header = make(http.Header)
+		}
+		for name, values := range extra {
+			header[name] = append(header[name], values...)
+		}
+	}
+	if data, err = ReadResource(ctx, p.Client, header, key.URI, nil); err != nil {
+		return
+	}
+	if key.Method == KeyMethodAES128 && len(data) != aes.BlockSize {
+		err = fmt.Errorf("key from %s has %d bytes: %w", key.URI, len(data), ErrInvalidKeyLength)
+	}
+	return
+}
+
+// SegmentIV writes the IV to use for the segment with the given Media
+// Sequence Number into iv, which must be 16 bytes: the IV attribute if
+// present, otherwise the Media Sequence Number as a big-endian 128-bit
+// integer.
+func (k *Key) SegmentIV(mediaSequence uint64, iv []byte) (err error) {
+	if len(iv) != aes.BlockSize {
+		return ErrInvalidIVLength
+	}
+	if k.IV != nil {
+		if len(k.IV) != aes.BlockSize {
+			return fmt.Errorf("IV attribute has %d bytes: %w", len(k.IV), ErrInvalidIVLength)
+		}
+		copy(iv, k.IV)
+		return
+	}
+	binary.BigEndian.PutUint64(iv[:8], 0)
+	binary.BigEndian.PutUint64(iv[8:], mediaSequence)
+	return
+}
+
+// aes128Reader decrypts AES-128 CBC ciphertext, holding back the last block
+// until EOF so that its PKCS#7 padding can be removed.
+type aes128Reader struct {
+	src    io.Reader
+	mode   cipher.BlockMode
+	cipher []byte // ciphertext read but not decrypted
+	plain  []byte
+	out    []byte // plaintext not yet returned
+	total  int
+	err    error
+}
+
+const aes128ReaderBufferSize = 32 * 1024
+
+// NewAES128Reader returns a reader decrypting the AES-128 CBC ciphertext read
+// from r and removing its PKCS#7 padding. The key and the IV must be 16
+// bytes.
+func NewAES128Reader(r io.Reader, key, iv []byte) (io.Reader, error) {
+	if len(key) != aes.BlockSize {
+		return nil, ErrInvalidKeyLength
+	}
+	if len(iv) != aes.BlockSize {
+		return nil, ErrInvalidIVLength
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return &aes128Reader{
+		src:    r,
+		mode:   cipher.NewCBCDecrypter(block, iv),
+		cipher: make([]byte, 0, aes128ReaderBufferSize+aes.BlockSize),
+		plain:  make([]byte, aes128ReaderBufferSize+aes.BlockSize),
+	}, nil
+}
+
+func (r *aes128Reader) Read(p []byte) (n int, err error) {
+	for len(r.out) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		r.fill()
+	}
+	n = copy(p, r.out)
+	r.out = r.out[n:]
+	return
+}
+
+func (r *aes128Reader) fill() {
+	n, err := r.src.Read(r.cipher[len(r.cipher):cap(r.cipher)])
+	r.cipher = r.cipher[:len(r.cipher)+n]
+	r.total += n
+	if err != nil && err != io.EOF {
+		r.err = err
+		return
+	}
+	eof := err == io.EOF
+	decryptable := len(r.cipher) / aes.BlockSize * aes.BlockSize
+	if eof {
+		if decryptable != len(r.cipher) || r.total == 0 {
+			r.err = fmt.Errorf("ciphertext of %d bytes is not a multiple of the block size: %w", r.total, ErrInvalidPadding)
+			return
+		}
+	} else if decryptable == len(r.cipher) {
+		decryptable -= aes.BlockSize
+	}
+	if decryptable > 0 {
+		r.mode.CryptBlocks(r.plain[:decryptable], r.cipher[:decryptable])
+		r.out = r.plain[:decryptable]
+		r.cipher = r.cipher[:copy(r.cipher, r.cipher[decryptable:])]
+	}
+	if eof {
+		if r.out, r.err = unpadPKCS7(r.out); r.err == nil {
+			r.err = io.EOF
+		}
+	}
+}
+
+func unpadPKCS7(data []byte) ([]byte, error) {
+	if len(data) == 0 {
+		return nil, ErrInvalidPadding
+	}
+	pad := int(data[len(data)-1])
+	if pad == 0 || pad > aes.BlockSize || pad > len(data) {
+		return nil, ErrInvalidPadding
+	}
+	for _, b := range data[len(data)-pad:] {
+		if int(b) != pad {
+			return nil, ErrInvalidPadding
+		}
+	}
+	return data[:len(data)-pad], nil
+}
+
+// decryptSampleAES decrypts a SAMPLE-AES MPEG-TS segment, using a pooled IV
+// buffer.
+func decryptSampleAES(data []byte, key []byte, k *Key, mediaSequence uint64) (plain []byte, err error) {
+	iv := IV128Pool.Get().([]byte)
+	defer IV128Pool.Put(iv)
+	if err = k.SegmentIV(mediaSequence, iv); err != nil {
+		return
+	}
+	return sampleaes.Decrypt(data, key, iv)
+}
+
+// decryptAES128 decrypts a whole resource, using a pooled IV buffer.
+func decryptAES128(data []byte, key []byte, k *Key, mediaSequence uint64) (plain []byte, err error) {
+	iv := IV128Pool.Get().([]byte)
+	defer IV128Pool.Put(iv)
+	if err = k.SegmentIV(mediaSequence, iv); err != nil {
+		return
+	}
+	var r io.Reader
+	if r, err = NewAES128Reader(bytes.NewReader(data), key, iv); err != nil {
+		return
+	}
+	return io.ReadAll(r)
+}
@@ -0,0 +1,127 @@
+package hls
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	testKey = []byte("0123456789abcdef")
+	testIV  = []byte("fedcba9876543210")
+)
+
+func encryptAES128(plain, key, iv []byte) []byte {
+	pad := aes.BlockSize - len(plain)%aes.BlockSize
+	data := append(append([]byte(nil), plain...), bytes.Repeat([]byte{byte(pad)}, pad)...)
+	block, _ := aes.NewCipher(key)
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(data, data)
+	return data
+}
+
+func TestAES128Reader(t *testing.T) {
+	for _, size := range []int{0, 1, 15, 16, 17, 100000} {
+		plain := bytes.Repeat([]byte("x"), size)
+		for i := range plain {
+			plain[i] = byte(i)
+		}
+		encrypted := encryptAES128(plain, testKey, testIV)
+		for _, r := range []io.Reader{bytes.NewReader(encrypted), iotest.OneByteReader(bytes.NewReader(encrypted)), iotest.DataErrReader(bytes.NewReader(encrypted))} {
+			reader, err := NewAES128Reader(r, testKey, testIV)
+			if !assert.NoError(t, err) {
+				return
+			}
+			decrypted, err := io.ReadAll(reader)
+			assert.NoError(t, err, "size %d", size)
+			assert.Equal(t, plain, decrypted, "size %d", size)
+		}
+	}
+
+	_, err := NewAES128Reader(nil, testKey[:8], testIV)
+	assert.ErrorIs(t, err, ErrInvalidKeyLength)
+	_, err = NewAES128Reader(nil, testKey, testIV[:8])
+	assert.ErrorIs(t, err, ErrInvalidIVLength)
+
+	for _, encrypted := range [][]byte{
+		encryptAES128([]byte("hello"), testKey, testIV)[:15],
+		encryptAES128([]byte("hello"), []byte("another key 1234"), testIV),
+		{},
+	} {
+		reader, _ := NewAES128Reader(bytes.NewReader(encrypted), testKey, testIV)
+		_, err = io.ReadAll(reader)
+		assert.ErrorIs(t, err, ErrInvalidPadding)
+	}
+}
+
+func TestSegmentIV(t *testing.T) {
+	iv := make([]byte, 16)
+	k := &Key{Method: KeyMethodAES128}
+	assert.NoError(t, k.SegmentIV(0x0102, iv))
+	assert.Equal(t, []byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 2}, iv)
+
+	k.IV = testIV
+	assert.NoError(t, k.SegmentIV(0x0102, iv))
+	assert.Equal(t, testIV, iv)
+
+	k.IV = testIV[:15]
+	assert.ErrorIs(t, k.SegmentIV(0, iv), ErrInvalidIVLength)
+	assert.ErrorIs(t, k.SegmentIV(0, iv[:8]), ErrInvalidIVLength)
+}
+
+func TestDownloaderDecrypt(t *testing.T) {
+	ivFor := func(sequence uint64) []byte {
+		iv := make([]byte, 16)
+		(&Key{}).SegmentIV(sequence, iv)
+		return iv
+	}
+	keyRequests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/key":
+			keyRequests++
+			w.Write(testKey)
+		case "/short.key":
+			w.Write(testKey[:10])
+		case "/init.mp4":
+			w.Write(encryptAES128([]byte("init"), testKey, testIV))
+		case "/clear.ts":
+			w.Write([]byte("clear"))
+		default:
+			var sequence uint64
+			fmt.Sscanf(r.URL.Path, "/%d.ts", &sequence)
+			w.Write(encryptAES128([]byte(r.URL.Path), testKey, ivFor(sequence)))
+		}
+	}))
+	defer server.Close()
+
+	playlist := parseTestMediaPlaylist(t, server.URL, "#EXT-X-MEDIA-SEQUENCE:7\n"+
+		"#EXT-X-KEY:METHOD=AES-128,URI=\"key\",IV=0x66656463626139383736353433323130\n"+
+		"#EXT-X-MAP:URI=\"init.mp4\"\n"+
+		"#EXT-X-KEY:METHOD=AES-128,URI=\"key\"\n"+
+		"#EXTINF:4,\n7.ts\n#EXTINF:4,\n8.ts\n"+
+		"#EXT-X-KEY:METHOD=NONE\n"+
+		"#EXTINF:4,\nclear.ts\n")
+	sink := &recordingSink{}
+	d := &Downloader{KeyProvider: &HTTPKeyProvider{}}
+	_, err := d.Download(context.Background(), playlist, sink, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"init:init", "/7.ts", "/8.ts", "clear"}, sink.writes)
+	assert.Equal(t, 1, keyRequests)
+
+	playlist = parseTestMediaPlaylist(t, server.URL, "#EXT-X-KEY:METHOD=AES-128,URI=\"key\"\n#EXT-X-MAP:URI=\"init.mp4\"\n#EXTINF:4,\n0.ts\n")
+	_, err = d.Download(context.Background(), playlist, &recordingSink{}, nil)
+	assert.ErrorIs(t, err, ErrMissingIV)
+
+	playlist = parseTestMediaPlaylist(t, server.URL, "#EXT-X-KEY:METHOD=AES-128,URI=\"short.key\"\n#EXTINF:4,\n0.ts\n")
+	_, err = d.Download(context.Background(), playlist, &recordingSink{}, nil)
+	assert.ErrorIs(t, err, ErrInvalidKeyLength)
+}
@@ -0,0 +1,249 @@
+package hls
+
+import (
+	"context"
+	"crypto/aes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Sink receives the resources of a media playlist in playlist order. An
+// init map is written before the first segment using it, and again only when
+// a later segment uses a different one.
+type Sink interface {
+	WriteMediaInitMap(m *MediaInitMap, data []byte) error
+	WriteMediaSegment(s *MediaSegment, data []byte) error
+}
+
+// Checkpoint is the progress of a download, from which Download can resume.
+type Checkpoint struct {
+	NextMediaSequence uint64 // Media Sequence Number of the first segment not yet written
+	MediaInitMap      string // MediaInitMapKey of the last init map written, "" if none
+}
+
+// MediaInitMapKey identifies an init map by its URI and byte range.
+func MediaInitMapKey(m *MediaInitMap) string {
+	if m.ByteRange == nil {
+		return m.URI.String()
+	}
+	return fmt.Sprintf("%s@%d-%d", m.URI, m.ByteRange.Offset, m.ByteRange.Length)
+}
+
+// Downloader downloads the segments of a media playlist.
+type Downloader struct {
+	Client           Doer             // [OPTIONAL][DEFAULT=http.DefaultClient]
+	Header           http.Header      // [OPTIONAL] added to every request
+	Concurrency      int              // [OPTIONAL][DEFAULT=4] the maximum number of segments downloaded or waiting to be written
+	Retries          int              // [OPTIONAL][DEFAULT=3] retries of a request after a transient failure, negative for none
+	Backoff          time.Duration    // [OPTIONAL][DEFAULT=500ms] the delay before the first retry, doubled for each following one
+	HandleCheckpoint func(Checkpoint) // [OPTIONAL] called after every written segment, so the progress can be persisted
+	KeyProvider      KeyProvider      // [OPTIONAL] when set, AES-128 encrypted segments and init maps are decrypted before being written; wrapped in a CachingKeyProvider for each Download unless it is one
+}
+
+type downloadJob struct {
+	segment *MediaSegment
+	initMap *MediaInitMap // set for init map jobs
+	result  chan downloadResult
+}
+
+type downloadResult struct {
+	data []byte
+	err  error
+}
+
+// Download writes the segments of the playlist, and the init maps they use,
+// to the sink. Up to Concurrency resources are fetched in parallel but they
+// are written in playlist order. Gap segments are skipped. If from is not
+// nil, segments before from.NextMediaSequence are skipped. The returned
+// checkpoint reflects what was written, even on error.
+func (d *Downloader) Download(ctx context.Context, playlist *MediaPlaylist, sink Sink, from *Checkpoint) (checkpoint Checkpoint, err error) {
+	concurrency := d.Concurrency
+	if concurrency <= 0 {
+		concurrency = 4
+	}
+	if from != nil {
+		checkpoint = *from
+	} else if len(playlist.MediaSegments) > 0 {
+		checkpoint.NextMediaSequence = playlist.MediaSegments[0].MediaSequence
+	}
+
+	var jobs []*downloadJob
+	initMapKey := checkpoint.MediaInitMap
+	for _, segment := range playlist.MediaSegments {
+		if segment.MediaSequence < checkpoint.NextMediaSequence || segment.IsGap {
+			continue
+		}
+		if segment.MediaInitMap != nil {
+			if key := MediaInitMapKey(segment.MediaInitMap); key != initMapKey {
+				jobs = append(jobs, &downloadJob{segment: segment, initMap: segment.MediaInitMap, result: make(chan downloadResult, 1)})
+				initMapKey = key
+			}
+		}
+		jobs = append(jobs, &downloadJob{segment: segment, result: make(chan downloadResult, 1)})
+	}
+
+	keys := d.KeyProvider
+	if _, ok := keys.(*CachingKeyProvider); !ok && keys != nil {
+		keys = &CachingKeyProvider{Provider: keys}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+
+	// slots bounds the resources fetched but not yet written
+	slots := make(chan struct{}, concurrency)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for _, job := range jobs {
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			wg.Add(1)
+			go func(job *downloadJob) {
+				defer wg.Done()
+				var result downloadResult
+				if job.initMap != nil {
+					if result.data, result.err = d.fetch(ctx, job.initMap.URI, job.initMap.ByteRange); result.err == nil {
+						result.data, result.err = d.decrypt(ctx, keys, job.initMap.Key, true, 0, result.data)
+					}
+				} else {
+					if result.data, result.err = d.fetch(ctx, job.segment.URI, job.segment.ByteRange); result.err == nil {
+						result.data, result.err = d.decrypt(ctx, keys, job.segment.Key, false, job.segment.MediaSequence, result.data)
+					}
+				}
+				job.result <- result
+			}(job)
+		}
+	}()
+
+	for _, job := range jobs {
+		var result downloadResult
+		select {
+		case result = <-job.result:
+		case <-ctx.Done():
+			err = ctx.Err()
+			return
+		}
+		if result.err != nil {
+			err = result.err
+			return
+		}
+		if job.initMap != nil {
+			if err = sink.WriteMediaInitMap(job.initMap, result.data); err != nil {
+				return
+			}
+			checkpoint.MediaInitMap = MediaInitMapKey(job.initMap)
+		} else {
+			if err = sink.WriteMediaSegment(job.segment, result.data); err != nil {
+				return
+			}
+			checkpoint.NextMediaSequence = job.segment.MediaSequence + 1
+			if d.HandleCheckpoint != nil {
+				d.HandleCheckpoint(checkpoint)
+			}
+		}
+		<-slots
+	}
+	// skipped trailing gap segments count as done
+	if n := len(playlist.MediaSegments); n > 0 && playlist.MediaSegments[n-1].MediaSequence >= checkpoint.NextMediaSequence {
+		checkpoint.NextMediaSequence = playlist.MediaSegments[n-1].MediaSequence + 1
+	}
+	return
+}
+
+// decrypt decrypts an AES-128 encrypted resource, or a SAMPLE-AES encrypted
+// MPEG-TS segment with an identity key, if a KeyProvider is set. Media
+// initialization sections have no Media Sequence Number, so their key must
+// carry an IV.
+func (d *Downloader) decrypt(ctx context.Context, keys KeyProvider, k *Key, initMap bool, mediaSequence uint64, data []byte) (plain []byte, err error) {
+	if d.KeyProvider == nil || k == nil {
+		return data, nil
+	}
+	switch {
+	case k.Method == KeyMethodAES128:
+		if initMap && k.IV == nil {
+			err = ErrMissingIV
+			return
+		}
+	case k.Method == KeyMethodSampleAES && !initMap && k.IsIdentity() && len(data) > 0 && data[0] == tsSyncByte:
+	default:
+		return data, nil
+	}
+	var key []byte
+	if key, err = keys.Key(ctx, k); err != nil {
+		return
+	}
+	if len(key) != aes.BlockSize {
+		err = fmt.Errorf("key from %s has %d bytes: %w", k.URI, len(key), ErrInvalidKeyLength)
+		return
+	}
+	if k.Method == KeyMethodSampleAES {
+		plain, err = decryptSampleAES(data, key, k, mediaSequence)
+	} else {
+		plain, err = decryptAES128(data, key, k, mediaSequence)
+	}
+	if err != nil {
+		err = fmt.Errorf("failed decrypting with key %s: %w", k.URI, err)
+	}
+	return
+}
+
+// fetch gets a resource, retrying transient failures with exponential
+// backoff.
+func (d *Downloader) fetch(ctx context.Context, u *url.URL, br *ByteRange) (data []byte, err error) {
+	retries := d.Retries
+	if retries == 0 {
+		retries = 3
+	}
+	backoff := d.Backoff
+	if backoff <= 0 {
+		backoff = 500 * time.Millisecond
+	}
+	for attempt := 0; ; attempt++ {
+		if data, err = ReadResource(ctx, d.Client, d.Header, u, br); err == nil || attempt >= retries || !isTransient(err) || ctx.Err() != nil {
+			return
+		}
+		timer := time.NewTimer(backoff << attempt)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// isTransient reports whether a failed request is worth retrying: network
+// errors, truncated bodies and 5xx, 408 or 429 responses.
+func isTransient(err error) bool {
+	var statusErr *HTTPStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= 500 || statusErr.StatusCode == http.StatusTooManyRequests || statusErr.StatusCode == http.StatusRequestTimeout
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	// every error of an HTTP client is a *url.Error, a net.Error, whatever
+	// went wrong
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		err = urlErr.Err
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
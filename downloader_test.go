@@ -0,0 +1,161 @@
+package hls
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingSink struct {
+	writes []string
+}
+
+func (s *recordingSink) WriteMediaInitMap(m *MediaInitMap, data []byte) error {
+	s.writes = append(s.writes, "init:"+string(data))
+	return nil
+}
+
+func (s *recordingSink) WriteMediaSegment(segment *MediaSegment, data []byte) error {
+	s.writes = append(s.writes, string(data))
+	return nil
+}
+
+func newSegmentOrigin(t *testing.T) (server *httptest.Server, requests map[string]int) {
+	requests = map[string]int{}
+	var mu sync.Mutex
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[r.URL.Path]++
+		count := requests[r.URL.Path]
+		mu.Unlock()
+		name := strings.TrimPrefix(r.URL.Path, "/")
+		switch name {
+		case "flaky.ts":
+			if count <= 2 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+		case "missing.ts":
+			http.NotFound(w, r)
+			return
+		case "0.ts":
+			// finish last to check that the output order is kept
+			time.Sleep(50 * time.Millisecond)
+		}
+		if strings.HasPrefix(name, "packed") {
+			http.ServeContent(w, r, name, time.Time{}, strings.NewReader("init|seg-a|seg-b"))
+			return
+		}
+		w.Write([]byte(name))
+	}))
+	return
+}
+
+func parseTestMediaPlaylist(t *testing.T, base string, body string) (playlist *MediaPlaylist) {
+	u, _ := url.Parse(base + "/index.m3u8")
+	err := Parse(strings.NewReader("#EXTM3U\n#EXT-X-TARGETDURATION:4\n"+body), u, &ParserHandler{
+		HandleMediaPlaylist: func(p *MediaPlaylist) { playlist = p },
+	})
+	assert.NoError(t, err)
+	return
+}
+
+func TestDownloader(t *testing.T) {
+	server, requests := newSegmentOrigin(t)
+	defer server.Close()
+	playlist := parseTestMediaPlaylist(t, server.URL, "#EXT-X-MEDIA-SEQUENCE:10\n"+
+		"#EXT-X-MAP:URI=\"init-a.mp4\"\n"+
+		"#EXTINF:4,\n0.ts\n"+
+		"#EXTINF:4,\nflaky.ts\n"+
+		"#EXT-X-GAP\n#EXTINF:4,\ngap.ts\n"+
+		"#EXTINF:4,\n3.ts\n"+
+		"#EXT-X-MAP:URI=\"init-b.mp4\"\n"+
+		"#EXTINF:4,\n4.ts\n")
+
+	var checkpoints []uint64
+	d := &Downloader{Concurrency: 3, Backoff: time.Millisecond, HandleCheckpoint: func(c Checkpoint) {
+		checkpoints = append(checkpoints, c.NextMediaSequence)
+	}}
+	sink := &recordingSink{}
+	checkpoint, err := d.Download(context.Background(), playlist, sink, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []string{"init:init-a.mp4", "0.ts", "flaky.ts", "3.ts", "init:init-b.mp4", "4.ts"}, sink.writes)
+	assert.Equal(t, 3, requests["/flaky.ts"])
+	assert.Zero(t, requests["/gap.ts"])
+	assert.Equal(t, []uint64{11, 12, 14, 15}, checkpoints)
+	assert.Equal(t, Checkpoint{NextMediaSequence: 15, MediaInitMap: server.URL + "/init-b.mp4"}, checkpoint)
+
+	// resume after 3.ts, the init map already written is not repeated
+	sink = &recordingSink{}
+	checkpoint, err = d.Download(context.Background(), playlist, sink, &Checkpoint{NextMediaSequence: 12, MediaInitMap: server.URL + "/init-a.mp4"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"3.ts", "init:init-b.mp4", "4.ts"}, sink.writes)
+	assert.EqualValues(t, 15, checkpoint.NextMediaSequence)
+}
+
+func TestDownloaderRetries(t *testing.T) {
+	server, requests := newSegmentOrigin(t)
+	defer server.Close()
+	playlist := parseTestMediaPlaylist(t, server.URL, "#EXTINF:4,\nflaky.ts\n")
+	_, err := (&Downloader{Retries: -1}).Download(context.Background(), playlist, &recordingSink{}, nil)
+	var statusErr *HTTPStatusError
+	if assert.True(t, errors.As(err, &statusErr), fmt.Sprint(err)) {
+		assert.Equal(t, http.StatusServiceUnavailable, statusErr.StatusCode)
+	}
+	assert.Equal(t, 1, requests["/flaky.ts"])
+
+	// only network errors, truncated bodies and some statuses are retried
+	refused := &url.Error{Op: "Get", URL: server.URL, Err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}}
+	for err, transient := range map[error]bool{
+		refused: true,
+		fmt.Errorf("GET %s: reading range 4@0: %w", server.URL, io.ErrUnexpectedEOF):                    true,
+		&HTTPStatusError{StatusCode: http.StatusTooManyRequests}:                                        true,
+		&HTTPStatusError{StatusCode: http.StatusNotFound}:                                               false,
+		&url.Error{Op: "Get", URL: "ftp://example.com", Err: errors.New("unsupported protocol scheme")}: false,
+		fmt.Errorf("range 4@8 exceeds the 10 bytes of the data URI: %w", ErrFormat):                     false,
+		context.Canceled: false,
+	} {
+		assert.Equal(t, transient, isTransient(err), err.Error())
+	}
+}
+
+func TestDownloaderByteRange(t *testing.T) {
+	server, _ := newSegmentOrigin(t)
+	defer server.Close()
+	playlist := parseTestMediaPlaylist(t, server.URL,
+		"#EXT-X-MAP:URI=\"packed.mp4\",BYTERANGE=\"4@0\"\n"+
+			"#EXT-X-BYTERANGE:5@5\n#EXTINF:4,\npacked.mp4\n"+
+			"#EXT-X-BYTERANGE:5@11\n#EXTINF:4,\npacked.mp4\n")
+	sink := &recordingSink{}
+	_, err := (&Downloader{}).Download(context.Background(), playlist, sink, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"init:init", "seg-a", "seg-b"}, sink.writes)
+}
+
+func TestDownloaderErrors(t *testing.T) {
+	server, requests := newSegmentOrigin(t)
+	defer server.Close()
+	playlist := parseTestMediaPlaylist(t, server.URL, "#EXTINF:4,\n1.ts\n#EXTINF:4,\nmissing.ts\n#EXTINF:4,\n3.ts\n")
+	sink := &recordingSink{}
+	checkpoint, err := (&Downloader{Concurrency: 1}).Download(context.Background(), playlist, sink, nil)
+	var statusErr *HTTPStatusError
+	if assert.True(t, errors.As(err, &statusErr), fmt.Sprint(err)) {
+		assert.Equal(t, http.StatusNotFound, statusErr.StatusCode)
+	}
+	assert.Equal(t, 1, requests["/missing.ts"])
+	assert.Equal(t, []string{"1.ts"}, sink.writes)
+	assert.EqualValues(t, 1, checkpoint.NextMediaSequence)
+}
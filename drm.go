@@ -0,0 +1,168 @@
+package hls
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-webdl/hls/cenc"
+)
+
+type DRMSystem string
+
+const (
+	DRMSystemIdentity  DRMSystem = "identity"
+	DRMSystemWidevine  DRMSystem = "widevine"
+	DRMSystemPlayReady DRMSystem = "playready"
+	DRMSystemFairPlay  DRMSystem = "fairplay"
+	DRMSystemUnknown   DRMSystem = "unknown"
+)
+
+// Well-known KEYFORMAT values.
+const (
+	KeyFormatIdentity  = "identity"
+	KeyFormatWidevine  = "urn:uuid:edef8ba9-79d6-4ace-a3c8-27dcd51d21ed"
+	KeyFormatPlayReady = "com.microsoft.playready"
+	KeyFormatFairPlay  = "com.apple.streamingkeydelivery"
+)
+
+var drmSystemIDs = map[cenc.SystemID]DRMSystem{
+	cenc.SystemIDWidevine:  DRMSystemWidevine,
+	cenc.SystemIDPlayReady: DRMSystemPlayReady,
+	cenc.SystemIDFairPlay:  DRMSystemFairPlay,
+}
+
+// keyFormat returns the KEYFORMAT, "identity" when absent.
+func (k *Key) keyFormat() string {
+	if k.KeyFormat == nil {
+		return KeyFormatIdentity
+	}
+	return *k.KeyFormat
+}
+
+// DRMSystem identifies the DRM system of the key from its KEYFORMAT, which
+// may also be the "urn:uuid:" URN of the system ID of any known system.
+func (k *Key) DRMSystem() DRMSystem {
+	format := k.keyFormat()
+	switch format {
+	case KeyFormatIdentity:
+		return DRMSystemIdentity
+	case KeyFormatPlayReady:
+		return DRMSystemPlayReady
+	case KeyFormatFairPlay:
+		return DRMSystemFairPlay
+	}
+	if len(format) > len("urn:uuid:") && strings.EqualFold(format[:len("urn:uuid:")], "urn:uuid:") {
+		if id, err := cenc.ParseUUID(format[len("urn:uuid:"):]); err == nil {
+			if system, ok := drmSystemIDs[id]; ok {
+				return system
+			}
+		}
+	}
+	return DRMSystemUnknown
+}
+
+// DRMData is the DRM system specific data carried by a key.
+type DRMData struct {
+	System   DRMSystem
+	SystemID cenc.SystemID // [OPTIONAL] zero when unknown
+	KeyIDs   []cenc.KeyID  // [OPTIONAL] the KIDs of the PSSH box or of the system specific data, and the KEYID attribute
+	Data     []byte        // the system specific data, such as the Widevine PSSH data or the PlayReady Object
+	PSSH     []byte        // [OPTIONAL] the whole PSSH box, if the URI carries one
+}
+
+// DRMData decodes the data: URI of the key, which carries either a base64
+// PSSH box or, for Widevine and PlayReady, the system specific data alone.
+// It returns nil if the URI is not a data: URI.
+func (k *Key) DRMData() (d *DRMData, err error) {
+	if k.URI == nil || k.URI.Scheme != "data" {
+		return
+	}
+	_, data, err := decodeDataURI(k.URI)
+	if err != nil {
+		return
+	}
+	d = &DRMData{System: k.DRMSystem(), Data: data}
+	if len(data) >= 8 && string(data[4:8]) == "pssh" {
+		var pssh *cenc.PSSH
+		if pssh, err = cenc.ParsePSSH(data); err != nil {
+			return nil, fmt.Errorf("failed parsing key PSSH box: %v: %w", err, ErrFormat)
+		}
+		d.PSSH, d.SystemID, d.KeyIDs, d.Data = data, pssh.SystemID, pssh.KeyIDs, pssh.Data
+		if system, ok := drmSystemIDs[pssh.SystemID]; ok {
+			d.System = system
+		}
+	} else {
+		switch d.System {
+		case DRMSystemWidevine:
+			d.SystemID = cenc.SystemIDWidevine
+			if d.KeyIDs, err = cenc.WidevineKeyIDs(data); err != nil {
+				return nil, fmt.Errorf("failed parsing key Widevine data: %v: %w", err, ErrFormat)
+			}
+		case DRMSystemPlayReady:
+			d.SystemID = cenc.SystemIDPlayReady
+			if d.KeyIDs, err = cenc.PlayReadyKeyIDs(data); err != nil {
+				return nil, fmt.Errorf("failed parsing key PlayReady Object: %v: %w", err, ErrFormat)
+			}
+		}
+	}
+	if len(k.KeyID) == len(cenc.KeyID{}) {
+		var kid cenc.KeyID
+		copy(kid[:], k.KeyID)
+		found := false
+		for _, id := range d.KeyIDs {
+			found = found || id == kid
+		}
+		if !found {
+			d.KeyIDs = append(d.KeyIDs, kid)
+		}
+	}
+	return
+}
+
+// Keys returns every key of the playlist, those of the media segments and
+// of the media initialization sections, once each in order of appearance.
+func (p *MediaPlaylist) Keys() (keys []*Key) {
+	seen := make(map[*Key]bool)
+	add := func(list []*Key) {
+		for _, k := range list {
+			if !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+	}
+	for _, s := range p.MediaSegments {
+		if s.MediaInitMap != nil {
+			add(s.MediaInitMap.Keys)
+		}
+		add(s.Keys)
+	}
+	return
+}
+
+// KeysByDRMSystem groups the keys of the playlist by DRM system. Keys with
+// METHOD=NONE are left out.
+func (p *MediaPlaylist) KeysByDRMSystem() map[DRMSystem][]*Key {
+	groups := make(map[DRMSystem][]*Key)
+	for _, k := range p.Keys() {
+		if k.Method != KeyMethodNone {
+			system := k.DRMSystem()
+			groups[system] = append(groups[system], k)
+		}
+	}
+	return groups
+}
+
+// activeKeys returns the keys in effect after an EXT-X-KEY tag: a key
+// replaces the one of the same KEYFORMAT, and METHOD=NONE all of them.
+func activeKeys(keys []*Key, key *Key) (active []*Key) {
+	if key.Method == KeyMethodNone {
+		return []*Key{key}
+	}
+	for _, k := range keys {
+		if k.Method != KeyMethodNone && k.keyFormat() != key.keyFormat() {
+			active = append(active, k)
+		}
+	}
+	return append(active, key)
+}
@@ -0,0 +1,132 @@
+package hls
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"strings"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/go-webdl/hls/cenc"
+	"github.com/stretchr/testify/assert"
+)
+
+var testKID = cenc.KeyID{0x10, 0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18, 0x19, 0x1a, 0x1b, 0x1c, 0x1d, 0x1e, 0x1f}
+
+// testWidevinePSSH builds a version 0 Widevine pssh box whose protobuf data
+// holds the KID.
+func testWidevinePSSH() []byte {
+	data := append([]byte{0x08, 0x01, 0x12, 0x10}, testKID[:]...)
+	data = append(data, 0x22, 0x03, 'a', 'b', 'c')
+	box := []byte{0, 0, 0, 0, 'p', 's', 's', 'h', 0, 0, 0, 0}
+	box = append(box, cenc.SystemIDWidevine[:]...)
+	box = append(box, 0, 0, 0, byte(len(data)))
+	box = append(box, data...)
+	binary.BigEndian.PutUint32(box, uint32(len(box)))
+	return box
+}
+
+// testPlayReadyObject builds a PlayReady Object whose WRM header holds the
+// KID as a GUID.
+func testPlayReadyObject() []byte {
+	guid := []byte{testKID[3], testKID[2], testKID[1], testKID[0], testKID[5], testKID[4], testKID[7], testKID[6]}
+	guid = append(guid, testKID[8:]...)
+	header := `<WRMHEADER version="4.3.0.0"><DATA><PROTECTINFO><KIDS><KID ALGID="AESCBC" VALUE="` +
+		base64.StdEncoding.EncodeToString(guid) + `"></KID></KIDS></PROTECTINFO></DATA></WRMHEADER>`
+	var record []byte
+	for _, u := range utf16.Encode([]rune(header)) {
+		record = append(record, byte(u), byte(u>>8))
+	}
+	pro := make([]byte, 10, 10+len(record))
+	binary.LittleEndian.PutUint32(pro, uint32(10+len(record)))
+	binary.LittleEndian.PutUint16(pro[4:], 1)
+	binary.LittleEndian.PutUint16(pro[6:], 1)
+	binary.LittleEndian.PutUint16(pro[8:], uint16(len(record)))
+	return append(pro, record...)
+}
+
+func TestKeysByDRMSystem(t *testing.T) {
+	input := "#EXTM3U\n" +
+		"#EXT-X-TARGETDURATION:4\n" +
+		`#EXT-X-KEY:METHOD=SAMPLE-AES,URI="data:text/plain;base64,` + base64.StdEncoding.EncodeToString(testWidevinePSSH()) + `",KEYFORMAT="urn:uuid:EDEF8BA9-79D6-4ACE-A3C8-27DCD51D21ED",KEYFORMATVERSIONS="1"` + "\n" +
+		`#EXT-X-KEY:METHOD=SAMPLE-AES,URI="data:text/plain;charset=UTF-16;base64,` + base64.StdEncoding.EncodeToString(testPlayReadyObject()) + `",KEYFORMAT="com.microsoft.playready"` + "\n" +
+		`#EXT-X-KEY:METHOD=SAMPLE-AES,URI="skd://key1",KEYFORMAT="com.apple.streamingkeydelivery"` + "\n" +
+		`#EXT-X-MAP:URI="init.mp4"` + "\n" +
+		"#EXTINF:4,\n" +
+		"a.mp4\n" +
+		`#EXT-X-KEY:METHOD=SAMPLE-AES,URI="skd://key2",KEYFORMAT="com.apple.streamingkeydelivery"` + "\n" +
+		"#EXTINF:4,\n" +
+		"b.mp4\n" +
+		"#EXT-X-KEY:METHOD=NONE\n" +
+		"#EXTINF:4,\n" +
+		"c.mp4\n"
+	var playlist *MediaPlaylist
+	err := Parse(strings.NewReader(input), testBaseURL, &ParserHandler{
+		HandleMediaPlaylist: func(p *MediaPlaylist) { playlist = p },
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	segments := playlist.MediaSegments
+	assert.Len(t, segments[0].Keys, 3)
+	assert.Equal(t, segments[0].Keys, segments[0].MediaInitMap.Keys)
+	if assert.Len(t, segments[1].Keys, 3) {
+		assert.Equal(t, "skd://key2", segments[1].Keys[2].URI.String())
+		assert.Equal(t, segments[0].Keys[:2], segments[1].Keys[:2])
+	}
+	assert.Len(t, segments[2].Keys, 1)
+
+	groups := playlist.KeysByDRMSystem()
+	assert.Len(t, groups, 3)
+	assert.Len(t, groups[DRMSystemWidevine], 1)
+	assert.Len(t, groups[DRMSystemPlayReady], 1)
+	assert.Len(t, groups[DRMSystemFairPlay], 2)
+
+	widevine, err := groups[DRMSystemWidevine][0].DRMData()
+	if assert.NoError(t, err) {
+		assert.Equal(t, cenc.SystemIDWidevine, widevine.SystemID)
+		assert.Equal(t, []cenc.KeyID{testKID}, widevine.KeyIDs)
+		assert.Equal(t, testWidevinePSSH(), widevine.PSSH)
+	}
+	playReady, err := groups[DRMSystemPlayReady][0].DRMData()
+	if assert.NoError(t, err) {
+		assert.Equal(t, cenc.SystemIDPlayReady, playReady.SystemID)
+		assert.Equal(t, []cenc.KeyID{testKID}, playReady.KeyIDs)
+		assert.Nil(t, playReady.PSSH)
+	}
+	fairPlay, err := groups[DRMSystemFairPlay][0].DRMData()
+	assert.NoError(t, err)
+	assert.Nil(t, fairPlay)
+}
+
+func TestKeyDRMSystem(t *testing.T) {
+	for format, system := range map[string]DRMSystem{
+		"":                 DRMSystemIdentity,
+		KeyFormatIdentity:  DRMSystemIdentity,
+		KeyFormatWidevine:  DRMSystemWidevine,
+		KeyFormatPlayReady: DRMSystemPlayReady,
+		"urn:uuid:9a04f079-9840-4286-ab92-e65be0885f95": DRMSystemPlayReady,
+		KeyFormatFairPlay: DRMSystemFairPlay,
+		"com.example.drm": DRMSystemUnknown,
+	} {
+		k := &Key{}
+		if format != "" {
+			k.KeyFormat = &format
+		}
+		assert.Equal(t, system, k.DRMSystem(), format)
+	}
+}
+
+func TestKeyDRMDataKeyID(t *testing.T) {
+	tag := &Tag{Name: "EXT-X-KEY", Value: `METHOD=SAMPLE-AES,URI="data:text/plain;base64,` + base64.RawStdEncoding.EncodeToString(testWidevinePSSH()[32:]) + `",KEYID=0x202122232425262728292a2b2c2d2e2f,KEYFORMAT="` + KeyFormatWidevine + `"`}
+	k := &Key{}
+	if !assert.NoError(t, k.ParseTag(tag)) {
+		return
+	}
+	d, err := k.DRMData()
+	if assert.NoError(t, err) {
+		kid := cenc.KeyID{0x20, 0x21, 0x22, 0x23, 0x24, 0x25, 0x26, 0x27, 0x28, 0x29, 0x2a, 0x2b, 0x2c, 0x2d, 0x2e, 0x2f}
+		assert.Equal(t, []cenc.KeyID{testKID, kid}, d.KeyIDs)
+		assert.Equal(t, cenc.SystemIDWidevine, d.SystemID)
+	}
+}
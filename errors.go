@@ -3,3 +3,21 @@ package hls
 import "errors"
 
 var ErrFormat = errors.New("invalid HLS format")
+
+var (
+	ErrPlaylistTooLarge       = errors.New("playlist exceeds the byte limit")
+	ErrLineTooLong            = errors.New("playlist line exceeds the length limit")
+	ErrTooManyMediaSegments   = errors.New("playlist exceeds the media segment limit")
+	ErrTooManyStreams         = errors.New("playlist exceeds the stream limit")
+	ErrTooManyRenditions      = errors.New("playlist exceeds the rendition limit")
+	ErrTooManyRenditionGroups = errors.New("playlist exceeds the rendition group limit")
+	ErrTooManyAttributes      = errors.New("attribute list exceeds the attribute limit")
+	ErrAttributeValueTooLong  = errors.New("attribute value exceeds the length limit")
+)
+
+var (
+	ErrInvalidKeyLength = errors.New("AES-128 key must be 16 bytes")
+	ErrInvalidIVLength  = errors.New("AES-128 IV must be 16 bytes")
+	ErrInvalidPadding   = errors.New("invalid PKCS#7 padding")
+	ErrMissingIV        = errors.New("encrypted media initialization section requires an IV")
+)
@@ -7,6 +7,7 @@ import (
 
 type IframeStream struct {
 	BaseStream
+	Video *string // [OPTIONAL] indicates the set of video Renditions that SHOULD be used when playing the presentation
 }
 
 func (s *IframeStream) ParseTag(tag *Tag) (err error) {
@@ -40,5 +41,11 @@ func (s *IframeStream) ParseAttributeList(attrs *AttributeList) (err error) {
 			return
 		}
 	}
+	if attr := attrs.GetLast("VIDEO"); attr != nil {
+		if s.Video, err = attr.StringPtr(); err != nil {
+			err = fmt.Errorf("failed getting VIDEO attribute: %w", err)
+			return
+		}
+	}
 	return
 }
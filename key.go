@@ -14,14 +14,17 @@ type Key struct {
 	IV                []byte    // [OPTIONAL] specifies a 128-bit unsigned integer Initialization Vector to be used with the key
 	KeyFormat         *string   // [OPTIONAL] specifies how the key is represented in the resource identified by the URI
 	KeyFormatVersions []uint64  // [OPTIONAL] indicate which version(s) this instance complies with
+	KeyID             []byte    // [OPTIONAL] the KEYID attribute some DRM systems use for the key ID
 }
 
 type KeyMethod string
 
 const (
-	KeyMethodNone      KeyMethod = "NONE"
-	KeyMethodAES128    KeyMethod = "AES-128"
-	KeyMethodSampleAES KeyMethod = "SAMPLE-AES"
+	KeyMethodNone         KeyMethod = "NONE"
+	KeyMethodAES128       KeyMethod = "AES-128"
+	KeyMethodSampleAES    KeyMethod = "SAMPLE-AES"
+	KeyMethodSampleAESCTR KeyMethod = "SAMPLE-AES-CTR" // Common Encryption "cenc" scheme (AES-CTR) of fMP4 segments
+	KeyMethodISO230017    KeyMethod = "ISO-23001-7"    // Common Encryption, the scheme being signaled in the fMP4 segments
 )
 
 func (k *Key) ParseTag(tag *Tag) (err error) {
@@ -49,7 +52,7 @@ func (k *Key) ParseAttributeList(attrs *AttributeList) (err error) {
 		}
 		method := KeyMethod(value)
 		switch method {
-		case KeyMethodNone, KeyMethodAES128, KeyMethodSampleAES:
+		case KeyMethodNone, KeyMethodAES128, KeyMethodSampleAES, KeyMethodSampleAESCTR, KeyMethodISO230017:
 			k.Method = method
 		default:
 			err = fmt.Errorf("%s tag has invalid METHOD enum value: %s: %w", k.Tag.Name, value, ErrFormat)
@@ -79,6 +82,12 @@ func (k *Key) ParseAttributeList(attrs *AttributeList) (err error) {
 			return
 		}
 	}
+	if attr := attrs.GetLast("KEYID"); attr != nil {
+		if k.KeyID, err = attr.Bytes(); err != nil {
+			err = fmt.Errorf("failed getting KEYID attribute: %w", err)
+			return
+		}
+	}
 	if attr := attrs.GetLast("KEYFORMATVERSIONS"); attr != nil {
 		var value string
 		if value, err = attr.String(); err != nil {
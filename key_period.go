@@ -0,0 +1,67 @@
+package hls
+
+import "bytes"
+
+// KeyIVMode tells how the IV of the segments of a key period is obtained.
+type KeyIVMode string
+
+const (
+	KeyIVModeNone          KeyIVMode = "none"           // the segments are not encrypted
+	KeyIVModeExplicit      KeyIVMode = "explicit"       // the IV attribute is used for every segment
+	KeyIVModeMediaSequence KeyIVMode = "media-sequence" // the Media Sequence Number of each segment is the IV
+)
+
+// KeyPeriod is a run of contiguous media segments encrypted with the same
+// keys.
+type KeyPeriod struct {
+	FirstMediaSequence uint64
+	LastMediaSequence  uint64
+	Key                *Key   // [OPTIONAL] the key of the segments, nil when there is no EXT-X-KEY tag
+	Keys               []*Key // [OPTIONAL] every key in effect, one per KEYFORMAT
+	IVMode             KeyIVMode
+}
+
+// KeyPeriods returns the key periods of the playlist, in order. A period
+// ends where an EXT-X-KEY tag changes any of the keys in effect, so key
+// rotation boundaries are where one period follows another. Repeated
+// identical EXT-X-KEY tags do not end a period.
+func (p *MediaPlaylist) KeyPeriods() (periods []KeyPeriod) {
+	var last *MediaSegment
+	for _, s := range p.MediaSegments {
+		if n := len(periods); n > 0 && sameKeys(last.activeKeys(), s.activeKeys()) {
+			periods[n-1].LastMediaSequence = s.MediaSequence
+			last = s
+			continue
+		}
+		last = s
+		periods = append(periods, KeyPeriod{
+			FirstMediaSequence: s.MediaSequence,
+			LastMediaSequence:  s.MediaSequence,
+			Key:                s.Key,
+			Keys:               s.Keys,
+			IVMode:             s.Key.ivMode(),
+		})
+	}
+	return
+}
+
+// sameKey reports whether two keys have the same attributes.
+func sameKey(a, b *Key) bool {
+	if a == b {
+		return true
+	}
+	if a == nil || b == nil || a.Method != b.Method || !bytes.Equal(a.IV, b.IV) || !bytes.Equal(a.KeyID, b.KeyID) || a.keyFormat() != b.keyFormat() {
+		return false
+	}
+	return (a.URI == nil && b.URI == nil) || (a.URI != nil && b.URI != nil && a.URI.String() == b.URI.String())
+}
+
+func (k *Key) ivMode() KeyIVMode {
+	switch {
+	case k == nil || k.Method == KeyMethodNone:
+		return KeyIVModeNone
+	case k.IV != nil:
+		return KeyIVModeExplicit
+	}
+	return KeyIVModeMediaSequence
+}
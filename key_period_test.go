@@ -0,0 +1,86 @@
+package hls
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyPeriods(t *testing.T) {
+	input := "#EXTM3U\n" +
+		"#EXT-X-TARGETDURATION:4\n" +
+		"#EXT-X-MEDIA-SEQUENCE:10\n" +
+		"#EXTINF:4,\n" +
+		"a.ts\n" +
+		`#EXT-X-KEY:METHOD=AES-128,URI="k1"` + "\n" +
+		"#EXTINF:4,\n" +
+		"b.ts\n" +
+		"#EXT-X-DISCONTINUITY\n" +
+		`#EXT-X-KEY:METHOD=AES-128,URI="k1"` + "\n" +
+		"#EXTINF:4,\n" +
+		"c.ts\n" +
+		`#EXT-X-KEY:METHOD=AES-128,URI="k2",IV=0x000102030405060708090a0b0c0d0e0f` + "\n" +
+		"#EXTINF:4,\n" +
+		"d.ts\n" +
+		"#EXTINF:4,\n" +
+		"e.ts\n" +
+		"#EXT-X-KEY:METHOD=NONE\n" +
+		"#EXTINF:4,\n" +
+		"f.ts\n"
+	var playlist *MediaPlaylist
+	err := Parse(strings.NewReader(input), testBaseURL, &ParserHandler{
+		HandleMediaPlaylist: func(p *MediaPlaylist) { playlist = p },
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	periods := playlist.KeyPeriods()
+	type span struct {
+		first, last uint64
+		uri         string
+		mode        KeyIVMode
+	}
+	var spans []span
+	for _, p := range periods {
+		s := span{p.FirstMediaSequence, p.LastMediaSequence, "", p.IVMode}
+		if p.Key != nil && p.Key.URI != nil {
+			s.uri = p.Key.URI.Path
+		}
+		spans = append(spans, s)
+	}
+	assert.Equal(t, []span{
+		{10, 10, "", KeyIVModeNone},
+		{11, 12, "/live/k1", KeyIVModeMediaSequence},
+		{13, 14, "/live/k2", KeyIVModeExplicit},
+		{15, 15, "", KeyIVModeNone},
+	}, spans)
+}
+
+func TestKeyPeriodsMultiDRM(t *testing.T) {
+	input := "#EXTM3U\n" +
+		"#EXT-X-TARGETDURATION:4\n" +
+		`#EXT-X-KEY:METHOD=SAMPLE-AES,URI="data:text/plain;base64,AAAA",KEYFORMAT="urn:uuid:edef8ba9-79d6-4ace-a3c8-27dcd51d21ed"` + "\n" +
+		`#EXT-X-KEY:METHOD=SAMPLE-AES,URI="skd://fairplay",KEYFORMAT="com.apple.streamingkeydelivery"` + "\n" +
+		"#EXTINF:4,\n" +
+		"a.ts\n" +
+		`#EXT-X-KEY:METHOD=SAMPLE-AES,URI="data:text/plain;base64,BBBB",KEYFORMAT="urn:uuid:edef8ba9-79d6-4ace-a3c8-27dcd51d21ed"` + "\n" +
+		`#EXT-X-KEY:METHOD=SAMPLE-AES,URI="skd://fairplay",KEYFORMAT="com.apple.streamingkeydelivery"` + "\n" +
+		"#EXTINF:4,\n" +
+		"b.ts\n"
+	var playlist *MediaPlaylist
+	err := Parse(strings.NewReader(input), testBaseURL, &ParserHandler{
+		HandleMediaPlaylist: func(p *MediaPlaylist) { playlist = p },
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	// the Widevine key rotates while the FairPlay key stays the same
+	periods := playlist.KeyPeriods()
+	if assert.Len(t, periods, 2) {
+		assert.Equal(t, uint64(1), periods[1].FirstMediaSequence)
+		if assert.Len(t, periods[1].Keys, 2) {
+			assert.Equal(t, "data:text/plain;base64,BBBB", periods[1].Keys[0].URI.String())
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package hls
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// CachingKeyProvider caches the keys obtained from another KeyProvider by
+// URI and KEYFORMAT. Concurrent requests for the same key share a single
+// fetch. Failures are not cached. It is safe for concurrent use.
+type CachingKeyProvider struct {
+	Provider KeyProvider      // [REQUIRED]
+	TTL      time.Duration    // [OPTIONAL][DEFAULT=0] how long keys are kept, forever when zero
+	Now      func() time.Time // [OPTIONAL][DEFAULT=time.Now]
+
+	mu      sync.Mutex
+	entries map[string]*keyCacheEntry
+}
+
+type keyCacheEntry struct {
+	done    chan struct{} // closed once the fetch is over
+	key     []byte
+	err     error
+	expires time.Time
+}
+
+func (p *CachingKeyProvider) now() time.Time {
+	if p.Now != nil {
+		return p.Now()
+	}
+	return time.Now()
+}
+
+func keyCacheID(k *Key) string {
+	if k.URI == nil {
+		return k.keyFormat() + " "
+	}
+	return k.keyFormat() + " " + k.URI.String()
+}
+
+func (p *CachingKeyProvider) Key(ctx context.Context, k *Key) (key []byte, err error) {
+	id := keyCacheID(k)
+	for {
+		p.mu.Lock()
+		if p.entries == nil {
+			p.entries = make(map[string]*keyCacheEntry)
+		}
+		entry := p.entries[id]
+		if entry != nil && isClosed(entry.done) && !entry.expires.IsZero() && !p.now().Before(entry.expires) {
+			delete(p.entries, id)
+			entry = nil
+		}
+		owner := entry == nil
+		if owner {
+			entry = &keyCacheEntry{done: make(chan struct{})}
+			p.entries[id] = entry
+		}
+		p.mu.Unlock()
+
+		if owner {
+			fetched, fetchErr := p.Provider.Key(ctx, k)
+			p.mu.Lock()
+			entry.key, entry.err = fetched, fetchErr
+			if fetchErr != nil {
+				if p.entries[id] == entry {
+					delete(p.entries, id)
+				}
+			} else if p.TTL > 0 {
+				entry.expires = p.now().Add(p.TTL)
+			}
+			p.mu.Unlock()
+			close(entry.done)
+		} else {
+			select {
+			case <-entry.done:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		// a fetch cancelled by the context of another caller is retried
+		if !owner && entry.err != nil && (errors.Is(entry.err, context.Canceled) || errors.Is(entry.err, context.DeadlineExceeded)) {
+			continue
+		}
+		if entry.err != nil {
+			return nil, entry.err
+		}
+		return append([]byte(nil), entry.key...), nil
+	}
+}
+
+// Forget removes a key from the cache, so that the next request fetches it
+// again.
+func (p *CachingKeyProvider) Forget(k *Key) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.entries, keyCacheID(k))
+}
+
+func isClosed(c chan struct{}) bool {
+	select {
+	case <-c:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,104 @@
+package hls
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type countingKeyProvider struct {
+	calls   int32
+	release chan struct{}
+	err     error
+}
+
+func (p *countingKeyProvider) Key(ctx context.Context, k *Key) ([]byte, error) {
+	atomic.AddInt32(&p.calls, 1)
+	if p.release != nil {
+		<-p.release
+	}
+	if p.err != nil {
+		return nil, p.err
+	}
+	return []byte(k.URI.String()), nil
+}
+
+func testKeyWithURI(uri string) *Key {
+	u, _ := url.Parse(uri)
+	return &Key{Method: KeyMethodAES128, URI: u}
+}
+
+func TestCachingKeyProvider(t *testing.T) {
+	source := &countingKeyProvider{release: make(chan struct{})}
+	now := time.Unix(0, 0)
+	provider := &CachingKeyProvider{Provider: source, TTL: time.Minute, Now: func() time.Time { return now }}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			key, err := provider.Key(context.Background(), testKeyWithURI("https://example.com/k1"))
+			assert.NoError(t, err)
+			assert.Equal(t, "https://example.com/k1", string(key))
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(source.release)
+	wg.Wait()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&source.calls))
+
+	_, err := provider.Key(context.Background(), testKeyWithURI("https://example.com/k2"))
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&source.calls))
+
+	now = now.Add(2 * time.Minute)
+	_, err = provider.Key(context.Background(), testKeyWithURI("https://example.com/k1"))
+	assert.NoError(t, err)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&source.calls))
+
+	provider.Forget(testKeyWithURI("https://example.com/k1"))
+	_, err = provider.Key(context.Background(), testKeyWithURI("https://example.com/k1"))
+	assert.NoError(t, err)
+	assert.Equal(t, int32(4), atomic.LoadInt32(&source.calls))
+}
+
+func TestCachingKeyProviderErrors(t *testing.T) {
+	failure := errors.New("unavailable")
+	source := &countingKeyProvider{err: failure}
+	provider := &CachingKeyProvider{Provider: source}
+	for i := 0; i < 2; i++ {
+		_, err := provider.Key(context.Background(), testKeyWithURI("https://example.com/k1"))
+		assert.ErrorIs(t, err, failure)
+	}
+	assert.Equal(t, int32(2), source.calls)
+}
+
+func TestHTTPKeyProviderHeaderFunc(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+r.URL.Path || r.Header.Get("X-Client") != "test" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write(testKey)
+	}))
+	defer server.Close()
+	provider := &HTTPKeyProvider{
+		Header: http.Header{"X-Client": {"test"}},
+		HeaderFunc: func(ctx context.Context, key *Key) (http.Header, error) {
+			return http.Header{"Authorization": {"Bearer " + key.URI.Path}}, nil
+		},
+	}
+	key, err := provider.Key(context.Background(), testKeyWithURI(server.URL+"/k1"))
+	assert.NoError(t, err)
+	assert.Equal(t, testKey, key)
+	assert.Equal(t, http.Header{"X-Client": {"test"}}, provider.Header)
+}
@@ -0,0 +1,102 @@
+package hls
+
+import (
+	"fmt"
+	"io"
+)
+
+// ParseLimits bounds the resources Parse and ParseAttributeList may consume
+// while reading a playlist. A zero field disables the corresponding limit.
+type ParseLimits struct {
+	MaxBytes                int64 // total number of bytes read from the playlist
+	MaxLineLength           int   // number of bytes in a single line, excluding the line terminator
+	MaxMediaSegments        int   // number of media segments in a media playlist
+	MaxStreams              int   // number of EXT-X-STREAM-INF and EXT-X-I-FRAME-STREAM-INF tags in a master playlist
+	MaxRenditions           int   // number of EXT-X-MEDIA tags in a master playlist
+	MaxRenditionGroups      int   // number of distinct rendition groups in a master playlist
+	MaxAttributes           int   // number of attributes in a single attribute list
+	MaxAttributeValueLength int   // number of bytes in a single attribute value
+}
+
+// DefaultParseLimits is used when ParserHandler.Limits is nil. The values are
+// far above what real world playlists need, but keep a hostile playlist from
+// exhausting memory.
+var DefaultParseLimits = ParseLimits{
+	MaxBytes:                64 << 20,
+	MaxLineLength:           64 << 10,
+	MaxMediaSegments:        100000,
+	MaxStreams:              10000,
+	MaxRenditions:           10000,
+	MaxRenditionGroups:      1000,
+	MaxAttributes:           256,
+	MaxAttributeValueLength: 32 << 10,
+}
+
+// NoParseLimits disables every limit.
+var NoParseLimits = ParseLimits{}
+
+func (limits *ParseLimits) checkMediaSegments(count int) error {
+	if limits.MaxMediaSegments > 0 && count > limits.MaxMediaSegments {
+		return fmt.Errorf("more than %d media segments: %w", limits.MaxMediaSegments, ErrTooManyMediaSegments)
+	}
+	return nil
+}
+
+func (limits *ParseLimits) checkStreams(count int) error {
+	if limits.MaxStreams > 0 && count > limits.MaxStreams {
+		return fmt.Errorf("more than %d streams: %w", limits.MaxStreams, ErrTooManyStreams)
+	}
+	return nil
+}
+
+func (limits *ParseLimits) checkRenditions(count int) error {
+	if limits.MaxRenditions > 0 && count > limits.MaxRenditions {
+		return fmt.Errorf("more than %d renditions: %w", limits.MaxRenditions, ErrTooManyRenditions)
+	}
+	return nil
+}
+
+func (limits *ParseLimits) checkRenditionGroups(count int) error {
+	if limits.MaxRenditionGroups > 0 && count > limits.MaxRenditionGroups {
+		return fmt.Errorf("more than %d rendition groups: %w", limits.MaxRenditionGroups, ErrTooManyRenditionGroups)
+	}
+	return nil
+}
+
+func (limits *ParseLimits) checkAttributes(count int) error {
+	if limits.MaxAttributes > 0 && count > limits.MaxAttributes {
+		return fmt.Errorf("more than %d attributes: %w", limits.MaxAttributes, ErrTooManyAttributes)
+	}
+	return nil
+}
+
+func (limits *ParseLimits) checkAttributeValueLength(length int) error {
+	if limits.MaxAttributeValueLength > 0 && length > limits.MaxAttributeValueLength {
+		return fmt.Errorf("attribute value longer than %d bytes: %w", limits.MaxAttributeValueLength, ErrAttributeValueTooLong)
+	}
+	return nil
+}
+
+// limitedReader is like io.LimitedReader but reports ErrPlaylistTooLarge
+// instead of io.EOF once the limit is crossed.
+type limitedReader struct {
+	r     io.Reader
+	limit int64
+	read  int64
+}
+
+func (lr *limitedReader) Read(p []byte) (n int, err error) {
+	if lr.read > lr.limit {
+		return 0, fmt.Errorf("more than %d bytes: %w", lr.limit, ErrPlaylistTooLarge)
+	}
+	if remaining := lr.limit - lr.read + 1; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+	n, err = lr.r.Read(p)
+	lr.read += int64(n)
+	if lr.read > lr.limit {
+		n -= int(lr.read - lr.limit)
+		err = fmt.Errorf("more than %d bytes: %w", lr.limit, ErrPlaylistTooLarge)
+	}
+	return
+}
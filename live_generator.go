@@ -0,0 +1,393 @@
+package hls
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// ErrPlaylistEnded is returned when appending to a LiveGenerator after End.
+var ErrPlaylistEnded = errors.New("live playlist has ended")
+
+// LiveGenerator builds the media playlist of a live stream being originated.
+//
+// Appended segments inherit the key and media initialization section of the
+// segment before them, as they would in a parsed playlist, and get their
+// Media Sequence Number and Discontinuity Sequence Number. In a sliding
+// window, the oldest segments are removed once the window lasts longer than
+// DVRDepth, never leaving less than three target durations, and
+// EXT-X-MEDIA-SEQUENCE and EXT-X-DISCONTINUITY-SEQUENCE follow the first
+// segment left. The EXT-X-KEY, EXT-X-MAP and EXT-X-PROGRAM-DATE-TIME tags in
+// effect are written again before the first segment of the window.
+//
+// A date range stays in the playlist while the segment it was appended with
+// is in the window, and after that until its end date is before the program
+// date time of the window.
+//
+// For Low-Latency HLS, the Partial Segments of the segment being produced are
+// appended with AppendPart as they become available, and become the parts of
+// the next segment appended. Parts are listed for the segments of the last
+// three target durations only.
+type LiveGenerator struct {
+	TargetDuration time.Duration // [REQUIRED] the maximum segment duration, rounded to the nearest second
+	DVRDepth       time.Duration // [OPTIONAL][DEFAULT=3 target durations] the duration of the sliding window
+	Event          bool          // [OPTIONAL][DEFAULT=false] keeps every segment, with EXT-X-PLAYLIST-TYPE:EVENT, instead of sliding
+	Version        uint64        // [OPTIONAL] the minimum EXT-X-VERSION, raised as the tags used require
+	PartTarget     time.Duration // [OPTIONAL] the maximum Partial Segment duration, enabling AppendPart
+
+	mu                    sync.Mutex
+	segments              []*liveSegment
+	dateRanges            []*liveDateRange
+	removedDateRanges     []*liveDateRange // mediaSequence being the next one when the date range was removed
+	parts                 []*PartialSegment
+	preloadHints          []*PreloadHint
+	mediaSequence         uint64 // of the next segment
+	discontinuitySequence uint64 // of the last segment
+	endList               bool
+	changed               chan struct{} // closed on every change
+}
+
+type liveSegment struct {
+	*MediaSegment
+	programDateTime *time.Time // explicit or extrapolated from the segments before
+}
+
+type liveDateRange struct {
+	*DateRange
+	mediaSequence uint64 // of the segment the date range was appended with
+}
+
+// Append adds a segment to the end of the playlist, with the date ranges
+// starting with it. The segment is copied; Duration and URI are required.
+func (g *LiveGenerator) Append(s *MediaSegment, dateRanges ...*DateRange) (err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.endList {
+		return ErrPlaylistEnded
+	}
+	if s.URI == nil {
+		return fmt.Errorf("media segment has no URI: %w", ErrFormat)
+	}
+	if s.Duration.Round(time.Second) > g.TargetDuration {
+		return fmt.Errorf("media segment duration %s exceeds target duration %s: %w", s.Duration, g.TargetDuration, ErrFormat)
+	}
+
+	segment := &liveSegment{MediaSegment: &MediaSegment{}}
+	*segment.MediaSegment = *s
+	var last *liveSegment
+	if n := len(g.segments); n > 0 {
+		last = g.segments[n-1]
+	}
+	if last != nil && segment.IsDiscontinuity {
+		g.discontinuitySequence += 1
+	}
+	segment.MediaSequence = g.mediaSequence
+	segment.DiscontinuitySequence = g.discontinuitySequence
+	if segment.Key != nil {
+		if len(segment.Keys) == 0 {
+			var keys []*Key
+			if last != nil {
+				keys = last.Keys
+			}
+			segment.Keys = activeKeys(keys, segment.Key)
+		}
+	} else if last != nil {
+		segment.Key, segment.Keys = last.Key, last.Keys
+	}
+	if len(segment.Parts) == 0 {
+		segment.Parts = g.parts
+	}
+	g.parts = nil
+	if segment.MediaInitMap == nil && last != nil {
+		segment.MediaInitMap = last.MediaInitMap
+	}
+	segment.programDateTime = segment.ProgramDateTime
+	if segment.programDateTime == nil && last != nil && last.programDateTime != nil && !segment.IsDiscontinuity {
+		t := last.programDateTime.Add(last.Duration)
+		segment.programDateTime = &t
+	}
+	g.mediaSequence += 1
+	g.segments = append(g.segments, segment)
+
+	for _, r := range dateRanges {
+		dateRange := &liveDateRange{DateRange: r, mediaSequence: segment.MediaSequence}
+		replaced := false
+		for i, existing := range g.dateRanges {
+			if existing.ID == r.ID {
+				g.dateRanges[i], replaced = dateRange, true
+			}
+		}
+		if !replaced {
+			g.dateRanges = append(g.dateRanges, dateRange)
+		}
+	}
+	g.slide()
+	g.notify()
+	return
+}
+
+// AppendPart adds a Partial Segment to the segment being produced. The part
+// is copied, and the PART preload hints for its URI are removed.
+func (g *LiveGenerator) AppendPart(p *PartialSegment) (err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.endList {
+		return ErrPlaylistEnded
+	}
+	if g.PartTarget <= 0 {
+		return fmt.Errorf("live playlist has no part target: %w", ErrFormat)
+	}
+	if p.URI == nil {
+		return fmt.Errorf("partial segment has no URI: %w", ErrFormat)
+	}
+	if p.Duration > g.PartTarget {
+		return fmt.Errorf("partial segment duration %s exceeds part target %s: %w", p.Duration, g.PartTarget, ErrFormat)
+	}
+	part := *p
+	g.parts = append(g.parts, &part)
+	// the playlists returned before share the hints
+	var hints []*PreloadHint
+	for _, hint := range g.preloadHints {
+		if hint.Type != PreloadHintTypePart || hint.URI.String() != part.URI.String() {
+			hints = append(hints, hint)
+		}
+	}
+	g.preloadHints = hints
+	g.notify()
+	return
+}
+
+// SetPreloadHints replaces the preload hints of the playlist.
+func (g *LiveGenerator) SetPreloadHints(hints ...*PreloadHint) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.preloadHints = append([]*PreloadHint(nil), hints...)
+	g.notify()
+}
+
+// notify wakes up the waiters on changes.
+func (g *LiveGenerator) notify() {
+	if g.changed != nil {
+		close(g.changed)
+		g.changed = nil
+	}
+}
+
+// changes returns a channel closed on the next change.
+func (g *LiveGenerator) changes() <-chan struct{} {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.changed == nil {
+		g.changed = make(chan struct{})
+	}
+	return g.changed
+}
+
+// lastPart returns the Media Sequence Number and the Part Index of the last
+// part, which is that of the segment being produced if it has any, partIndex
+// being -1 when the last segment has no parts and -2 when there is none.
+func (g *LiveGenerator) lastPart() (mediaSequence uint64, partIndex int) {
+	if len(g.parts) > 0 {
+		return g.mediaSequence, len(g.parts) - 1
+	}
+	if g.mediaSequence == 0 {
+		return 0, -2
+	}
+	mediaSequence = g.mediaSequence - 1
+	partIndex = -1
+	if n := len(g.segments); n > 0 {
+		partIndex = len(g.segments[n-1].Parts) - 1
+	}
+	return
+}
+
+// slide removes the segments and date ranges that fell out of the window.
+func (g *LiveGenerator) slide() {
+	if g.Event {
+		return
+	}
+	depth := g.DVRDepth
+	if floor := 3 * g.TargetDuration; depth < floor {
+		depth = floor
+	}
+	var total time.Duration
+	for _, s := range g.segments {
+		total += s.Duration
+	}
+	removed := 0
+	for removed < len(g.segments)-1 && total-g.segments[removed].Duration >= depth {
+		total -= g.segments[removed].Duration
+		removed += 1
+	}
+	if removed == 0 {
+		return
+	}
+	g.segments = append([]*liveSegment(nil), g.segments[removed:]...)
+
+	first := g.segments[0]
+	dateRanges := g.dateRanges[:0]
+	for _, r := range g.dateRanges {
+		if r.mediaSequence < first.MediaSequence {
+			end := r.End()
+			if first.programDateTime == nil || end == nil || end.Before(*first.programDateTime) {
+				g.removedDateRanges = append(g.removedDateRanges, &liveDateRange{DateRange: r.DateRange, mediaSequence: g.mediaSequence})
+				continue
+			}
+		}
+		dateRanges = append(dateRanges, r)
+	}
+	g.dateRanges = dateRanges
+	removedDateRanges := g.removedDateRanges[:0]
+	for _, r := range g.removedDateRanges {
+		if r.mediaSequence > first.MediaSequence {
+			removedDateRanges = append(removedDateRanges, r)
+		}
+	}
+	g.removedDateRanges = removedDateRanges
+}
+
+// End marks the playlist as complete with an EXT-X-ENDLIST tag, after which
+// no segment can be appended.
+func (g *LiveGenerator) End() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.endList = true
+	g.notify()
+}
+
+// Playlist returns the current playlist.
+func (g *LiveGenerator) Playlist() *MediaPlaylist {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.playlist(0, false)
+}
+
+// playlist returns the current playlist, as a Playlist Delta Update skipping
+// the segments older than skipUntil from the end, and the date ranges
+// appended with them if skipDateRanges, when skipUntil is not zero.
+func (g *LiveGenerator) playlist(skipUntil time.Duration, skipDateRanges bool) *MediaPlaylist {
+	playlist := &MediaPlaylist{
+		Playlist:              &Playlist{Version: g.version()},
+		TargetDuration:        g.TargetDuration,
+		MediaSequence:         g.mediaSequence,
+		DiscontinuitySequence: g.discontinuitySequence,
+		EndList:               g.endList,
+		PartTarget:            g.PartTarget,
+		Parts:                 g.parts,
+		PreloadHints:          g.preloadHints,
+	}
+	if g.Event {
+		playlistType := PlaylistTypeEvent
+		playlist.PlaylistType = &playlistType
+	}
+
+	// the segments starting before the skip boundary are skipped, and the
+	// parts of those ending before the last three target durations are left
+	// out
+	skipped := 0
+	if skipUntil > 0 {
+		var duration time.Duration
+		for i := len(g.segments) - 1; i >= 0; i-- {
+			if duration += g.segments[i].Duration; duration > skipUntil {
+				skipped = i + 1
+				break
+			}
+		}
+		if skipped == len(g.segments) {
+			skipped = 0
+		}
+	}
+	var recent time.Duration
+	parts := len(g.segments)
+	for parts > 0 && recent < 3*g.TargetDuration {
+		parts -= 1
+		recent += g.segments[parts].Duration
+	}
+	for i, s := range g.segments {
+		if i == 0 {
+			playlist.MediaSequence = s.MediaSequence
+			playlist.DiscontinuitySequence = s.DiscontinuitySequence
+		}
+		if i < skipped {
+			continue
+		}
+		segment := s.MediaSegment
+		if (i == 0 && segment.ProgramDateTime == nil && s.programDateTime != nil) || (i < parts && len(segment.Parts) > 0) {
+			copied := *segment
+			if i == 0 && segment.ProgramDateTime == nil {
+				copied.ProgramDateTime = s.programDateTime
+			}
+			if i < parts {
+				copied.Parts = nil
+			}
+			segment = &copied
+		}
+		playlist.MediaSegments = append(playlist.MediaSegments, segment)
+	}
+
+	var firstIncluded uint64
+	if skipped > 0 {
+		firstIncluded = g.segments[skipped].MediaSequence
+		playlist.Skip = &Skip{SkippedSegments: uint64(skipped)}
+		if playlist.Version < 9 {
+			playlist.Version = 9
+		}
+	}
+	for _, r := range g.dateRanges {
+		if skipped > 0 && skipDateRanges && r.mediaSequence < firstIncluded {
+			continue
+		}
+		playlist.DateRanges = append(playlist.DateRanges, r.DateRange)
+	}
+	if skipped > 0 && skipDateRanges {
+		// the date ranges removed since the client got the segments
+		// skipped
+		for _, r := range g.removedDateRanges {
+			if r.mediaSequence > firstIncluded {
+				playlist.Skip.RecentlyRemovedDateRanges = append(playlist.Skip.RecentlyRemovedDateRanges, r.ID)
+			}
+		}
+		if len(playlist.Skip.RecentlyRemovedDateRanges) > 0 && playlist.Version < 10 {
+			playlist.Version = 10
+		}
+	}
+	return playlist
+}
+
+// WriteTo writes the current playlist.
+func (g *LiveGenerator) WriteTo(w io.Writer) (n int64, err error) {
+	return g.Playlist().WriteTo(w)
+}
+
+// version returns the lowest compatibility version of the tags in use.
+func (g *LiveGenerator) version() (version uint64) {
+	version = 1
+	require := func(v uint64) {
+		if v > version {
+			version = v
+		}
+	}
+	require(g.Version)
+	for _, s := range g.segments {
+		if s.Duration%time.Second != 0 {
+			require(3)
+		}
+		if s.ByteRange != nil {
+			require(4)
+		}
+		if s.MediaInitMap != nil {
+			require(6)
+		}
+		for _, k := range s.activeKeys() {
+			if k.IV != nil {
+				require(2)
+			}
+			if k.KeyFormat != nil || len(k.KeyFormatVersions) > 0 {
+				require(5)
+			}
+		}
+	}
+	return
+}
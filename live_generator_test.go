@@ -0,0 +1,93 @@
+package hls
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLiveGenerator(t *testing.T) {
+	g := &LiveGenerator{TargetDuration: 4 * time.Second, DVRDepth: 12 * time.Second}
+	segment := func(name string) *MediaSegment {
+		u, _ := url.Parse("https://example.com/" + name)
+		return &MediaSegment{URI: u, Duration: 4 * time.Second}
+	}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	key, _ := url.Parse("https://example.com/k1")
+	initMap, _ := url.Parse("https://example.com/init.mp4")
+
+	a := segment("a.m4s")
+	a.Key = &Key{Method: KeyMethodAES128, URI: key}
+	a.MediaInitMap = &MediaInitMap{URI: initMap}
+	a.ProgramDateTime = &start
+	end := start.Add(6 * time.Second)
+	assert.NoError(t, g.Append(a, &DateRange{ID: "ad", StartDate: start, EndDate: &end}))
+	assert.NoError(t, g.Append(segment("b.m4s")))
+	c := segment("c.m4s")
+	c.IsDiscontinuity = true
+	assert.NoError(t, g.Append(c))
+	assert.NoError(t, g.Append(segment("d.m4s")))
+
+	var b strings.Builder
+	_, err := g.WriteTo(&b)
+	assert.NoError(t, err)
+	// a.m4s fell out, not its date range ending within b.m4s, which gets the
+	// key, map and date time in effect
+	assert.Equal(t, "#EXTM3U\n"+
+		"#EXT-X-VERSION:6\n"+
+		"#EXT-X-TARGETDURATION:4\n"+
+		"#EXT-X-MEDIA-SEQUENCE:1\n"+
+		`#EXT-X-DATERANGE:ID="ad",START-DATE="2024-01-01T00:00:00.000Z",END-DATE="2024-01-01T00:00:06.000Z"`+"\n"+
+		`#EXT-X-KEY:METHOD=AES-128,URI="https://example.com/k1"`+"\n"+
+		`#EXT-X-MAP:URI="https://example.com/init.mp4"`+"\n"+
+		"#EXT-X-PROGRAM-DATE-TIME:2024-01-01T00:00:04.000Z\n"+
+		"#EXTINF:4,\nhttps://example.com/b.m4s\n"+
+		"#EXT-X-DISCONTINUITY\n"+
+		"#EXTINF:4,\nhttps://example.com/c.m4s\n"+
+		"#EXTINF:4,\nhttps://example.com/d.m4s\n", b.String())
+
+	// the discontinuity falls out
+	assert.NoError(t, g.Append(segment("e.m4s")))
+	playlist := g.Playlist()
+	assert.Equal(t, uint64(2), playlist.MediaSequence)
+	assert.Equal(t, uint64(1), playlist.DiscontinuitySequence)
+	assert.False(t, strings.Contains(formatPlaylist(t, playlist), "#EXT-X-DISCONTINUITY\n"))
+	assert.Nil(t, playlist.MediaSegments[0].ProgramDateTime, "date time after a discontinuity")
+	assert.Empty(t, playlist.DateRanges)
+
+	u, _ := url.Parse("https://example.com/f.m4s")
+	assert.ErrorIs(t, g.Append(&MediaSegment{URI: u, Duration: 5 * time.Second}), ErrFormat)
+	g.End()
+	assert.ErrorIs(t, g.Append(segment("f.m4s")), ErrPlaylistEnded)
+	assert.True(t, strings.HasSuffix(formatPlaylist(t, g.Playlist()), "#EXT-X-ENDLIST\n"))
+}
+
+func TestLiveGeneratorEvent(t *testing.T) {
+	g := &LiveGenerator{TargetDuration: 4 * time.Second, Event: true}
+	for _, name := range []string{"a.ts", "b.ts", "c.ts", "d.ts", "e.ts"} {
+		u, _ := url.Parse("https://example.com/" + name)
+		assert.NoError(t, g.Append(&MediaSegment{URI: u, Duration: 4 * time.Second}))
+	}
+	playlist := g.Playlist()
+	assert.Len(t, playlist.MediaSegments, 5)
+	output := formatPlaylist(t, playlist)
+	assert.True(t, strings.Contains(output, "#EXT-X-PLAYLIST-TYPE:EVENT\n"), output)
+
+	// the window never lasts less than three target durations
+	g = &LiveGenerator{TargetDuration: 4 * time.Second, DVRDepth: time.Second}
+	for _, name := range []string{"a.ts", "b.ts", "c.ts", "d.ts", "e.ts"} {
+		u, _ := url.Parse("https://example.com/" + name)
+		assert.NoError(t, g.Append(&MediaSegment{URI: u, Duration: 4 * time.Second}))
+	}
+	assert.Len(t, g.Playlist().MediaSegments, 3)
+}
+
+func formatPlaylist(t *testing.T, p *MediaPlaylist) string {
+	var b strings.Builder
+	_, err := p.WriteTo(&b)
+	assert.NoError(t, err)
+	return b.String()
+}
@@ -0,0 +1,168 @@
+package hls
+
+import (
+	"bytes"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// LiveHandler serves the media playlist of a LiveGenerator with the delivery
+// directives of Low-Latency HLS.
+//
+// A request with _HLS_msn, and optionally _HLS_part, is a Blocking Playlist
+// Reload: the response is held until the playlist has the segment, or the
+// part of the segment, requested, and is 503 Service Unavailable when it
+// still has not after BlockTimeout. A request with _HLS_skip=YES gets a
+// Playlist Delta Update skipping the segments older than the Skip Boundary,
+// and with _HLS_skip=v2 the date ranges appended with them as well.
+type LiveHandler struct {
+	Generator        *LiveGenerator            // [REQUIRED]
+	RenditionReports map[string]*LiveGenerator // [OPTIONAL] the playlists of the associated renditions by URI, relative to this one, for EXT-X-RENDITION-REPORT tags
+	BlockTimeout     time.Duration             // [OPTIONAL][DEFAULT=3 target durations] how long a Blocking Playlist Reload is held at most
+	CanSkipUntil     time.Duration             // [OPTIONAL][DEFAULT=6 target durations] the Skip Boundary of Playlist Delta Updates, raised to 6 target durations if lower
+	NoDeltaUpdates   bool                      // [OPTIONAL][DEFAULT=false] disables Playlist Delta Updates
+}
+
+func (h *LiveHandler) serverControl() *ServerControl {
+	g := h.Generator
+	holdBack := 3 * g.TargetDuration
+	c := &ServerControl{HoldBack: &holdBack, CanBlockReload: true}
+	if g.PartTarget > 0 {
+		partHoldBack := 3 * g.PartTarget
+		c.PartHoldBack = &partHoldBack
+	}
+	if !h.NoDeltaUpdates {
+		// the Skip Boundary must be at least six target durations
+		canSkipUntil := h.CanSkipUntil
+		if canSkipUntil < 6*g.TargetDuration {
+			canSkipUntil = 6 * g.TargetDuration
+		}
+		c.CanSkipUntil = &canSkipUntil
+		c.CanSkipDateRanges = true
+	}
+	return c
+}
+
+func (h *LiveHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	g := h.Generator
+	query := r.URL.Query()
+	var (
+		mediaSequence uint64
+		partIndex     uint64
+		hasPart       bool
+		err           error
+	)
+	if value := query.Get("_HLS_msn"); value != "" {
+		if mediaSequence, err = strconv.ParseUint(value, 10, 64); err != nil {
+			http.Error(w, "invalid _HLS_msn", http.StatusBadRequest)
+			return
+		}
+		if value = query.Get("_HLS_part"); value != "" {
+			if partIndex, err = strconv.ParseUint(value, 10, 64); err != nil {
+				http.Error(w, "invalid _HLS_part", http.StatusBadRequest)
+				return
+			}
+			hasPart = true
+		}
+		g.mu.Lock()
+		// the request must not be more than two segments ahead of the last
+		// one
+		tooFar := mediaSequence > g.mediaSequence+1
+		g.mu.Unlock()
+		if tooFar {
+			http.Error(w, "_HLS_msn is too far ahead of the playlist", http.StatusBadRequest)
+			return
+		}
+		if !h.wait(r, mediaSequence, partIndex, hasPart) {
+			if r.Context().Err() == nil {
+				http.Error(w, "the requested segment is not available yet", http.StatusServiceUnavailable)
+			}
+			return
+		}
+	} else if query.Get("_HLS_part") != "" {
+		http.Error(w, "_HLS_part without _HLS_msn", http.StatusBadRequest)
+		return
+	}
+
+	control := h.serverControl()
+	var skipUntil time.Duration
+	skip := query.Get("_HLS_skip")
+	if control.CanSkipUntil != nil && (skip == "YES" || skip == "v2") {
+		skipUntil = *control.CanSkipUntil
+	}
+	g.mu.Lock()
+	playlist := g.playlist(skipUntil, skip == "v2")
+	g.mu.Unlock()
+	playlist.ServerControl = control
+	playlist.RenditionReports = h.renditionReports()
+
+	var b bytes.Buffer
+	if _, err = playlist.WriteTo(&b); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+	w.Header().Set("Content-Length", strconv.Itoa(b.Len()))
+	_, _ = b.WriteTo(w)
+}
+
+// wait waits until the playlist has the segment, or the part of the segment
+// requested, or has ended, and reports whether it did before the timeout.
+func (h *LiveHandler) wait(r *http.Request, mediaSequence, partIndex uint64, hasPart bool) bool {
+	g := h.Generator
+	timeout := h.BlockTimeout
+	if timeout <= 0 {
+		timeout = 3 * g.TargetDuration
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		changed := g.changes()
+		g.mu.Lock()
+		// a segment published is complete, with all of its parts
+		available := g.endList || mediaSequence < g.mediaSequence ||
+			(hasPart && mediaSequence == g.mediaSequence && partIndex < uint64(len(g.parts)))
+		g.mu.Unlock()
+		if available {
+			return true
+		}
+		select {
+		case <-changed:
+		case <-timer.C:
+			return false
+		case <-r.Context().Done():
+			return false
+		}
+	}
+}
+
+func (h *LiveHandler) renditionReports() (reports []*RenditionReport) {
+	uris := make([]string, 0, len(h.RenditionReports))
+	for uri := range h.RenditionReports {
+		uris = append(uris, uri)
+	}
+	sort.Strings(uris)
+	for _, uri := range uris {
+		u, err := url.Parse(uri)
+		if err != nil {
+			continue
+		}
+		report := &RenditionReport{URI: u}
+		g := h.RenditionReports[uri]
+		g.mu.Lock()
+		mediaSequence, partIndex := g.lastPart()
+		g.mu.Unlock()
+		if partIndex > -2 {
+			report.LastMSN = &mediaSequence
+		}
+		if partIndex >= 0 {
+			lastPart := uint64(partIndex)
+			report.LastPart = &lastPart
+		}
+		reports = append(reports, report)
+	}
+	return
+}
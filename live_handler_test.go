@@ -0,0 +1,208 @@
+package hls
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestLLGenerator(t *testing.T, segments int) *LiveGenerator {
+	g := &LiveGenerator{TargetDuration: 4 * time.Second, PartTarget: time.Second, DVRDepth: time.Minute}
+	for i := 0; i < segments; i++ {
+		for j := 0; j < 4; j++ {
+			u, _ := url.Parse(fmt.Sprintf("https://example.com/s%d.%d.m4s", i, j))
+			assert.NoError(t, g.AppendPart(&PartialSegment{URI: u, Duration: time.Second, Independent: j == 0}))
+		}
+		u, _ := url.Parse(fmt.Sprintf("https://example.com/s%d.m4s", i))
+		assert.NoError(t, g.Append(&MediaSegment{URI: u, Duration: 4 * time.Second}))
+	}
+	return g
+}
+
+func getTestPlaylist(t *testing.T, server *httptest.Server, query string) (status int, playlist *MediaPlaylist, body string) {
+	resp, err := http.Get(server.URL + "/live.m3u8" + query)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	status, body = resp.StatusCode, string(data)
+	if status == http.StatusOK {
+		playlist = parseTestMediaPlaylist(t, server.URL, strings.TrimPrefix(body, "#EXTM3U\n"))
+	}
+	return
+}
+
+func TestLiveHandler(t *testing.T) {
+	g := newTestLLGenerator(t, 4)
+	u, _ := url.Parse("https://example.com/s4.0.m4s")
+	assert.NoError(t, g.AppendPart(&PartialSegment{URI: u, Duration: time.Second, Independent: true}))
+	u, _ = url.Parse("https://example.com/s4.1.m4s")
+	g.SetPreloadHints(&PreloadHint{Type: PreloadHintTypePart, URI: u})
+	audio := newTestLLGenerator(t, 3)
+	server := httptest.NewServer(&LiveHandler{Generator: g, RenditionReports: map[string]*LiveGenerator{"audio.m3u8": audio}})
+	defer server.Close()
+
+	status, playlist, body := getTestPlaylist(t, server, "")
+	if !assert.Equal(t, http.StatusOK, status, body) {
+		return
+	}
+	assert.True(t, strings.Contains(body, "#EXT-X-SERVER-CONTROL:CAN-SKIP-UNTIL=24,CAN-SKIP-DATERANGES=YES,HOLD-BACK=12,PART-HOLD-BACK=3,CAN-BLOCK-RELOAD=YES\n"), body)
+	assert.True(t, playlist.ServerControl.CanBlockReload)
+	assert.Equal(t, time.Second, playlist.PartTarget)
+	assert.Len(t, playlist.MediaSegments, 4)
+	// parts are only listed for the last three target durations
+	var parts []int
+	for _, s := range playlist.MediaSegments {
+		parts = append(parts, len(s.Parts))
+	}
+	assert.Equal(t, []int{0, 4, 4, 4}, parts)
+	assert.Equal(t, "https://example.com/s3.2.m4s", playlist.MediaSegments[3].Parts[2].URI.String())
+	if assert.Len(t, playlist.Parts, 1) {
+		assert.True(t, playlist.Parts[0].Independent)
+	}
+	if assert.Len(t, playlist.PreloadHints, 1) {
+		assert.Equal(t, "https://example.com/s4.1.m4s", playlist.PreloadHints[0].URI.String())
+	}
+	if assert.Len(t, playlist.RenditionReports, 1) {
+		report := playlist.RenditionReports[0]
+		assert.Equal(t, server.URL+"/audio.m3u8", report.URI.String())
+		assert.Equal(t, uint64(2), *report.LastMSN)
+		assert.Equal(t, uint64(3), *report.LastPart)
+	}
+
+	for _, query := range []string{"?_HLS_part=1", "?_HLS_msn=6", "?_HLS_msn=x"} {
+		status, _, _ := getTestPlaylist(t, server, query)
+		assert.Equal(t, http.StatusBadRequest, status, query)
+	}
+}
+
+func TestLiveHandlerBlockingReload(t *testing.T) {
+	g := newTestLLGenerator(t, 1)
+	server := httptest.NewServer(&LiveHandler{Generator: g, BlockTimeout: time.Minute})
+	defer server.Close()
+
+	type result struct {
+		status   int
+		playlist *MediaPlaylist
+	}
+	results := make(chan result)
+	go func() {
+		status, playlist, _ := getTestPlaylist(t, server, "?_HLS_msn=1&_HLS_part=1")
+		results <- result{status, playlist}
+	}()
+	for i := 0; i < 2; i++ {
+		select {
+		case <-results:
+			t.Fatal("the reload did not block")
+		case <-time.After(20 * time.Millisecond):
+		}
+		u, _ := url.Parse(fmt.Sprintf("https://example.com/s1.%d.m4s", i))
+		assert.NoError(t, g.AppendPart(&PartialSegment{URI: u, Duration: time.Second}))
+	}
+	r := <-results
+	if assert.Equal(t, http.StatusOK, r.status) {
+		assert.Len(t, r.playlist.Parts, 2)
+	}
+
+	// a published segment satisfies the requests for its parts
+	status, _, _ := getTestPlaylist(t, server, "?_HLS_msn=0&_HLS_part=9")
+	assert.Equal(t, http.StatusOK, status)
+
+	server.Config.Handler = &LiveHandler{Generator: g, BlockTimeout: 10 * time.Millisecond}
+	status, _, _ = getTestPlaylist(t, server, "?_HLS_msn=2")
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	g.End()
+	status, playlist, _ := getTestPlaylist(t, server, "?_HLS_msn=2")
+	if assert.Equal(t, http.StatusOK, status) {
+		assert.True(t, playlist.EndList)
+	}
+}
+
+func TestLiveHandlerDeltaUpdate(t *testing.T) {
+	g := &LiveGenerator{TargetDuration: 4 * time.Second, DVRDepth: 40 * time.Second}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 12; i++ {
+		u, _ := url.Parse(fmt.Sprintf("https://example.com/s%d.ts", i))
+		s := &MediaSegment{URI: u, Duration: 4 * time.Second}
+		var dateRanges []*DateRange
+		if i == 0 {
+			s.ProgramDateTime = &start
+		}
+		if i == 1 || i == 9 {
+			dateRanges = append(dateRanges, &DateRange{ID: fmt.Sprintf("d%d", i), StartDate: start.Add(time.Duration(i) * 4 * time.Second)})
+		}
+		assert.NoError(t, g.Append(s, dateRanges...))
+	}
+	server := httptest.NewServer(&LiveHandler{Generator: g})
+	defer server.Close()
+
+	status, full, _ := getTestPlaylist(t, server, "")
+	if !assert.Equal(t, http.StatusOK, status) {
+		return
+	}
+	assert.Equal(t, uint64(2), full.MediaSequence)
+	assert.Len(t, full.MediaSegments, 10)
+	assert.Len(t, full.DateRanges, 1)
+
+	_, delta, body := getTestPlaylist(t, server, "?_HLS_skip=YES")
+	if assert.NotNil(t, delta.Skip, body) {
+		// the segments starting more than 24s before the end are skipped
+		assert.Equal(t, uint64(4), delta.Skip.SkippedSegments)
+		assert.Equal(t, uint64(2), delta.MediaSequence)
+		assert.Equal(t, uint64(6), delta.MediaSegments[0].MediaSequence)
+		assert.Len(t, delta.MediaSegments, 6)
+		assert.Len(t, delta.DateRanges, 1)
+		assert.Equal(t, uint64(9), delta.Version)
+	}
+
+	_, delta, body = getTestPlaylist(t, server, "?_HLS_skip=v2")
+	if assert.NotNil(t, delta.Skip, body) {
+		assert.Len(t, delta.DateRanges, 1)
+		// d1 was removed with s1, after s6 was published
+		assert.Equal(t, []string{"d1"}, delta.Skip.RecentlyRemovedDateRanges)
+	}
+
+	// a Skip Boundary below six target durations is raised
+	server.Config.Handler = &LiveHandler{Generator: g, CanSkipUntil: 12 * time.Second}
+	_, delta, body = getTestPlaylist(t, server, "?_HLS_skip=YES")
+	if assert.NotNil(t, delta.Skip, body) {
+		assert.Equal(t, 24*time.Second, *delta.ServerControl.CanSkipUntil)
+		assert.Equal(t, uint64(4), delta.Skip.SkippedSegments)
+	}
+
+	server.Config.Handler = &LiveHandler{Generator: g, CanSkipUntil: 28 * time.Second}
+	_, delta, body = getTestPlaylist(t, server, "?_HLS_skip=v2")
+	if assert.NotNil(t, delta.Skip, body) {
+		assert.Equal(t, uint64(3), delta.Skip.SkippedSegments)
+		// d9 was appended with s9, after the segments skipped
+		if assert.Len(t, delta.DateRanges, 1) {
+			assert.Equal(t, "d9", delta.DateRanges[0].ID)
+		}
+		assert.Equal(t, uint64(10), delta.Version)
+	}
+}
+
+func TestLiveHandlerDeltaUpdateDiscontinuity(t *testing.T) {
+	g := &LiveGenerator{TargetDuration: 2 * time.Second, DVRDepth: time.Minute}
+	for i := 0; i < 30; i++ {
+		u, _ := url.Parse(fmt.Sprintf("https://example.com/s%d.ts", i))
+		assert.NoError(t, g.Append(&MediaSegment{URI: u, Duration: 2 * time.Second, IsDiscontinuity: i == 24}))
+	}
+	server := httptest.NewServer(&LiveHandler{Generator: g})
+	defer server.Close()
+
+	// the discontinuity is right at the Skip Boundary
+	_, delta, body := getTestPlaylist(t, server, "?_HLS_skip=YES")
+	if assert.NotNil(t, delta.Skip, body) && assert.Equal(t, uint64(24), delta.MediaSegments[0].MediaSequence, body) {
+		assert.True(t, delta.MediaSegments[0].IsDiscontinuity, body)
+		assert.Equal(t, uint64(1), delta.MediaSegments[0].DiscontinuitySequence, body)
+	}
+}
@@ -0,0 +1,270 @@
+package hls
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+// Doer sends HTTP requests. *http.Client implements it.
+type Doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// HTTPStatusError is returned when a server answers with an unexpected
+// status code.
+type HTTPStatusError struct {
+	URL        string
+	StatusCode int
+	Status     string
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("GET %s: unexpected status %s", e.URL, e.Status)
+}
+
+// Loader fetches a playlist and, for a master playlist, every media playlist
+// it references.
+type Loader struct {
+	Client       Doer         // [OPTIONAL][DEFAULT=http.DefaultClient]
+	Header       http.Header  // [OPTIONAL] added to every request
+	Concurrency  int          // [OPTIONAL][DEFAULT=4] the maximum number of concurrent requests
+	MaxRedirects int          // [OPTIONAL][DEFAULT=10] for clients that return redirect responses instead of following them
+	Limits       *ParseLimits // [OPTIONAL][DEFAULT=&DefaultParseLimits] passed to the parser as ParserHandler.Limits
+	Lenient      bool         // [OPTIONAL][DEFAULT=false] passed to the parser as ParserHandler.Lenient
+}
+
+// Presentation is a loaded master playlist with its media playlists. When
+// the loaded URL is a media playlist, only Media is set.
+type Presentation struct {
+	URL            *url.URL                          // the URL of the loaded playlist after redirects
+	Master         *MasterPlaylist                   // [OPTIONAL]
+	Media          *MediaPlaylist                    // [OPTIONAL]
+	VariantStreams map[*VariantStream]*MediaPlaylist // the media playlist of each Variant Stream
+	IframeStreams  map[*IframeStream]*MediaPlaylist  // the media playlist of each I-frame stream
+	Renditions     map[*Rendition]*MediaPlaylist     // the media playlist of each rendition with a URI
+	MediaPlaylists map[string]*MediaPlaylist         // every media playlist, by the URI referencing it
+}
+
+// Load fetches the playlist at u. For a master playlist, every media
+// playlist referenced by a Variant Stream, I-frame stream or rendition is
+// fetched concurrently, each URI once. The first failure cancels the rest.
+func (l *Loader) Load(ctx context.Context, u *url.URL) (p *Presentation, err error) {
+	p = &Presentation{}
+	if p.URL, p.Master, p.Media, err = l.load(ctx, u); err != nil {
+		return
+	}
+	if p.Master == nil {
+		return
+	}
+
+	var uris []string
+	targets := make(map[string]*url.URL)
+	addURI := func(u *url.URL) {
+		key := u.String()
+		if _, ok := targets[key]; !ok {
+			targets[key] = u
+			uris = append(uris, key)
+		}
+	}
+	for _, s := range p.Master.VariantStreams {
+		addURI(s.URI)
+	}
+	for _, s := range p.Master.IframeStreams {
+		addURI(s.URI)
+	}
+	for _, renditionType := range []RenditionType{Audio, Video, Subtitles, ClosedCaptions} {
+		for _, group := range p.Master.RenditionGroups[renditionType] {
+			for _, r := range group {
+				if r.URI != nil {
+					addURI(r.URI)
+				}
+			}
+		}
+	}
+
+	if p.MediaPlaylists, err = l.loadAll(ctx, uris, targets); err != nil {
+		return
+	}
+	p.VariantStreams = make(map[*VariantStream]*MediaPlaylist)
+	for _, s := range p.Master.VariantStreams {
+		p.VariantStreams[s] = p.MediaPlaylists[s.URI.String()]
+	}
+	p.IframeStreams = make(map[*IframeStream]*MediaPlaylist)
+	for _, s := range p.Master.IframeStreams {
+		p.IframeStreams[s] = p.MediaPlaylists[s.URI.String()]
+	}
+	p.Renditions = make(map[*Rendition]*MediaPlaylist)
+	for _, groups := range p.Master.RenditionGroups {
+		for _, group := range groups {
+			for _, r := range group {
+				if r.URI != nil {
+					p.Renditions[r] = p.MediaPlaylists[r.URI.String()]
+				}
+			}
+		}
+	}
+	return
+}
+
+// LoadMediaPlaylist fetches the media playlist at u, returning the URL it was
+// served from after redirects.
+func (l *Loader) LoadMediaPlaylist(ctx context.Context, u *url.URL) (playlist *MediaPlaylist, finalURL *url.URL, err error) {
+	if finalURL, _, playlist, err = l.load(ctx, u); err != nil {
+		return
+	}
+	if playlist == nil {
+		err = fmt.Errorf("%s is not a media playlist: %w", finalURL, ErrFormat)
+	}
+	return
+}
+
+func (l *Loader) loadAll(ctx context.Context, uris []string, targets map[string]*url.URL) (playlists map[string]*MediaPlaylist, err error) {
+	concurrency := l.Concurrency
+	if concurrency <= 0 {
+		concurrency = 4
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu  sync.Mutex
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, concurrency)
+	)
+	playlists = make(map[string]*MediaPlaylist, len(uris))
+	for _, uri := range uris {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func(uri string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			playlist, _, e := l.LoadMediaPlaylist(ctx, targets[uri])
+			mu.Lock()
+			defer mu.Unlock()
+			if e != nil {
+				if err == nil {
+					err = e
+					cancel()
+				}
+				return
+			}
+			playlists[uri] = playlist
+		}(uri)
+	}
+	wg.Wait()
+	if err == nil {
+		err = ctx.Err()
+	}
+	return
+}
+
+func (l *Loader) load(ctx context.Context, u *url.URL) (finalURL *url.URL, master *MasterPlaylist, media *MediaPlaylist, err error) {
+	var body io.Reader
+	if u.Scheme == "data" {
+		var data []byte
+		if _, data, err = decodeDataURI(u); err != nil {
+			return
+		}
+		body = bytes.NewReader(data)
+	} else {
+		var resp *http.Response
+		if resp, u, err = l.get(ctx, u); err != nil {
+			return
+		}
+		defer resp.Body.Close()
+		body = resp.Body
+	}
+	finalURL = u
+
+	err = Parse(body, u, &ParserHandler{
+		HandleMasterPlaylist: func(p *MasterPlaylist) { master = p },
+		HandleMediaPlaylist:  func(p *MediaPlaylist) { media = p },
+		Limits:               l.Limits,
+		Lenient:              l.Lenient,
+	})
+	if err != nil {
+		err = fmt.Errorf("failed parsing %s: %w", u, err)
+	}
+	return
+}
+
+// get GETs a playlist, following redirects, and returns the 200 response
+// and the URL it was served from.
+func (l *Loader) get(ctx context.Context, u *url.URL) (resp *http.Response, finalURL *url.URL, err error) {
+	client := l.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	maxRedirects := l.MaxRedirects
+	if maxRedirects <= 0 {
+		maxRedirects = 10
+	}
+
+	for redirects := 0; ; redirects++ {
+		var req *http.Request
+		if req, err = http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil); err != nil {
+			return
+		}
+		for name, values := range l.Header {
+			req.Header[name] = values
+		}
+		if resp, err = client.Do(req); err != nil {
+			return
+		}
+		if resp.Request != nil && resp.Request.URL != nil {
+			u = resp.Request.URL
+		}
+		location := resp.Header.Get("Location")
+		if resp.StatusCode < 300 || resp.StatusCode >= 400 || location == "" {
+			break
+		}
+		resp.Body.Close()
+		if redirects >= maxRedirects {
+			err = fmt.Errorf("GET %s: stopped after %d redirects", u, maxRedirects)
+			return
+		}
+		var ref *url.URL
+		if ref, err = url.Parse(location); err != nil {
+			err = fmt.Errorf("GET %s: invalid Location header: %w", u, err)
+			return
+		}
+		u = u.ResolveReference(ref)
+	}
+	finalURL = u
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		err = &HTTPStatusError{URL: u.String(), StatusCode: resp.StatusCode, Status: resp.Status}
+		resp = nil
+	}
+	return
+}
+
+// ReadSessionData returns the data of an EXT-X-SESSION-DATA tag: its VALUE,
+// or else the resource of its URI, which may be a data: URI. JSON resources
+// are checked to be valid JSON.
+func (l *Loader) ReadSessionData(ctx context.Context, d *SessionData) (data []byte, err error) {
+	if d.Value != nil {
+		return []byte(*d.Value), nil
+	}
+	if data, err = ReadResource(ctx, l.Client, l.Header, d.URI, nil); err != nil {
+		return
+	}
+	if d.Format == SessionDataFormatJSON && !json.Valid(data) {
+		err = fmt.Errorf("session data %s from %s is not valid JSON: %w", d.DataID, d.URI, ErrFormat)
+	}
+	return
+}
@@ -0,0 +1,109 @@
+package hls
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestOrigin(t *testing.T) (server *httptest.Server, requests map[string]int) {
+	requests = map[string]int{}
+	var mu sync.Mutex
+	media := "#EXTM3U\n#EXT-X-TARGETDURATION:4\n#EXTINF:4,\nseg0.ts\n#EXT-X-ENDLIST\n"
+	files := map[string]string{
+		"/master.m3u8": "#EXTM3U\n" +
+			`#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aac-lo",NAME="English",LANGUAGE="en",URI="audio/en.m3u8"` + "\n" +
+			`#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aac-hi",NAME="English",LANGUAGE="en",URI="audio/en.m3u8"` + "\n" +
+			`#EXT-X-I-FRAME-STREAM-INF:BANDWIDTH=100000,URI="iframe.m3u8"` + "\n" +
+			`#EXT-X-STREAM-INF:BANDWIDTH=800000,AUDIO="aac-lo"` + "\n" +
+			"low.m3u8\n" +
+			`#EXT-X-STREAM-INF:BANDWIDTH=3000000,AUDIO="aac-hi"` + "\n" +
+			"high.m3u8\n",
+		"/audio/en.m3u8":   media,
+		"/iframe.m3u8":     media,
+		"/low.m3u8":        media,
+		"/moved/high.m3u8": media,
+	}
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[r.URL.Path]++
+		mu.Unlock()
+		assert.Equal(t, "test", r.Header.Get("X-Token"))
+		switch r.URL.Path {
+		case "/index.m3u8":
+			http.Redirect(w, r, "/master.m3u8", http.StatusFound)
+			return
+		case "/high.m3u8":
+			http.Redirect(w, r, "/moved/high.m3u8", http.StatusMovedPermanently)
+			return
+		}
+		body, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	return
+}
+
+func TestLoader(t *testing.T) {
+	server, requests := newTestOrigin(t)
+	defer server.Close()
+	u, _ := url.Parse(server.URL + "/index.m3u8")
+
+	noFollow := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	for _, client := range []Doer{http.DefaultClient, noFollow} {
+		for path := range requests {
+			delete(requests, path)
+		}
+		loader := &Loader{Client: client, Header: http.Header{"X-Token": {"test"}}, Concurrency: 2}
+		p, err := loader.Load(context.Background(), u)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, server.URL+"/master.m3u8", p.URL.String())
+		assert.Nil(t, p.Media)
+		assert.Len(t, p.MediaPlaylists, 4)
+		assert.Equal(t, 1, requests["/audio/en.m3u8"])
+
+		low, high := p.Master.VariantStreams[0], p.Master.VariantStreams[1]
+		assert.Equal(t, server.URL+"/seg0.ts", p.VariantStreams[low].MediaSegments[0].URI.String())
+		assert.Equal(t, server.URL+"/moved/seg0.ts", p.VariantStreams[high].MediaSegments[0].URI.String())
+		assert.NotNil(t, p.IframeStreams[p.Master.IframeStreams[0]])
+
+		lo := p.Master.Renditions(low, Audio)[0]
+		hi := p.Master.Renditions(high, Audio)[0]
+		assert.Equal(t, server.URL+"/audio/en.m3u8", lo.URI.String())
+		assert.Same(t, p.Renditions[lo], p.Renditions[hi])
+		assert.Equal(t, server.URL+"/audio/seg0.ts", p.Renditions[lo].MediaSegments[0].URI.String())
+	}
+}
+
+func TestLoaderErrors(t *testing.T) {
+	server, _ := newTestOrigin(t)
+	defer server.Close()
+	loader := &Loader{Header: http.Header{"X-Token": {"test"}}}
+
+	u, _ := url.Parse(server.URL + "/missing.m3u8")
+	_, err := loader.Load(context.Background(), u)
+	var statusErr *HTTPStatusError
+	if assert.True(t, errors.As(err, &statusErr)) {
+		assert.Equal(t, http.StatusNotFound, statusErr.StatusCode)
+	}
+
+	u, _ = url.Parse(server.URL + "/master.m3u8")
+	_, _, err = loader.LoadMediaPlaylist(context.Background(), u)
+	assert.ErrorIs(t, err, ErrFormat)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = loader.Load(ctx, u)
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,420 @@
+package hls
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+var ErrInvalidTarget = errors.New("invalid attribute marshaling target")
+
+// AttributeUnmarshaler is implemented by types that decode themselves from an
+// attribute value.
+type AttributeUnmarshaler interface {
+	UnmarshalAttribute(value *Value) error
+}
+
+// AttributeMarshaler is implemented by types that encode themselves as an
+// attribute value.
+type AttributeMarshaler interface {
+	MarshalAttribute() (*Value, error)
+}
+
+var (
+	attributeUnmarshalerType = reflect.TypeOf((*AttributeUnmarshaler)(nil)).Elem()
+	attributeMarshalerType   = reflect.TypeOf((*AttributeMarshaler)(nil)).Elem()
+	resolutionType           = reflect.TypeOf(Resolution{})
+	timeType                 = reflect.TypeOf(time.Time{})
+	bytesType                = reflect.TypeOf([]byte(nil))
+	stringsType              = reflect.TypeOf([]string(nil))
+)
+
+// valueKind is how an attribute value is read or written. The set differs
+// from Type since the specification also distinguishes unsigned integers,
+// YES/NO enums and numbers that may be written either as integers or as
+// floats.
+type valueKind string
+
+const (
+	stringKind     valueKind = "string"
+	enumKind       valueKind = "enum"
+	intKind        valueKind = "int"
+	uintKind       valueKind = "uint"
+	floatKind      valueKind = "float"
+	numberKind     valueKind = "number"
+	bytesKind      valueKind = "bytes"
+	resolutionKind valueKind = "resolution"
+	yesNoKind      valueKind = "yesno"
+)
+
+// attributeField describes a struct field tagged with
+//
+//	hls:"NAME[,required][,omitempty][,kind[|kind...]]"
+//
+// where kind is one of string, enum, int, uint, float, number, bytes,
+// resolution and yesno. When several kinds are given, unmarshaling accepts
+// any of them and marshaling uses the first, but for NONE written as an enum
+// when enum is one of them. Without a kind it is inferred from the Go type of
+// the field.
+type attributeField struct {
+	name      string
+	index     []int
+	required  bool
+	omitEmpty bool
+	kinds     []valueKind
+}
+
+func parseAttributeFieldTag(field reflect.StructField, tag string) (f attributeField, err error) {
+	parts := strings.Split(tag, ",")
+	f.name = parts[0]
+	for _, option := range parts[1:] {
+		switch option {
+		case "required":
+			f.required = true
+		case "omitempty":
+			f.omitEmpty = true
+		default:
+			for _, kind := range strings.Split(option, "|") {
+				switch valueKind(kind) {
+				case stringKind, enumKind, intKind, uintKind, floatKind, numberKind, bytesKind, resolutionKind, yesNoKind:
+					f.kinds = append(f.kinds, valueKind(kind))
+				default:
+					err = fmt.Errorf("field %s has unknown hls tag option %q: %w", field.Name, kind, ErrInvalidTarget)
+					return
+				}
+			}
+		}
+	}
+	if len(f.kinds) == 0 {
+		t := field.Type
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if kind, ok := defaultValueKind(t); ok {
+			f.kinds = []valueKind{kind}
+		}
+	}
+	return
+}
+
+func defaultValueKind(t reflect.Type) (kind valueKind, ok bool) {
+	switch {
+	case t == resolutionType:
+		return resolutionKind, true
+	case t == timeType, t == stringsType:
+		return stringKind, true
+	case t == bytesType:
+		return bytesKind, true
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return stringKind, true
+	case reflect.Bool:
+		return yesNoKind, true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return intKind, true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return uintKind, true
+	case reflect.Float32, reflect.Float64:
+		return numberKind, true
+	}
+	return
+}
+
+// attributeFields collects the tagged fields of t, descending into untagged
+// embedded structs.
+func attributeFields(t reflect.Type) (fields []attributeField, err error) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag, ok := field.Tag.Lookup("hls")
+		if tag == "-" {
+			continue
+		}
+		if !ok {
+			if field.Anonymous && field.Type.Kind() == reflect.Struct {
+				var embedded []attributeField
+				if embedded, err = attributeFields(field.Type); err != nil {
+					return
+				}
+				for _, f := range embedded {
+					f.index = append([]int{i}, f.index...)
+					fields = append(fields, f)
+				}
+			}
+			continue
+		}
+		var f attributeField
+		if f, err = parseAttributeFieldTag(field, tag); err != nil {
+			return
+		}
+		f.index = []int{i}
+		fields = append(fields, f)
+	}
+	return
+}
+
+// UnmarshalAttributes decodes attrs into the struct pointed to by v according
+// to the hls struct tags of its fields. Duplicated attributes resolve to the
+// last one, as with AttributeList.GetLast.
+func UnmarshalAttributes(attrs *AttributeList, v interface{}) (err error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("UnmarshalAttributes needs a non-nil struct pointer, got %T: %w", v, ErrInvalidTarget)
+	}
+	rv = rv.Elem()
+	fields, err := attributeFields(rv.Type())
+	if err != nil {
+		return
+	}
+	for _, f := range fields {
+		attr := attrs.GetLast(f.name)
+		if attr == nil {
+			if f.required {
+				return fmt.Errorf("missing %s attribute: %w", f.name, ErrFormat)
+			}
+			continue
+		}
+		if err = unmarshalAttributeValue(&attr.Value, rv.FieldByIndex(f.index), f.kinds); err != nil {
+			return fmt.Errorf("failed getting %s attribute: %w", f.name, err)
+		}
+	}
+	return
+}
+
+func unmarshalAttributeValue(value *Value, field reflect.Value, kinds []valueKind) (err error) {
+	if field.CanAddr() && field.Addr().Type().Implements(attributeUnmarshalerType) {
+		return field.Addr().Interface().(AttributeUnmarshaler).UnmarshalAttribute(value)
+	}
+	if field.Kind() == reflect.Ptr {
+		ptr := reflect.New(field.Type().Elem())
+		if err = unmarshalAttributeValue(value, ptr.Elem(), kinds); err != nil {
+			return
+		}
+		field.Set(ptr)
+		return
+	}
+	if len(kinds) == 0 {
+		return fmt.Errorf("unsupported field type %s: %w", field.Type(), ErrInvalidTarget)
+	}
+
+	var decoded interface{}
+	for _, kind := range kinds {
+		if decoded, err = decodeValue(value, kind); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return
+	}
+
+	switch d := decoded.(type) {
+	case string:
+		switch {
+		case field.Type() == timeType:
+			var t time.Time
+			if t, err = parseDateTime(d); err != nil {
+				return
+			}
+			field.Set(reflect.ValueOf(t))
+		case field.Type() == stringsType:
+			field.Set(reflect.ValueOf(strings.Split(d, ",")))
+		case field.Kind() == reflect.String:
+			field.SetString(d)
+		default:
+			return fmt.Errorf("cannot store string in %s: %w", field.Type(), ErrInvalidTarget)
+		}
+	case bool:
+		if field.Kind() != reflect.Bool {
+			return fmt.Errorf("cannot store YES/NO in %s: %w", field.Type(), ErrInvalidTarget)
+		}
+		field.SetBool(d)
+	case int64:
+		switch field.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if field.OverflowInt(d) {
+				return fmt.Errorf("value %d overflows %s: %w", d, field.Type(), ErrFormat)
+			}
+			field.SetInt(d)
+		case reflect.Float32, reflect.Float64:
+			field.SetFloat(float64(d))
+		default:
+			return fmt.Errorf("cannot store integer in %s: %w", field.Type(), ErrInvalidTarget)
+		}
+	case uint64:
+		switch field.Kind() {
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if field.OverflowUint(d) {
+				return fmt.Errorf("value %d overflows %s: %w", d, field.Type(), ErrFormat)
+			}
+			field.SetUint(d)
+		default:
+			return fmt.Errorf("cannot store unsigned integer in %s: %w", field.Type(), ErrInvalidTarget)
+		}
+	case float64:
+		if field.Kind() != reflect.Float32 && field.Kind() != reflect.Float64 {
+			return fmt.Errorf("cannot store float in %s: %w", field.Type(), ErrInvalidTarget)
+		}
+		field.SetFloat(d)
+	case []byte:
+		if field.Type() != bytesType {
+			return fmt.Errorf("cannot store bytes in %s: %w", field.Type(), ErrInvalidTarget)
+		}
+		field.SetBytes(append([]byte{}, d...))
+	case Resolution:
+		if field.Type() != resolutionType {
+			return fmt.Errorf("cannot store resolution in %s: %w", field.Type(), ErrInvalidTarget)
+		}
+		field.Set(reflect.ValueOf(d))
+	}
+	return
+}
+
+func decodeValue(value *Value, kind valueKind) (decoded interface{}, err error) {
+	switch kind {
+	case stringKind:
+		return value.String()
+	case enumKind:
+		return value.Enum()
+	case intKind:
+		return value.Int()
+	case uintKind:
+		return value.Uint()
+	case floatKind:
+		return value.Float()
+	case numberKind:
+		return value.Number()
+	case bytesKind:
+		return value.Bytes()
+	case resolutionKind:
+		return value.Resolution()
+	case yesNoKind:
+		return value.YesNo()
+	}
+	return nil, fmt.Errorf("unknown value kind %s: %w", kind, ErrInvalidTarget)
+}
+
+// MarshalAttributes encodes the struct v, or a pointer to it, as an attribute
+// list according to the hls struct tags of its fields. Nil pointers, slices,
+// maps and interfaces are omitted, as are zero values of fields tagged
+// omitempty.
+func MarshalAttributes(v interface{}) (attrs *AttributeList, err error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("MarshalAttributes needs a struct, got %T: %w", v, ErrInvalidTarget)
+	}
+	fields, err := attributeFields(rv.Type())
+	if err != nil {
+		return
+	}
+	attrs = &AttributeList{}
+	for _, f := range fields {
+		field := rv.FieldByIndex(f.index)
+		if isNil(field) {
+			if f.required {
+				return nil, fmt.Errorf("missing %s attribute: %w", f.name, ErrFormat)
+			}
+			continue
+		}
+		if f.omitEmpty && field.IsZero() {
+			continue
+		}
+		var value *Value
+		if value, err = marshalAttributeValue(field, f.kinds); err != nil {
+			return nil, fmt.Errorf("failed setting %s attribute: %w", f.name, err)
+		}
+		attrs.Append(&Attribute{Name: f.name, Value: *value})
+	}
+	return
+}
+
+func isNil(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Map, reflect.Interface:
+		return v.IsNil()
+	}
+	return false
+}
+
+func hasValueKind(kinds []valueKind, kind valueKind) bool {
+	for _, k := range kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+func marshalAttributeValue(field reflect.Value, kinds []valueKind) (value *Value, err error) {
+	if field.Type().Implements(attributeMarshalerType) {
+		return field.Interface().(AttributeMarshaler).MarshalAttribute()
+	}
+	if field.CanAddr() && field.Addr().Type().Implements(attributeMarshalerType) {
+		return field.Addr().Interface().(AttributeMarshaler).MarshalAttribute()
+	}
+	if field.Kind() == reflect.Ptr {
+		return marshalAttributeValue(field.Elem(), kinds)
+	}
+	if len(kinds) == 0 {
+		return nil, fmt.Errorf("unsupported field type %s: %w", field.Type(), ErrInvalidTarget)
+	}
+
+	switch kinds[0] {
+	case stringKind, enumKind:
+		var str string
+		switch {
+		case field.Type() == timeType:
+			str = field.Interface().(time.Time).Format(time.RFC3339Nano)
+		case field.Type() == stringsType:
+			str = strings.Join(field.Interface().([]string), ",")
+		case field.Kind() == reflect.String:
+			str = field.String()
+		default:
+			return nil, fmt.Errorf("cannot format %s as %s: %w", field.Type(), kinds[0], ErrInvalidTarget)
+		}
+		if kinds[0] == enumKind || (str == "NONE" && hasValueKind(kinds, enumKind)) {
+			// a quoted "NONE" would be a group named NONE rather than the
+			// NONE enumerated-string, as for CLOSED-CAPTIONS
+			return Enum(str), nil
+		}
+		return String(str), nil
+	case yesNoKind:
+		if field.Kind() == reflect.Bool {
+			return YesNo(field.Bool()), nil
+		}
+	case intKind, uintKind:
+		switch field.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if kinds[0] == uintKind && field.Int() < 0 {
+				return nil, fmt.Errorf("negative value %d for unsigned integer: %w", field.Int(), ErrFormat)
+			}
+			return Int(field.Int()), nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if field.Uint() > 1<<63-1 {
+				return nil, fmt.Errorf("value %d overflows decimal-integer: %w", field.Uint(), ErrFormat)
+			}
+			return Int(int64(field.Uint())), nil
+		}
+	case floatKind, numberKind:
+		switch field.Kind() {
+		case reflect.Float32, reflect.Float64:
+			return Float(field.Float()), nil
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return Float(float64(field.Int())), nil
+		}
+	case bytesKind:
+		if field.Type() == bytesType {
+			return Bytes(field.Bytes()), nil
+		}
+	case resolutionKind:
+		if field.Type() == resolutionType {
+			resolution := field.Interface().(Resolution)
+			return ResolutionValue(&resolution), nil
+		}
+	}
+	return nil, fmt.Errorf("cannot format %s as %s: %w", field.Type(), kinds[0], ErrInvalidTarget)
+}
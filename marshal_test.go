@@ -0,0 +1,86 @@
+package hls
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testAdBreak string
+
+func (b *testAdBreak) UnmarshalAttribute(value *Value) error {
+	str, err := value.Enum()
+	*b = testAdBreak(strings.ToLower(str))
+	return err
+}
+
+func (b testAdBreak) MarshalAttribute() (*Value, error) {
+	return Enum(strings.ToUpper(string(b))), nil
+}
+
+type testVendorBase struct {
+	ID string `hls:"ID,required"`
+}
+
+type testVendorTag struct {
+	testVendorBase
+	Bandwidth      uint64      `hls:"BANDWIDTH,required"`
+	FrameRate      *float64    `hls:"FRAME-RATE"`
+	ClosedCaptions *string     `hls:"CLOSED-CAPTIONS,string|enum"`
+	Resolution     *Resolution `hls:"RESOLUTION"`
+	Key            []byte      `hls:"KEY"`
+	Default        bool        `hls:"DEFAULT"`
+	Offset         int         `hls:"OFFSET,omitempty"`
+	Tags           []string    `hls:"TAGS"`
+	Break          testAdBreak `hls:"BREAK"`
+	Ignored        string      `hls:"-"`
+}
+
+func TestUnmarshalAttributes(t *testing.T) {
+	attrs, err := ParseAttributeList(`ID="x1",BANDWIDTH=1280000,FRAME-RATE=30,CLOSED-CAPTIONS=NONE,RESOLUTION=1280x720,KEY=0x0A0B,DEFAULT=YES,TAGS="a,b",BREAK=MID`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v testVendorTag
+	if err = UnmarshalAttributes(attrs, &v); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "x1", v.ID)
+	assert.EqualValues(t, 1280000, v.Bandwidth)
+	assert.Equal(t, 30.0, *v.FrameRate)
+	assert.Equal(t, "NONE", *v.ClosedCaptions)
+	assert.Equal(t, Resolution{1280, 720}, *v.Resolution)
+	assert.Equal(t, []byte{0x0A, 0x0B}, v.Key)
+	assert.True(t, v.Default)
+	assert.Equal(t, []string{"a", "b"}, v.Tags)
+	assert.Equal(t, testAdBreak("mid"), v.Break)
+
+	formatted, err := MarshalAttributes(&v)
+	if assert.NoError(t, err) {
+		assert.Equal(t, `ID="x1",BANDWIDTH=1280000,FRAME-RATE=30,CLOSED-CAPTIONS=NONE,RESOLUTION=1280x720,KEY=0x0A0B,DEFAULT=YES,TAGS="a,b",BREAK=MID`, formatted.Format())
+	}
+
+	// only NONE is written as an enum
+	group := "cc1"
+	v.ClosedCaptions = &group
+	formatted, err = MarshalAttributes(&v)
+	if assert.NoError(t, err) {
+		assert.Contains(t, formatted.Format(), `,CLOSED-CAPTIONS="cc1",`)
+	}
+
+	// nil pointers and slices are left out
+	formatted, err = MarshalAttributes(&testVendorTag{testVendorBase: testVendorBase{ID: "x2"}, Bandwidth: 1, Break: "pre"})
+	if assert.NoError(t, err) {
+		assert.Equal(t, `ID="x2",BANDWIDTH=1,DEFAULT=NO,BREAK=PRE`, formatted.Format())
+	}
+
+	attrs, _ = ParseAttributeList(`ID="x1"`)
+	err = UnmarshalAttributes(attrs, &v)
+	assert.True(t, errors.Is(err, ErrFormat), "expected ErrFormat, got %v", err)
+
+	attrs, _ = ParseAttributeList(`ID="x1",BANDWIDTH="high"`)
+	err = UnmarshalAttributes(attrs, &v)
+	assert.True(t, errors.Is(err, ErrWrongType), "expected ErrWrongType, got %v", err)
+}
@@ -11,7 +11,8 @@ type MediaInitMap struct {
 	ByteRange *ByteRange
 
 	// the following are computed values
-	Key *Key
+	Key  *Key
+	Keys []*Key
 }
 
 func (m *MediaInitMap) ParseTag(tag *Tag) (err error) {
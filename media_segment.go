@@ -10,20 +10,23 @@ import (
 
 type MediaSegment struct {
 	Tag             *Tag
-	URI             *url.URL      // [REQUIRED] Media Segment URI
-	URILine         *Line         // [REQUIRED] The Line for the URI
-	Duration        time.Duration // [REQUIRED] specifies the duration of the Media Segment
-	Title           string        // [OPTIONAL][DEFAULT=""] human-readable informative title of the Media Segment
-	ByteRange       *ByteRange    // [OPTIONAL] indicates that a Media Segment is a sub-range of the resource identified by its URI
-	IsDiscontinuity bool          // [OPTIONAL] indicates a discontinuity between the Media Segment that follows it and the one that preceded it
-	IsGap           bool          // [OPTIONAL] indicates that the segment URL to which it applies does not contain media data and SHOULD NOT be loaded by clients
+	URI             *url.URL          // [REQUIRED] Media Segment URI
+	URILine         *Line             // [REQUIRED] The Line for the URI
+	Duration        time.Duration     // [REQUIRED] specifies the duration of the Media Segment
+	Title           string            // [OPTIONAL][DEFAULT=""] human-readable informative title of the Media Segment
+	ByteRange       *ByteRange        // [OPTIONAL] indicates that a Media Segment is a sub-range of the resource identified by its URI
+	IsDiscontinuity bool              // [OPTIONAL] indicates a discontinuity between the Media Segment that follows it and the one that preceded it
+	IsGap           bool              // [OPTIONAL] indicates that the segment URL to which it applies does not contain media data and SHOULD NOT be loaded by clients
+	ProgramDateTime *time.Time        // [OPTIONAL] the date/time of the first sample of the Media Segment, from an EXT-X-PROGRAM-DATE-TIME tag
+	Parts           []*PartialSegment // [OPTIONAL] the Partial Segments of the Media Segment, from EXT-X-PART tags
 
 	// the following are computed/inherited values
 	MediaSequence         uint64        // [OPTIONAL][DEFAULT=start at 0 and increment]
 	DiscontinuitySequence uint64        // [OPTIONAL][DEFAULT=start at 0 and increment]
 	Key                   *Key          // [OPTIONAL]
+	Keys                  []*Key        // [OPTIONAL] every key in effect, one per KEYFORMAT, Key being the last one
 	MediaInitMap          *MediaInitMap // [OPTIONAL]
-	Bitrate               *uint64       // [OPTIONAL]
+	Bitrate               *uint64       // [OPTIONAL] the approximate segment bit rate from the last EXT-X-BITRATE tag, in kilobits per second
 }
 
 func (s *MediaSegment) ParseTag(tag *Tag) (err error) {
@@ -2,20 +2,33 @@ package hls
 
 import (
 	"bufio"
+	"bytes"
 	"fmt"
 	"io"
 	"net/url"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
+	"unicode/utf8"
 )
 
 type ParserHandler struct {
 	HandleMediaSegment   func(segment *MediaSegment, playlist *MediaPlaylist) (next bool)
 	HandleVariantStream  func(variantStream *VariantStream, playlist *MasterPlaylist) (next bool)
 	HandleIframeStream   func(iframeStream *IframeStream, playlist *MasterPlaylist) (next bool)
+	HandleRendition      func(rendition *Rendition, playlist *MasterPlaylist) (next bool)
+	HandleKey            func(key *Key, playlist *MediaPlaylist) (next bool)
+	HandleMediaInitMap   func(mediaInitMap *MediaInitMap, playlist *MediaPlaylist) (next bool)
+	HandleDiscontinuity  func(discontinuitySequence uint64, playlist *MediaPlaylist) (next bool) // called with the discontinuity sequence number of the Media Segment that follows
+	HandleDateRange      func(dateRange *DateRange, playlist *MediaPlaylist) (next bool)
+	HandleTag            func(tag *Tag, line *Line) (next bool) // called for every tag line before it is interpreted
 	HandleMediaPlaylist  func(playlist *MediaPlaylist)
 	HandleMasterPlaylist func(playlist *MasterPlaylist)
+	HandleWarning        func(warning *Warning)
+	HandleError          func(err error, line *Line) // called with the error Parse is about to return and the offending line, nil if the error is not about a line that could be read and parsed
+	Limits               *ParseLimits                // nil means DefaultParseLimits
+	Lenient              bool                        // accept a missing #EXTM3U header, a byte order mark and control characters other than NUL
 }
 
 type LineType int
@@ -24,6 +37,7 @@ const (
 	TagLineType LineType = iota
 	URLLineType
 	SpaceLineType
+	CommentLineType
 )
 
 type Line struct {
@@ -32,6 +46,7 @@ type Line struct {
 	Tag     *Tag
 	URL     string
 	Space   string
+	Comment string // including the leading #
 }
 
 func (line Line) Format() string {
@@ -42,6 +57,8 @@ func (line Line) Format() string {
 		return line.URL
 	case SpaceLineType:
 		return line.Space
+	case CommentLineType:
+		return line.Comment
 	}
 	panic(fmt.Errorf("unknown line type %d", line.Type))
 }
@@ -58,27 +75,144 @@ var ParserBufferPool = sync.Pool{
 	},
 }
 
+// attributeListTags are the tags whose value is an attribute list. Parse
+// lexes them up front so that the attribute limits apply.
+var attributeListTags = map[string]bool{
+	"EXT-X-STREAM-INF":         true,
+	"EXT-X-I-FRAME-STREAM-INF": true,
+	"EXT-X-MEDIA":              true,
+	"EXT-X-MAP":                true,
+	"EXT-X-KEY":                true,
+	"EXT-X-DATERANGE":          true,
+	"EXT-X-PART-INF":           true,
+	"EXT-X-PART":               true,
+	"EXT-X-PRELOAD-HINT":       true,
+	"EXT-X-RENDITION-REPORT":   true,
+	"EXT-X-SKIP":               true,
+	"EXT-X-SESSION-DATA":       true,
+	"EXT-X-SESSION-KEY":        true,
+	"EXT-X-SERVER-CONTROL":     true,
+	"EXT-X-START":              true,
+	"EXT-X-CONTENT-STEERING":   true,
+}
+
+var byteOrderMark = []byte{0xEF, 0xBB, 0xBF}
+
+// readLine reads a line of at most maxLength bytes (0 means unlimited)
+// without the line terminator, which is either LF or CRLF. The returned
+// slice is only valid until the next read.
+func readLine(buf *bufio.Reader, maxLength int, scratch []byte) (line []byte, newScratch []byte, err error) {
+	fragment, err := buf.ReadSlice('\n')
+	line = fragment
+	if err == bufio.ErrBufferFull {
+		line = append(scratch[:0], fragment...)
+		for err == bufio.ErrBufferFull {
+			if maxLength > 0 && len(line) > maxLength+2 {
+				return nil, line, fmt.Errorf("longer than %d bytes: %w", maxLength, ErrLineTooLong)
+			}
+			fragment, err = buf.ReadSlice('\n')
+			line = append(line, fragment...)
+		}
+		scratch = line
+	}
+	if err == io.EOF && len(line) > 0 {
+		// the last line is not required to be terminated
+		err = nil
+	}
+	if err != nil {
+		return nil, scratch, err
+	}
+	if n := len(line); n > 0 && line[n-1] == '\n' {
+		line = line[:n-1]
+		if n := len(line); n > 0 && line[n-1] == '\r' {
+			line = line[:n-1]
+		}
+	}
+	if maxLength > 0 && len(line) > maxLength {
+		return nil, scratch, fmt.Errorf("longer than %d bytes: %w", maxLength, ErrLineTooLong)
+	}
+	return line, scratch, nil
+}
+
+// checkLineCharacters reports control characters (U+0000 to U+001F, and
+// U+007F to U+009F) and invalid UTF-8 sequences, which the specification
+// forbids. Tabs are only allowed in the quoted-string attribute values of
+// tags, where they delimit the RECENTLY-REMOVED-DATERANGES of EXT-X-SKIP.
+func checkLineCharacters(line []byte) error {
+	isTag := bytes.HasPrefix(line, []byte("#EXT"))
+	quoted := false
+	for i := 0; i < len(line); {
+		c := line[i]
+		if c < utf8.RuneSelf {
+			if c == '"' && isTag {
+				// a quoted-string cannot contain a double quote
+				quoted = !quoted
+			}
+			if (c < 0x20 && !(c == '\t' && quoted)) || c == 0x7F {
+				return fmt.Errorf("control character 0x%02X at column %d: %w", c, i+1, ErrFormat)
+			}
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRune(line[i:])
+		if r == utf8.RuneError && size == 1 {
+			return fmt.Errorf("invalid UTF-8 sequence at column %d: %w", i+1, ErrFormat)
+		}
+		if r >= 0x80 && r <= 0x9F {
+			return fmt.Errorf("control character U+%04X at column %d: %w", r, i+1, ErrFormat)
+		}
+		i += size
+	}
+	return nil
+}
+
 func Parse(r io.Reader, baseURL *url.URL, handler *ParserHandler) (err error) {
 	var (
 		lineNum               int
 		lineBytes             []byte
+		lineScratch           []byte
+		line                  *Line
+		errLine               *Line // the line causing the error returned, if any
 		lineStr               string
 		lineTrimed            string
-		isPrefix              bool
 		buf                   *bufio.Reader
+		limits                *ParseLimits
+		renditionCount        int
+		renditionGroupCount   int
+		pendingScopeLines     []*Line
 		key                   *Key
+		keys                  []*Key
 		isMaster              bool
 		isMedia               bool
 		stop                  bool
 		byteRangeOffset       uint64
+		partByteRange         *ByteRange // of the last partial segment
+		partURI               string
 		mediaInitMap          *MediaInitMap
 		mediaSequence         uint64
 		discontinuitySequence uint64
 		mediaSegmentBitrate   *uint64
 	)
 
+	defer func() {
+		if err != nil && handler.HandleError != nil {
+			handler.HandleError(err, errLine)
+		}
+	}()
+
+	if limits = handler.Limits; limits == nil {
+		limits = &DefaultParseLimits
+	}
+	if limits.MaxBytes > 0 {
+		r = &limitedReader{r: r, limit: limits.MaxBytes}
+	}
+
 	buf = ParserBufferPool.Get().(*bufio.Reader)
 	buf.Reset(r)
+	defer func() {
+		buf.Reset(nil)
+		ParserBufferPool.Put(buf)
+	}()
 
 	playlist := &Playlist{Lines: make([]*Line, 0), Version: 1}
 	mediaPlaylist := &MediaPlaylist{Playlist: playlist}
@@ -87,6 +221,30 @@ func Parse(r io.Reader, baseURL *url.URL, handler *ParserHandler) (err error) {
 	variantStream := &VariantStream{}
 	ifrmaeStream := &IframeStream{}
 
+	warn := func(code WarningCode, lineNum int, format string, a ...interface{}) {
+		warning := &Warning{Code: code, LineNum: lineNum, Message: fmt.Sprintf(format, a...)}
+		playlist.Warnings = append(playlist.Warnings, warning)
+		if handler.HandleWarning != nil {
+			handler.HandleWarning(warning)
+		}
+	}
+
+	// checkTagScope warns about tags that do not apply to the playlist type,
+	// deferring the decision until the type is known.
+	checkTagScope := func(line *Line) {
+		scope := knownTags[line.Tag.Name]
+		if scope == anyPlaylistTag {
+			return
+		}
+		if isMaster && scope == mediaPlaylistTag {
+			warn(WarnIgnoredTag, line.LineNum, "%s tag does not apply to a master playlist", line.Tag.Name)
+		} else if isMedia && scope == masterPlaylistTag {
+			warn(WarnIgnoredTag, line.LineNum, "%s tag does not apply to a media playlist", line.Tag.Name)
+		} else if !isMaster && !isMedia {
+			pendingScopeLines = append(pendingScopeLines, line)
+		}
+	}
+
 	ensurePlaylist := func(cond bool, toSet *bool) error {
 		if !cond {
 			return fmt.Errorf("line %d: mixing media and master playlist tags: %w", lineNum, ErrFormat)
@@ -97,10 +255,14 @@ func Parse(r io.Reader, baseURL *url.URL, handler *ParserHandler) (err error) {
 		return nil
 	}
 
-	finishMediaSegment := func() {
+	finishMediaSegment := func() (err error) {
+		if err = limits.checkMediaSegments(len(mediaPlaylist.MediaSegments) + 1); err != nil {
+			return
+		}
 		mediaSegment.MediaSequence = mediaSequence
 		mediaSegment.DiscontinuitySequence = discontinuitySequence
 		mediaSegment.Key = key
+		mediaSegment.Keys = keys
 		mediaSegment.MediaInitMap = mediaInitMap
 		mediaSegment.Bitrate = mediaSegmentBitrate
 		mediaSequence += 1
@@ -109,24 +271,48 @@ func Parse(r io.Reader, baseURL *url.URL, handler *ParserHandler) (err error) {
 			stop = !handler.HandleMediaSegment(mediaSegment, mediaPlaylist)
 		}
 		mediaSegment = &MediaSegment{}
+		return
 	}
 
 	for !stop {
 		lineNum++
-		if lineBytes, isPrefix, err = buf.ReadLine(); err == io.EOF {
+		line, errLine = &Line{LineNum: lineNum}, nil
+		if lineBytes, lineScratch, err = readLine(buf, limits.MaxLineLength, lineScratch); err == io.EOF {
 			err = nil
 			break
-		} else if isPrefix {
-			err = fmt.Errorf("line %d: playlist line too long", lineNum)
-			return
 		} else if err != nil {
 			err = fmt.Errorf("line %d: ReadLine failed: %w", lineNum, err)
 			return
 		}
 
-		line := &Line{LineNum: lineNum}
+		if lineNum == 1 && bytes.HasPrefix(lineBytes, byteOrderMark) {
+			if !handler.Lenient {
+				err = fmt.Errorf("line %d: playlist starts with a byte order mark: %w", lineNum, ErrFormat)
+				return
+			}
+			warn(WarnByteOrderMark, lineNum, "playlist starts with a byte order mark")
+			lineBytes = lineBytes[len(byteOrderMark):]
+		}
+		if i := bytes.IndexByte(lineBytes, 0); i >= 0 {
+			err = fmt.Errorf("line %d: NUL character at column %d: %w", lineNum, i+1, ErrFormat)
+			return
+		}
+		if e := checkLineCharacters(lineBytes); e != nil {
+			if !handler.Lenient {
+				err = fmt.Errorf("line %d: %w", lineNum, e)
+				return
+			}
+			warn(WarnControlCharacter, lineNum, "%s", e.Error())
+		}
 
 		lineStr = string(lineBytes)
+		if lineNum == 1 && strings.TrimRight(lineStr, " \t") != "#EXTM3U" {
+			if !handler.Lenient {
+				err = fmt.Errorf("line %d: playlist does not start with #EXTM3U: %w", lineNum, ErrFormat)
+				return
+			}
+			warn(WarnMissingHeader, lineNum, "playlist does not start with #EXTM3U")
+		}
 		lineTrimed = strings.TrimLeft(lineStr, " \t")
 
 		if len(lineTrimed) == 0 {
@@ -137,8 +323,11 @@ func Parse(r io.Reader, baseURL *url.URL, handler *ParserHandler) (err error) {
 		}
 
 		if lineTrimed[0] != '#' {
+			line.Type = URLLineType
+			line.URL = lineTrimed
+			errLine = line
 			if lineTrimed[0] == '<' {
-				err = fmt.Errorf("line %d: invalid starting character at URL line:\n%s\n%w", lineNum, lineTrimed, err)
+				err = fmt.Errorf("line %d: invalid starting character at URL line:\n%s\n%w", lineNum, lineTrimed, ErrFormat)
 				return
 			}
 			var ref *url.URL
@@ -146,14 +335,16 @@ func Parse(r io.Reader, baseURL *url.URL, handler *ParserHandler) (err error) {
 				err = fmt.Errorf("line %d: failed parsing line:\n%s\nas URL: %w", lineNum, lineTrimed, err)
 				return
 			}
-			line.Type = URLLineType
-			line.URL = lineTrimed
 			playlist.Lines = append(playlist.Lines, line)
 			resolvedURL := baseURL.ResolveReference(ref)
 			if isMaster {
 				if err = ensurePlaylist(!isMedia, nil); err != nil {
 					return
 				}
+				if err = limits.checkStreams(len(masterPlaylist.VariantStreams) + len(masterPlaylist.IframeStreams) + 1); err != nil {
+					err = fmt.Errorf("line %d: %w", lineNum, err)
+					return
+				}
 				variantStream.URI = resolvedURL
 				variantStream.URILine = line
 				masterPlaylist.VariantStreams = append(masterPlaylist.VariantStreams, variantStream)
@@ -167,11 +358,21 @@ func Parse(r io.Reader, baseURL *url.URL, handler *ParserHandler) (err error) {
 				}
 				mediaSegment.URI = resolvedURL
 				mediaSegment.URILine = line
-				finishMediaSegment()
+				if err = finishMediaSegment(); err != nil {
+					err = fmt.Errorf("line %d: %w", lineNum, err)
+					return
+				}
 			}
 			continue
 		}
 
+		if !strings.HasPrefix(lineTrimed, "#EXT") {
+			line.Type = CommentLineType
+			line.Comment = lineStr
+			playlist.Lines = append(playlist.Lines, line)
+			continue
+		}
+
 		colonParts := strings.SplitN(lineTrimed, ":", 2)
 
 		tag := &Tag{}
@@ -182,8 +383,30 @@ func Parse(r io.Reader, baseURL *url.URL, handler *ParserHandler) (err error) {
 		}
 		line.Type = TagLineType
 		line.Tag = tag
+		errLine = line
 		playlist.Lines = append(playlist.Lines, line)
 
+		if _, ok := knownTags[tag.Name]; !ok {
+			warn(WarnUnknownTag, lineNum, "unknown tag %s", tag.Name)
+		} else if deprecatedTags[tag.Name] {
+			warn(WarnDeprecatedTag, lineNum, "deprecated tag %s", tag.Name)
+		}
+
+		if attributeListTags[tag.Name] {
+			if tag.AttributeList, err = ParseAttributeListWithLimits(tag.Value, limits); err != nil {
+				err = fmt.Errorf("line %d: error in parsing tag %s attribute list: %w", lineNum, tag.Name, err)
+				return
+			}
+			for _, name := range tag.AttributeList.Duplicates() {
+				warn(WarnDuplicateAttribute, lineNum, "%s tag has duplicated attribute %s, using the last one", tag.Name, name)
+			}
+		}
+
+		if handler.HandleTag != nil && !handler.HandleTag(tag, line) {
+			stop = true
+			break
+		}
+
 		switch tag.Name {
 		case "EXT-X-VERSION":
 			var e error
@@ -200,6 +423,21 @@ func Parse(r io.Reader, baseURL *url.URL, handler *ParserHandler) (err error) {
 				err = fmt.Errorf("line %d: %w", lineNum, err)
 				return
 			}
+		case "EXT-X-SESSION-DATA":
+			// the tag is ignored in media playlists, as checkTagScope warns,
+			// but does not make the playlist a master one by itself
+			if isMedia {
+				break
+			}
+			sessionData := &SessionData{}
+			if err = sessionData.ParseTag(tag); err != nil {
+				err = fmt.Errorf("line %d: %w", lineNum, err)
+				return
+			}
+			if sessionData.URI != nil {
+				sessionData.URI = baseURL.ResolveReference(sessionData.URI)
+			}
+			masterPlaylist.SessionData = append(masterPlaylist.SessionData, sessionData)
 		case "EXT-X-I-FRAME-STREAM-INF":
 			if err = ensurePlaylist(!isMedia, &isMaster); err != nil {
 				return
@@ -209,6 +447,11 @@ func Parse(r io.Reader, baseURL *url.URL, handler *ParserHandler) (err error) {
 				err = fmt.Errorf("line %d: %w", lineNum, err)
 				return
 			}
+			ifrmaeStream.URI = baseURL.ResolveReference(ifrmaeStream.URI)
+			if err = limits.checkStreams(len(masterPlaylist.VariantStreams) + len(masterPlaylist.IframeStreams) + 1); err != nil {
+				err = fmt.Errorf("line %d: %w", lineNum, err)
+				return
+			}
 			masterPlaylist.IframeStreams = append(masterPlaylist.IframeStreams, ifrmaeStream)
 			if handler.HandleIframeStream != nil {
 				stop = !handler.HandleIframeStream(ifrmaeStream, masterPlaylist)
@@ -218,19 +461,50 @@ func Parse(r io.Reader, baseURL *url.URL, handler *ParserHandler) (err error) {
 			if err = ensurePlaylist(!isMedia, &isMaster); err != nil {
 				return
 			}
-			rendition := &Rendition{}
+			rendition := &Rendition{TagLine: line}
 			if err = rendition.ParseTag(tag); err != nil {
 				err = fmt.Errorf("line %d: %w", lineNum, err)
 				return
 			}
+			if rendition.URI != nil {
+				rendition.URI = baseURL.ResolveReference(rendition.URI)
+			}
+			renditionCount++
+			if err = limits.checkRenditions(renditionCount); err != nil {
+				err = fmt.Errorf("line %d: %w", lineNum, err)
+				return
+			}
 			if masterPlaylist.RenditionGroups == nil {
 				masterPlaylist.RenditionGroups = make(map[RenditionType]map[string][]*Rendition)
 			}
 			if masterPlaylist.RenditionGroups[rendition.Type] == nil {
 				masterPlaylist.RenditionGroups[rendition.Type] = make(map[string][]*Rendition)
 			}
+			if _, ok := masterPlaylist.RenditionGroups[rendition.Type][rendition.GroupID]; !ok {
+				renditionGroupCount++
+				if err = limits.checkRenditionGroups(renditionGroupCount); err != nil {
+					err = fmt.Errorf("line %d: %w", lineNum, err)
+					return
+				}
+			}
 			masterPlaylist.RenditionGroups[rendition.Type][rendition.GroupID] = append(masterPlaylist.RenditionGroups[rendition.Type][rendition.GroupID], rendition)
+			if handler.HandleRendition != nil {
+				stop = !handler.HandleRendition(rendition, masterPlaylist)
+			}
 
+		case "EXT-X-TARGETDURATION":
+			var (
+				e              error
+				targetDuration uint64
+			)
+			if err = ensurePlaylist(!isMaster, &isMedia); err != nil {
+				return
+			}
+			if targetDuration, e = strconv.ParseUint(tag.Value, 10, 64); e != nil {
+				err = fmt.Errorf("line %d: failed to parse EXT-X-TARGETDURATION value as integer: %s: %w", lineNum, e.Error(), ErrFormat)
+				return
+			}
+			mediaPlaylist.TargetDuration = time.Duration(targetDuration) * time.Second
 		case "EXT-X-MEDIA-SEQUENCE":
 			var e error
 			if err = ensurePlaylist(!isMaster, &isMedia); err != nil {
@@ -251,6 +525,103 @@ func Parse(r io.Reader, baseURL *url.URL, handler *ParserHandler) (err error) {
 				return
 			}
 			mediaPlaylist.DiscontinuitySequence = discontinuitySequence
+		case "EXT-X-ENDLIST":
+			if err = ensurePlaylist(!isMaster, &isMedia); err != nil {
+				return
+			}
+			mediaPlaylist.EndList = true
+		case "EXT-X-PLAYLIST-TYPE":
+			if err = ensurePlaylist(!isMaster, &isMedia); err != nil {
+				return
+			}
+			playlistType := PlaylistType(tag.Value)
+			if playlistType != PlaylistTypeEvent && playlistType != PlaylistTypeVOD {
+				err = fmt.Errorf("line %d: invalid EXT-X-PLAYLIST-TYPE value: %s: %w", lineNum, tag.Value, ErrFormat)
+				return
+			}
+			mediaPlaylist.PlaylistType = &playlistType
+		case "EXT-X-SERVER-CONTROL":
+			if err = ensurePlaylist(!isMaster, &isMedia); err != nil {
+				return
+			}
+			serverControl := &ServerControl{}
+			if err = serverControl.ParseTag(tag); err != nil {
+				err = fmt.Errorf("line %d: %w", lineNum, err)
+				return
+			}
+			mediaPlaylist.ServerControl = serverControl
+		case "EXT-X-PART-INF":
+			if err = ensurePlaylist(!isMaster, &isMedia); err != nil {
+				return
+			}
+			attr := tag.AttributeList.GetLast("PART-TARGET")
+			if attr == nil {
+				err = fmt.Errorf("line %d: EXT-X-PART-INF tag is missing PART-TARGET attribute: %w", lineNum, ErrFormat)
+				return
+			}
+			var seconds float64
+			if seconds, err = attr.Number(); err != nil {
+				err = fmt.Errorf("line %d: failed getting PART-TARGET attribute: %w", lineNum, err)
+				return
+			}
+			if mediaPlaylist.PartTarget, err = parseSeconds(seconds); err != nil {
+				err = fmt.Errorf("line %d: failed parsing PART-TARGET attribute: %w", lineNum, err)
+				return
+			}
+		case "EXT-X-PART":
+			if err = ensurePlaylist(!isMaster, &isMedia); err != nil {
+				return
+			}
+			part := &PartialSegment{}
+			// a byte range without offset follows the previous one of the
+			// same resource
+			var defaultOffset uint64
+			if attr := tag.AttributeList.GetLast("URI"); attr != nil && partByteRange != nil {
+				if uri, e := attr.String(); e == nil && uri == partURI {
+					defaultOffset = partByteRange.End()
+				}
+			}
+			if err = part.ParseTag(tag, defaultOffset); err != nil {
+				err = fmt.Errorf("line %d: %w", lineNum, err)
+				return
+			}
+			partByteRange, partURI = part.ByteRange, part.URI.String()
+			part.URI = baseURL.ResolveReference(part.URI)
+			mediaSegment.Parts = append(mediaSegment.Parts, part)
+		case "EXT-X-PRELOAD-HINT":
+			if err = ensurePlaylist(!isMaster, &isMedia); err != nil {
+				return
+			}
+			hint := &PreloadHint{}
+			if err = hint.ParseTag(tag); err != nil {
+				err = fmt.Errorf("line %d: %w", lineNum, err)
+				return
+			}
+			hint.URI = baseURL.ResolveReference(hint.URI)
+			mediaPlaylist.PreloadHints = append(mediaPlaylist.PreloadHints, hint)
+		case "EXT-X-RENDITION-REPORT":
+			if err = ensurePlaylist(!isMaster, &isMedia); err != nil {
+				return
+			}
+			report := &RenditionReport{}
+			if err = report.ParseTag(tag); err != nil {
+				err = fmt.Errorf("line %d: %w", lineNum, err)
+				return
+			}
+			report.URI = baseURL.ResolveReference(report.URI)
+			mediaPlaylist.RenditionReports = append(mediaPlaylist.RenditionReports, report)
+		case "EXT-X-SKIP":
+			if err = ensurePlaylist(!isMaster, &isMedia); err != nil {
+				return
+			}
+			skip := &Skip{}
+			if err = skip.ParseTag(tag); err != nil {
+				err = fmt.Errorf("line %d: %w", lineNum, err)
+				return
+			}
+			mediaPlaylist.Skip = skip
+			// the skipped segments come first
+			mediaSequence += skip.SkippedSegments
 		case "EXTINF":
 			if err = ensurePlaylist(!isMaster, &isMedia); err != nil {
 				return
@@ -274,23 +645,108 @@ func Parse(r io.Reader, baseURL *url.URL, handler *ParserHandler) (err error) {
 			}
 			mediaSegment.IsDiscontinuity = true
 			discontinuitySequence += 1
+			if handler.HandleDiscontinuity != nil {
+				stop = !handler.HandleDiscontinuity(discontinuitySequence, mediaPlaylist)
+			}
 		case "EXT-X-GAP":
 			if err = ensurePlaylist(!isMaster, &isMedia); err != nil {
 				return
 			}
 			mediaSegment.IsGap = true
+		case "EXT-X-PROGRAM-DATE-TIME":
+			if err = ensurePlaylist(!isMaster, &isMedia); err != nil {
+				return
+			}
+			var programDateTime time.Time
+			if programDateTime, err = parseDateTime(tag.Value); err != nil {
+				err = fmt.Errorf("line %d: failed to parse EXT-X-PROGRAM-DATE-TIME value: %w", lineNum, err)
+				return
+			}
+			mediaSegment.ProgramDateTime = &programDateTime
+		case "EXT-X-BITRATE":
+			var (
+				e       error
+				bitrate uint64
+			)
+			if err = ensurePlaylist(!isMaster, &isMedia); err != nil {
+				return
+			}
+			if bitrate, e = strconv.ParseUint(tag.Value, 10, 64); e != nil {
+				err = fmt.Errorf("line %d: failed to parse EXT-X-BITRATE value as integer: %s: %w", lineNum, e.Error(), ErrFormat)
+				return
+			}
+			mediaSegmentBitrate = &bitrate
 		case "EXT-X-MAP":
-			mediaInitMap = &MediaInitMap{Key: key}
+			mediaInitMap = &MediaInitMap{Key: key, Keys: keys}
 			if err = mediaInitMap.ParseTag(tag); err != nil {
 				err = fmt.Errorf("line %d: %w", lineNum, err)
 				return
 			}
+			mediaInitMap.URI = baseURL.ResolveReference(mediaInitMap.URI)
+			if handler.HandleMediaInitMap != nil {
+				stop = !handler.HandleMediaInitMap(mediaInitMap, mediaPlaylist)
+			}
 		case "EXT-X-KEY":
 			key = &Key{}
 			if err = key.ParseTag(tag); err != nil {
 				err = fmt.Errorf("line %d: %w", lineNum, err)
 				return
 			}
+			if key.URI != nil {
+				key.URI = baseURL.ResolveReference(key.URI)
+			}
+			keys = activeKeys(keys, key)
+			if handler.HandleKey != nil {
+				stop = !handler.HandleKey(key, mediaPlaylist)
+			}
+		case "EXT-X-DATERANGE":
+			dateRange := &DateRange{}
+			if err = dateRange.ParseTag(tag); err != nil {
+				err = fmt.Errorf("line %d: %w", lineNum, err)
+				return
+			}
+			mediaPlaylist.DateRanges = append(mediaPlaylist.DateRanges, dateRange)
+			if handler.HandleDateRange != nil {
+				stop = !handler.HandleDateRange(dateRange, mediaPlaylist)
+			}
+		}
+		checkTagScope(line)
+	}
+	// the errors from here on are about the playlist as a whole
+	errLine = nil
+
+	for _, line := range pendingScopeLines {
+		checkTagScope(line)
+	}
+	if isMedia {
+		// the segment these parts belong to is not complete yet
+		mediaPlaylist.Parts = mediaSegment.Parts
+	}
+	if isMedia && !stop {
+		if mediaPlaylist.TargetDuration == 0 {
+			warn(WarnMissingTargetDuration, 0, "media playlist has no EXT-X-TARGETDURATION tag")
+		} else {
+			for _, segment := range mediaPlaylist.MediaSegments {
+				if segment.Duration.Round(time.Second) > mediaPlaylist.TargetDuration {
+					warn(WarnSegmentExceedsTargetDuration, segment.URILine.LineNum, "EXTINF duration %s exceeds target duration %s", segment.Duration, mediaPlaylist.TargetDuration)
+				}
+			}
+		}
+	}
+
+	if isMaster && !stop {
+		if e := masterPlaylist.ValidateRenditionGroups(); e != nil {
+			if !handler.Lenient {
+				err = e
+				return
+			}
+			groupErr := e.(*RenditionGroupError)
+			for _, ref := range groupErr.Dangling {
+				warn(WarnDanglingRenditionGroup, ref.lineNum(), "%s group %q is not defined", ref.Type, ref.GroupID)
+			}
+			for _, invalid := range groupErr.Invalid {
+				warn(WarnInvalidRendition, invalid.Rendition.TagLine.LineNum, "%s rendition %q %s", invalid.Rendition.Type, invalid.Rendition.Name, invalid.Reason)
+			}
 		}
 	}
 
@@ -302,7 +758,7 @@ func Parse(r io.Reader, baseURL *url.URL, handler *ParserHandler) (err error) {
 		if handler.HandleMediaPlaylist != nil {
 			handler.HandleMediaPlaylist(mediaPlaylist)
 		}
-	} else {
+	} else if !stop {
 		err = fmt.Errorf("ambiguous playlist: %w", ErrFormat)
 		return
 	}
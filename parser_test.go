@@ -0,0 +1,360 @@
+package hls
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testBaseURL, _ = url.Parse("https://example.com/live/index.m3u8")
+
+func TestParseLimits(t *testing.T) {
+	media := "#EXTM3U\n#EXT-X-TARGETDURATION:4\n#EXTINF:4,\na.ts\n#EXTINF:4,\nb.ts\n#EXTINF:4,\nc.ts\n"
+	master := "#EXTM3U\n" +
+		`#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="a1",NAME="en",URI="en.m3u8"` + "\n" +
+		`#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="a2",NAME="fr",URI="fr.m3u8"` + "\n" +
+		`#EXT-X-STREAM-INF:BANDWIDTH=1000,CODECS="avc1.64001f,mp4a.40.2",AUDIO="a1"` + "\n" +
+		"low.m3u8\n" +
+		`#EXT-X-STREAM-INF:BANDWIDTH=2000,CODECS="avc1.640028,mp4a.40.2",AUDIO="a2"` + "\n" +
+		"high.m3u8\n"
+	sessionData := master + `#EXT-X-SESSION-DATA:DATA-ID="com.example.data",VALUE="` + strings.Repeat("a", 40<<10) + `"` + "\n"
+	start := "#EXTM3U\n#EXT-X-TARGETDURATION:4\n#EXT-X-START:TIME-OFFSET=-8,PRECISE=YES\n#EXTINF:4,\na.ts\n"
+
+	tests := []struct {
+		name     string
+		playlist string
+		limits   ParseLimits
+		err      error
+	}{
+		{"bytes", media, ParseLimits{MaxBytes: 20}, ErrPlaylistTooLarge},
+		{"line length", media, ParseLimits{MaxLineLength: 8}, ErrLineTooLong},
+		{"media segments", media, ParseLimits{MaxMediaSegments: 2}, ErrTooManyMediaSegments},
+		{"streams", master, ParseLimits{MaxStreams: 1}, ErrTooManyStreams},
+		{"unlimited", master, NoParseLimits, nil},
+		{"renditions", master, ParseLimits{MaxRenditions: 1}, ErrTooManyRenditions},
+		{"rendition groups", master, ParseLimits{MaxRenditionGroups: 1}, ErrTooManyRenditionGroups},
+		{"attributes", master, ParseLimits{MaxAttributes: 3}, ErrTooManyAttributes},
+		{"attribute value length", master, ParseLimits{MaxAttributeValueLength: 10}, ErrAttributeValueTooLong},
+		{"session data value length", sessionData, ParseLimits{MaxAttributeValueLength: 1 << 10}, ErrAttributeValueTooLong},
+		{"unlimited session data", sessionData, NoParseLimits, nil},
+		{"start attributes", start, ParseLimits{MaxAttributes: 1}, ErrTooManyAttributes},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			limits := test.limits
+			err := Parse(strings.NewReader(test.playlist), testBaseURL, &ParserHandler{Limits: &limits})
+			if test.err == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.True(t, errors.Is(err, test.err), "expected %v, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestParseLongLine(t *testing.T) {
+	uri := strings.Repeat("a", 10000) + ".ts"
+	var playlist *MediaPlaylist
+	err := Parse(strings.NewReader("#EXTM3U\n#EXTINF:4,\n"+uri+"\n"), testBaseURL, &ParserHandler{
+		HandleMediaPlaylist: func(p *MediaPlaylist) { playlist = p },
+	})
+	if assert.NoError(t, err) {
+		assert.Equal(t, uri, playlist.MediaSegments[0].URILine.URL)
+	}
+}
+
+func TestParseCommentsAndHeader(t *testing.T) {
+	var playlist *MediaPlaylist
+	handler := &ParserHandler{HandleMediaPlaylist: func(p *MediaPlaylist) { playlist = p }}
+	input := "#EXTM3U\r\n# generated by packager\r\n#EXTINF:4,\r\na.ts\r\n"
+	if assert.NoError(t, Parse(strings.NewReader(input), testBaseURL, handler)) {
+		assert.Equal(t, CommentLineType, playlist.Lines[1].Type)
+		assert.Equal(t, "# generated by packager", playlist.Lines[1].Comment)
+		assert.Equal(t, "a.ts", playlist.MediaSegments[0].URILine.URL)
+		assert.Equal(t, "#EXTM3U\n# generated by packager\n#EXTINF:4,\na.ts\n", playlist.Format())
+	}
+
+	for name, input := range map[string]string{
+		"missing header":    "#EXTINF:4,\na.ts\n",
+		"byte order mark":   "\xEF\xBB\xBF#EXTM3U\n#EXTINF:4,\na.ts\n",
+		"control character": "#EXTM3U\n#EXTINF:4,\x01\na.ts\n",
+		"unquoted tab":      "#EXTM3U\n#EXTINF:4,\ttitle\na.ts\n",
+	} {
+		err := Parse(strings.NewReader(input), testBaseURL, &ParserHandler{})
+		assert.True(t, errors.Is(err, ErrFormat), "%s: expected ErrFormat, got %v", name, err)
+		err = Parse(strings.NewReader(input), testBaseURL, &ParserHandler{Lenient: true})
+		assert.NoError(t, err, name)
+	}
+
+	err := Parse(strings.NewReader("#EXTM3U\n#EXTINF:4,\na\x00.ts\n"), testBaseURL, &ParserHandler{Lenient: true})
+	assert.True(t, errors.Is(err, ErrFormat), "expected ErrFormat for NUL, got %v", err)
+}
+
+func TestParseWarnings(t *testing.T) {
+	input := "#EXTM3U\n" +
+		"#EXT-X-TARGETDURATION:4\n" +
+		"#EXT-X-ALLOW-CACHE:NO\n" +
+		"#EXT-X-VENDOR-THING:1\n" +
+		"#EXT-X-SESSION-DATA:DATA-ID=\"com.example.title\",VALUE=\"Title\"\n" +
+		"#EXT-X-KEY:METHOD=AES-128,URI=\"a.key\",URI=\"b.key\"\n" +
+		"#EXTINF:6,\n" +
+		"a.ts\n"
+	var warnings []*Warning
+	var playlist *MediaPlaylist
+	err := Parse(strings.NewReader(input), testBaseURL, &ParserHandler{
+		HandleWarning:       func(w *Warning) { warnings = append(warnings, w) },
+		HandleMediaPlaylist: func(p *MediaPlaylist) { playlist = p },
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	var codes []WarningCode
+	var lines []int
+	for _, w := range warnings {
+		codes = append(codes, w.Code)
+		lines = append(lines, w.LineNum)
+	}
+	assert.Equal(t, []WarningCode{WarnDeprecatedTag, WarnUnknownTag, WarnIgnoredTag, WarnDuplicateAttribute, WarnSegmentExceedsTargetDuration}, codes)
+	assert.Equal(t, []int{3, 4, 5, 6, 8}, lines)
+	assert.Equal(t, warnings, playlist.Warnings)
+	assert.Equal(t, "https://example.com/live/b.key", playlist.MediaSegments[0].Key.URI.String())
+}
+
+func TestParseKeyMethods(t *testing.T) {
+	for _, method := range []KeyMethod{KeyMethodSampleAESCTR, KeyMethodISO230017} {
+		input := "#EXTM3U\n" +
+			"#EXT-X-TARGETDURATION:4\n" +
+			"#EXT-X-KEY:METHOD=" + string(method) + ",URI=\"skd://key\",KEYFORMAT=\"com.apple.streamingkeydelivery\"\n" +
+			"#EXTINF:4,\n" +
+			"a.mp4\n"
+		var playlist *MediaPlaylist
+		err := Parse(strings.NewReader(input), testBaseURL, &ParserHandler{
+			HandleMediaPlaylist: func(p *MediaPlaylist) { playlist = p },
+		})
+		if assert.NoError(t, err, method) {
+			assert.Equal(t, method, playlist.MediaSegments[0].Key.Method)
+		}
+	}
+}
+
+func TestParseHandlerEvents(t *testing.T) {
+	input := "#EXTM3U\n" +
+		"#EXT-X-TARGETDURATION:4\n" +
+		`#EXT-X-DATERANGE:ID="ad1",START-DATE="2022-05-01T10:00:00.000Z",DURATION=30.5,X-COM-EXAMPLE-AD-ID="XYZ123"` + "\n" +
+		`#EXT-X-MAP:URI="init.mp4"` + "\n" +
+		"#EXTINF:4,\n" +
+		"a.ts\n" +
+		"#EXT-X-DISCONTINUITY\n" +
+		`#EXT-X-KEY:METHOD=AES-128,URI="key1"` + "\n" +
+		"#EXTINF:4,\n" +
+		"b.ts\n"
+	var events []string
+	err := Parse(strings.NewReader(input), testBaseURL, &ParserHandler{
+		HandleDateRange: func(r *DateRange, p *MediaPlaylist) bool {
+			events = append(events, "daterange "+r.ID+" "+r.End().Format("15:04:05.0"))
+			return true
+		},
+		HandleMediaInitMap: func(m *MediaInitMap, p *MediaPlaylist) bool {
+			events = append(events, "map")
+			return true
+		},
+		HandleMediaSegment: func(s *MediaSegment, p *MediaPlaylist) bool {
+			events = append(events, "segment")
+			return true
+		},
+		HandleDiscontinuity: func(seq uint64, p *MediaPlaylist) bool {
+			events = append(events, "discontinuity")
+			return true
+		},
+		HandleKey: func(k *Key, p *MediaPlaylist) bool {
+			events = append(events, "key")
+			return false
+		},
+		HandleMediaPlaylist: func(p *MediaPlaylist) {
+			events = append(events, "playlist")
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"daterange ad1 10:00:30.5", "map", "segment", "discontinuity", "key", "playlist"}, events)
+
+	var errLine *Line
+	err = Parse(strings.NewReader("#EXTM3U\n#EXTINF:abc,\na.ts\n"), testBaseURL, &ParserHandler{
+		HandleError: func(err error, line *Line) { errLine = line },
+	})
+	assert.Error(t, err)
+	if assert.NotNil(t, errLine) {
+		assert.Equal(t, 2, errLine.LineNum)
+		assert.Equal(t, "EXTINF", errLine.Tag.Name)
+	}
+
+	// the rendition groups are validated once every line was parsed
+	for _, input := range []string{"#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=1000,AUDIO=\"missing\"\nlow.m3u8\n", "#EXTM3U\n#EXTINF:4,\x01\na.ts\n"} {
+		errLine = &Line{}
+		err = Parse(strings.NewReader(input), testBaseURL, &ParserHandler{
+			HandleError: func(err error, line *Line) { errLine = line },
+		})
+		assert.Error(t, err)
+		assert.Nil(t, errLine, input)
+	}
+}
+
+func TestParseSpatialAttributes(t *testing.T) {
+	input := "#EXTM3U\n" +
+		`#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="atmos",NAME="English",LANGUAGE="en",CHANNELS="16/JOC/IMMERSIVE",BIT-DEPTH=24,SAMPLE-RATE=48000,STABLE-RENDITION-ID="en-atmos",URI="atmos.m3u8"` + "\n" +
+		`#EXT-X-STREAM-INF:BANDWIDTH=20000000,CODECS="hvc1.2.20000000.L153.B0,ec-3",SUPPLEMENTAL-CODECS="dvh1.08.07/db4h",REQ-VIDEO-LAYOUT="CH-STEREO,CH-MONO/PROJ-RECT",PATHWAY-ID="CDN-A",AUDIO="atmos"` + "\n" +
+		"stereo.m3u8\n"
+	var playlist *MasterPlaylist
+	err := Parse(strings.NewReader(input), testBaseURL, &ParserHandler{
+		HandleMasterPlaylist: func(p *MasterPlaylist) { playlist = p },
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	stream := playlist.VariantStreams[0]
+	if assert.Len(t, stream.SupplementalCodecs, 1) {
+		assert.EqualValues(t, 8, stream.SupplementalCodecs[0].DolbyVision.Profile)
+		assert.Equal(t, []string{"db4h"}, stream.SupplementalCodecs[0].Brands)
+		assert.Equal(t, "dvh1.08.07/db4h", stream.SupplementalCodecs[0].String())
+	}
+	assert.True(t, stream.ReqVideoLayout.Stereo())
+	assert.Equal(t, VideoProjectionRectilinear, stream.ReqVideoLayout.Projection())
+	assert.Equal(t, "CH-STEREO,CH-MONO/PROJ-RECT", stream.ReqVideoLayout.String())
+	assert.Equal(t, "CDN-A", *stream.PathwayID)
+
+	rendition := playlist.RenditionGroups[Audio]["atmos"][0]
+	assert.EqualValues(t, 24, *rendition.BitDepth)
+	assert.EqualValues(t, 48000, *rendition.SampleRate)
+	assert.Equal(t, "en-atmos", *rendition.StableRenditionID)
+	assert.Nil(t, rendition.AssocLanguage)
+	assert.Equal(t, []ChannelUsage{ChannelUsageImmersive}, rendition.Channels.AudioChannelUsage)
+	assert.True(t, rendition.Channels.IsSpatial())
+}
+
+func TestParseRenditionGroups(t *testing.T) {
+	input := "#EXTM3U\n" +
+		`#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aac",NAME="English",LANGUAGE="en",URI="en.m3u8"` + "\n" +
+		`#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aac",NAME="French",LANGUAGE="fr",URI="fr.m3u8"` + "\n" +
+		`#EXT-X-MEDIA:TYPE=CLOSED-CAPTIONS,GROUP-ID="cc",NAME="English",INSTREAM-ID="CC1"` + "\n" +
+		`#EXT-X-STREAM-INF:BANDWIDTH=1000000,AUDIO="aac",CLOSED-CAPTIONS="cc"` + "\n" +
+		"low.m3u8\n" +
+		`#EXT-X-STREAM-INF:BANDWIDTH=2000000,AUDIO="aac",CLOSED-CAPTIONS=NONE` + "\n" +
+		"high.m3u8\n"
+	var playlist *MasterPlaylist
+	err := Parse(strings.NewReader(input), testBaseURL, &ParserHandler{
+		HandleMasterPlaylist: func(p *MasterPlaylist) { playlist = p },
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	low, high := playlist.VariantStreams[0], playlist.VariantStreams[1]
+	assert.Len(t, playlist.Renditions(low, Audio), 2)
+	assert.Len(t, playlist.Renditions(low, ClosedCaptions), 1)
+	assert.Nil(t, playlist.Renditions(high, ClosedCaptions))
+	assert.Nil(t, playlist.Renditions(high, Video))
+	assert.Equal(t, 2, playlist.RenditionGroup(Audio, "aac")[0].TagLine.LineNum)
+
+	invalid := "#EXTM3U\n" +
+		`#EXT-X-MEDIA:TYPE=CLOSED-CAPTIONS,GROUP-ID="cc",NAME="English",INSTREAM-ID="CC5",URI="cc.m3u8"` + "\n" +
+		`#EXT-X-STREAM-INF:BANDWIDTH=1000000,AUDIO="missing",CLOSED-CAPTIONS="cc"` + "\n" +
+		"low.m3u8\n"
+	err = Parse(strings.NewReader(invalid), testBaseURL, &ParserHandler{})
+	assert.ErrorIs(t, err, ErrFormat)
+	var groupErr *RenditionGroupError
+	if assert.True(t, errors.As(err, &groupErr)) {
+		if assert.Len(t, groupErr.Dangling, 1) {
+			assert.Equal(t, Audio, groupErr.Dangling[0].Type)
+			assert.Equal(t, "missing", groupErr.Dangling[0].GroupID)
+		}
+		assert.Len(t, groupErr.Invalid, 2)
+	}
+
+	var warnings []WarningCode
+	err = Parse(strings.NewReader(invalid), testBaseURL, &ParserHandler{
+		Lenient:       true,
+		HandleWarning: func(w *Warning) { warnings = append(warnings, w.Code) },
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []WarningCode{WarnDanglingRenditionGroup, WarnInvalidRendition, WarnInvalidRendition}, warnings)
+
+	iframes := "#EXTM3U\n" +
+		`#EXT-X-MEDIA:TYPE=VIDEO,GROUP-ID="v",NAME="Main",URI="main.m3u8"` + "\n" +
+		`#EXT-X-MEDIA:TYPE=CLOSED-CAPTIONS,GROUP-ID="cc2",NAME="B",INSTREAM-ID="CC9"` + "\n" +
+		`#EXT-X-MEDIA:TYPE=CLOSED-CAPTIONS,GROUP-ID="cc1",NAME="A",INSTREAM-ID="CC9"` + "\n" +
+		`#EXT-X-STREAM-INF:BANDWIDTH=1000000,VIDEO="v"` + "\n" +
+		"low.m3u8\n" +
+		`#EXT-X-I-FRAME-STREAM-INF:BANDWIDTH=100000,VIDEO="v",URI="low-iframes.m3u8"` + "\n" +
+		`#EXT-X-I-FRAME-STREAM-INF:BANDWIDTH=100000,VIDEO="missing",URI="high-iframes.m3u8"` + "\n"
+	for i := 0; i < 5; i++ {
+		err = Parse(strings.NewReader(iframes), testBaseURL, &ParserHandler{})
+		if assert.True(t, errors.As(err, &groupErr)) {
+			if assert.Len(t, groupErr.Dangling, 1) {
+				assert.Equal(t, "missing", *groupErr.Dangling[0].IframeStream.Video)
+			}
+			// the problems are listed by group ID
+			assert.True(t, strings.HasSuffix(err.Error(), `"A" in group "cc1" has invalid INSTREAM-ID "CC9"; line 3: CLOSED-CAPTIONS rendition "B" in group "cc2" has invalid INSTREAM-ID "CC9"`), err.Error())
+			assert.True(t, strings.HasPrefix(err.Error(), `invalid rendition groups: line 8: VIDEO group "missing" is not defined`), err.Error())
+		}
+	}
+}
+
+func TestParseServerControl(t *testing.T) {
+	var playlist *MediaPlaylist
+	err := Parse(strings.NewReader("#EXTM3U\n#EXT-X-TARGETDURATION:4\n#EXT-X-SERVER-CONTROL:CAN-SKIP-UNTIL=24,HOLD-BACK=12.5,CAN-BLOCK-RELOAD=YES\n"), testBaseURL, &ParserHandler{
+		HandleMediaPlaylist: func(p *MediaPlaylist) { playlist = p },
+	})
+	if assert.NoError(t, err) {
+		assert.Equal(t, 24*time.Second, *playlist.ServerControl.CanSkipUntil)
+		assert.Equal(t, 12500*time.Millisecond, *playlist.ServerControl.HoldBack)
+		assert.Nil(t, playlist.ServerControl.PartHoldBack)
+	}
+	for _, value := range []string{"HOLD-BACK=-1", "PART-HOLD-BACK=1e300", "CAN-SKIP-UNTIL=10000000000"} {
+		err := Parse(strings.NewReader("#EXTM3U\n#EXT-X-TARGETDURATION:4\n#EXT-X-SERVER-CONTROL:"+value+"\n"), testBaseURL, &ParserHandler{})
+		assert.ErrorIs(t, err, ErrFormat, value)
+	}
+}
+
+func TestParseLowLatency(t *testing.T) {
+	input := "#EXTM3U\n" +
+		"#EXT-X-TARGETDURATION:4\n" +
+		"#EXT-X-PART-INF:PART-TARGET=1.002\n" +
+		"#EXT-X-MEDIA-SEQUENCE:10\n" +
+		"#EXT-X-SKIP:SKIPPED-SEGMENTS=3,RECENTLY-REMOVED-DATERANGES=\"a\tb\"\n" +
+		`#EXT-X-PART:DURATION=1,URI="s13.mp4",BYTERANGE="100@0",INDEPENDENT=YES` + "\n" +
+		`#EXT-X-PART:DURATION=1,URI="s13.mp4",BYTERANGE="200"` + "\n" +
+		"#EXTINF:2,\ns13.mp4\n" +
+		`#EXT-X-PART:DURATION=1,URI="s14.mp4",BYTERANGE="50"` + "\n" +
+		`#EXT-X-PRELOAD-HINT:TYPE=PART,URI="s14.mp4",BYTERANGE-START=50` + "\n" +
+		`#EXT-X-RENDITION-REPORT:URI="../audio/index.m3u8",LAST-MSN=14,LAST-PART=0` + "\n"
+	var playlist *MediaPlaylist
+	err := Parse(strings.NewReader(input), testBaseURL, &ParserHandler{
+		HandleMediaPlaylist: func(p *MediaPlaylist) { playlist = p },
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, 1002*time.Millisecond, playlist.PartTarget)
+	assert.Equal(t, []string{"a", "b"}, playlist.Skip.RecentlyRemovedDateRanges)
+	if assert.Len(t, playlist.MediaSegments, 1) {
+		s := playlist.MediaSegments[0]
+		assert.Equal(t, uint64(13), s.MediaSequence)
+		if assert.Len(t, s.Parts, 2) {
+			assert.True(t, s.Parts[0].Independent)
+			// the byte range follows the previous one of the same resource
+			assert.Equal(t, ByteRange{Offset: 100, Length: 200}, *s.Parts[1].ByteRange)
+		}
+	}
+	if assert.Len(t, playlist.Parts, 1) {
+		assert.Equal(t, ByteRange{Offset: 0, Length: 50}, *playlist.Parts[0].ByteRange)
+	}
+	if assert.Len(t, playlist.PreloadHints, 1) {
+		assert.Equal(t, uint64(50), playlist.PreloadHints[0].ByteRangeStart)
+		assert.Nil(t, playlist.PreloadHints[0].ByteRangeLength)
+	}
+	if assert.Len(t, playlist.RenditionReports, 1) {
+		assert.Equal(t, "https://example.com/audio/index.m3u8", playlist.RenditionReports[0].URI.String())
+	}
+}
@@ -0,0 +1,314 @@
+package hls
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+)
+
+// ErrPartsMismatch is reported when the Partial Segments downloaded do not
+// add up to their parent segment.
+var ErrPartsMismatch = errors.New("partial segments do not match their segment")
+
+// PartDownloader downloads the Partial Segments of a Low-Latency media
+// playlist as they appear, and writes the segments they make up to a Sink once
+// they are published. Update is called with every reload of the playlist,
+// from a single goroutine.
+//
+// Downloading starts with the segment being produced at the first Update.
+// The resources of EXT-X-PRELOAD-HINT tags are requested as soon as they are
+// hinted, the server holding the responses until they are available, and the
+// parts and init maps they turn out to be are read from these responses.
+//
+// When a segment is published, its parts are assembled into it. Parts that
+// are byte ranges of the segment resource must be contiguous, and the parts
+// must add up to the segment byte range if it has one, else to the length of
+// the segment resource, as answered to a HEAD request. The segment is
+// downloaded in full instead when its parts do not match those downloaded or
+// do not add up, when its length is unknown, when they are no longer listed,
+// having been replaced by the segment between reloads, or when some were
+// missed.
+type PartDownloader struct {
+	Downloader *Downloader                                                                    // [OPTIONAL][DEFAULT=&Downloader{}] its Client, Header, Retries, Backoff and KeyProvider are used; segments and init maps are decrypted, parts are not
+	Sink       Sink                                                                           // [REQUIRED] receives the segments and the init maps they use
+	HandlePart func(mediaSequence uint64, index int, part *PartialSegment, data []byte) error // [OPTIONAL] called for every part downloaded, in order
+
+	started    bool
+	next       uint64 // the Media Sequence Number of the next segment to write
+	parts      []*PartialSegment
+	partData   [][]byte // of the parts of the next segment downloaded
+	initMapKey string   // of the last init map written
+	keys       KeyProvider
+	hints      map[string]*hintFetch
+	ctx        context.Context
+	cancel     context.CancelFunc
+}
+
+// hintFetch is the request for the resource of a preload hint.
+type hintFetch struct {
+	hint   *PreloadHint
+	cancel context.CancelFunc
+	done   chan struct{}
+	data   []byte
+	err    error
+}
+
+func hintKey(t PreloadHintType, u *url.URL, offset uint64) string {
+	return fmt.Sprintf("%s %s@%d", t, u, offset)
+}
+
+func (d *PartDownloader) downloader() *Downloader {
+	if d.Downloader != nil {
+		return d.Downloader
+	}
+	return &Downloader{}
+}
+
+// Update downloads the new parts of a reload of the playlist, and writes the
+// segments published since the previous one.
+func (d *PartDownloader) Update(ctx context.Context, p *MediaPlaylist) (err error) {
+	if d.ctx == nil {
+		// hinted resources outlive the Update that requested them
+		d.ctx, d.cancel = context.WithCancel(context.Background())
+		d.hints = make(map[string]*hintFetch)
+		d.keys = d.downloader().KeyProvider
+		if _, ok := d.keys.(*CachingKeyProvider); !ok && d.keys != nil {
+			d.keys = &CachingKeyProvider{Provider: d.keys}
+		}
+	}
+	producing := p.MediaSequence + uint64(len(p.MediaSegments))
+	if p.Skip != nil && len(p.MediaSegments) > 0 {
+		producing = p.MediaSegments[len(p.MediaSegments)-1].MediaSequence + 1
+	}
+	if !d.started {
+		d.started = true
+		d.next = producing
+	}
+	d.startHints(p.PreloadHints)
+
+	for _, s := range p.MediaSegments {
+		if s.MediaSequence < d.next {
+			continue
+		}
+		if s.MediaSequence > d.next {
+			// the segments between were removed before they were published
+			d.resetParts()
+			d.next = s.MediaSequence
+		}
+		if err = d.writeSegment(ctx, s); err != nil {
+			return
+		}
+		d.next = s.MediaSequence + 1
+		d.resetParts()
+	}
+	if d.next == producing && !p.EndList {
+		if err = d.downloadParts(ctx, d.next, p.Parts); err != nil {
+			return
+		}
+	}
+	d.pruneHints(p.PreloadHints)
+	return
+}
+
+// Close cancels the requests for hinted resources.
+func (d *PartDownloader) Close() {
+	if d.cancel != nil {
+		d.cancel()
+	}
+}
+
+func (d *PartDownloader) resetParts() {
+	d.parts, d.partData = nil, nil
+}
+
+// downloadParts downloads the parts of a segment not downloaded yet, parts
+// being listed from the first one.
+func (d *PartDownloader) downloadParts(ctx context.Context, mediaSequence uint64, parts []*PartialSegment) (err error) {
+	if !samePartPrefix(d.parts, parts) {
+		// the server replaced the parts
+		d.resetParts()
+	}
+	for i := len(d.parts); i < len(parts); i++ {
+		part := parts[i]
+		var data []byte
+		if !part.IsGap {
+			if data, err = d.fetch(ctx, PreloadHintTypePart, part.URI, part.ByteRange); err != nil {
+				return
+			}
+		}
+		d.parts = append(d.parts, part)
+		d.partData = append(d.partData, data)
+		if d.HandlePart != nil {
+			if err = d.HandlePart(mediaSequence, i, part, data); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+// writeSegment writes a published segment, from its parts if they can be
+// assembled, and the init map it uses if it changed.
+func (d *PartDownloader) writeSegment(ctx context.Context, s *MediaSegment) (err error) {
+	downloader := d.downloader()
+	if m := s.MediaInitMap; m != nil {
+		if key := MediaInitMapKey(m); key != d.initMapKey {
+			var data []byte
+			if data, err = d.fetch(ctx, PreloadHintTypeMap, m.URI, m.ByteRange); err != nil {
+				return
+			}
+			if data, err = downloader.decrypt(ctx, d.keys, m.Key, true, 0, data); err != nil {
+				return
+			}
+			if err = d.Sink.WriteMediaInitMap(m, data); err != nil {
+				return
+			}
+			d.initMapKey = key
+		}
+	}
+	if s.IsGap {
+		return
+	}
+
+	var data []byte
+	// the parts downloaded must be the first ones of the segment, the others
+	// being downloaded now
+	if len(d.parts) > 0 && len(s.Parts) >= len(d.parts) && samePartPrefix(d.parts, s.Parts) {
+		if err = d.downloadParts(ctx, s.MediaSequence, s.Parts); err != nil {
+			return
+		}
+		length := int64(-1)
+		if s.ByteRange != nil {
+			length = int64(s.ByteRange.Length)
+		} else if length, err = resourceLength(ctx, downloader.Client, downloader.Header, s.URI); err != nil {
+			// the segment is downloaded instead
+			length, err = -1, nil
+		}
+		if length >= 0 {
+			data, err = assembleParts(s, d.parts, d.partData, uint64(length))
+			if err != nil && !errors.Is(err, ErrPartsMismatch) {
+				return
+			}
+		}
+	}
+	if data == nil {
+		if data, err = downloader.fetch(ctx, s.URI, s.ByteRange); err != nil {
+			return
+		}
+	}
+	if data, err = downloader.decrypt(ctx, d.keys, s.Key, false, s.MediaSequence, data); err != nil {
+		return
+	}
+	return d.Sink.WriteMediaSegment(s, data)
+}
+
+// assembleParts concatenates the parts of a segment, checking them against
+// the segment where they are byte ranges of its resource, and their total
+// against its length.
+func assembleParts(s *MediaSegment, parts []*PartialSegment, partData [][]byte, length uint64) (data []byte, err error) {
+	var offset uint64
+	if s.ByteRange != nil {
+		offset = s.ByteRange.Offset
+	}
+	var b bytes.Buffer
+	for i, part := range parts {
+		if part.IsGap {
+			return nil, fmt.Errorf("segment %d part %d is a gap: %w", s.MediaSequence, i, ErrPartsMismatch)
+		}
+		if part.ByteRange != nil && part.URI.String() == s.URI.String() {
+			if part.ByteRange.Offset != offset {
+				return nil, fmt.Errorf("segment %d part %d starts at %d instead of %d: %w", s.MediaSequence, i, part.ByteRange.Offset, offset, ErrPartsMismatch)
+			}
+			offset = part.ByteRange.End()
+		}
+		b.Write(partData[i])
+	}
+	if uint64(b.Len()) != length {
+		return nil, fmt.Errorf("segment %d parts have %d bytes instead of %d: %w", s.MediaSequence, b.Len(), length, ErrPartsMismatch)
+	}
+	return b.Bytes(), nil
+}
+
+func samePartPrefix(downloaded, listed []*PartialSegment) bool {
+	if len(listed) < len(downloaded) {
+		return false
+	}
+	for i, part := range downloaded {
+		other := listed[i]
+		if part.URI.String() != other.URI.String() || (part.ByteRange == nil) != (other.ByteRange == nil) {
+			return false
+		}
+		if part.ByteRange != nil && *part.ByteRange != *other.ByteRange {
+			return false
+		}
+	}
+	return true
+}
+
+// startHints requests the resources of the preload hints not requested yet.
+func (d *PartDownloader) startHints(hints []*PreloadHint) {
+	client, header := d.downloader().Client, d.downloader().Header
+	for _, hint := range hints {
+		key := hintKey(hint.Type, hint.URI, hint.ByteRangeStart)
+		if _, ok := d.hints[key]; ok {
+			continue
+		}
+		ctx, cancel := context.WithCancel(d.ctx)
+		request := &hintFetch{hint: hint, cancel: cancel, done: make(chan struct{})}
+		d.hints[key] = request
+		go func() {
+			defer close(request.done)
+			if length := request.hint.ByteRangeLength; length != nil {
+				request.data, request.err = ReadResource(ctx, client, header, request.hint.URI, &ByteRange{Offset: request.hint.ByteRangeStart, Length: *length})
+			} else {
+				request.data, request.err = readResourceFrom(ctx, client, header, request.hint.URI, request.hint.ByteRangeStart)
+			}
+		}()
+	}
+}
+
+// pruneHints cancels the requests of the hints that were withdrawn.
+func (d *PartDownloader) pruneHints(hints []*PreloadHint) {
+	current := make(map[string]bool)
+	for _, hint := range hints {
+		current[hintKey(hint.Type, hint.URI, hint.ByteRangeStart)] = true
+	}
+	for key, fetch := range d.hints {
+		if !current[key] {
+			fetch.cancel()
+			delete(d.hints, key)
+		}
+	}
+}
+
+// fetch reads a part or an init map, from the response to its preload hint
+// if there is one, else with a request of its own.
+func (d *PartDownloader) fetch(ctx context.Context, t PreloadHintType, u *url.URL, br *ByteRange) (data []byte, err error) {
+	var offset uint64
+	if br != nil {
+		offset = br.Offset
+	}
+	key := hintKey(t, u, offset)
+	if fetch, ok := d.hints[key]; ok {
+		delete(d.hints, key)
+		select {
+		case <-fetch.done:
+		case <-ctx.Done():
+			fetch.cancel()
+			return nil, ctx.Err()
+		}
+		fetch.cancel()
+		if fetch.err == nil {
+			switch {
+			case br == nil:
+				return fetch.data, nil
+			case uint64(len(fetch.data)) >= br.Length:
+				return fetch.data[:br.Length], nil
+			}
+		}
+		// the hinted resource turned out different, it is requested again
+	}
+	return d.downloader().fetch(ctx, u, br)
+}
@@ -0,0 +1,132 @@
+package hls
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPartDownloader(t *testing.T) {
+	requested, gate := make(chan struct{}), make(chan struct{})
+	requests := map[string]int{}
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(r.URL.Path, "/")
+		mu.Lock()
+		requests[r.Method+" "+name]++
+		mu.Unlock()
+		switch {
+		case name == "s1.1.m4s":
+			// held until the part is available
+			close(requested)
+			<-gate
+		case name == "s3.m4s":
+			name = "s3 was replaced"
+		case strings.Count(name, ".") == 1 && strings.HasSuffix(name, ".m4s"):
+			// a segment is made of its two parts
+			base := strings.TrimSuffix(name, ".m4s")
+			name = base + ".0.m4s" + base + ".1.m4s"
+		}
+		w.Write([]byte(name))
+	}))
+	defer server.Close()
+
+	var handled []string
+	sink := &recordingSink{}
+	d := &PartDownloader{Sink: sink, HandlePart: func(mediaSequence uint64, index int, part *PartialSegment, data []byte) error {
+		handled = append(handled, fmt.Sprintf("%d.%d:%s", mediaSequence, index, data))
+		return nil
+	}}
+	defer d.Close()
+	header := "#EXT-X-VERSION:9\n#EXT-X-PART-INF:PART-TARGET=1\n#EXT-X-MAP:URI=\"init.mp4\"\n#EXTINF:2,\ns0.m4s\n"
+	parts := func(n int) string {
+		return fmt.Sprintf("#EXT-X-PART:DURATION=1,URI=\"s%d.0.m4s\"\n#EXT-X-PART:DURATION=1,URI=\"s%d.1.m4s\"\n#EXTINF:2,\ns%d.m4s\n", n, n, n)
+	}
+
+	err := d.Update(context.Background(), parseTestMediaPlaylist(t, server.URL, header+
+		"#EXT-X-PART:DURATION=1,URI=\"s1.0.m4s\"\n"+
+		"#EXT-X-PRELOAD-HINT:TYPE=PART,URI=\"s1.1.m4s\"\n"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []string{"1.0:s1.0.m4s"}, handled)
+	assert.Empty(t, sink.writes)
+
+	<-requested
+	close(gate)
+	err = d.Update(context.Background(), parseTestMediaPlaylist(t, server.URL, header+parts(1)+
+		"#EXT-X-PART:DURATION=1,URI=\"s2.0.m4s\"\n"+
+		"#EXT-X-PRELOAD-HINT:TYPE=PART,URI=\"s2.1.m4s\"\n"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []string{"init:init.mp4", "s1.0.m4ss1.1.m4s"}, sink.writes)
+
+	// the parts of s2 were replaced by the segment
+	err = d.Update(context.Background(), parseTestMediaPlaylist(t, server.URL, header+parts(1)+
+		"#EXTINF:2,\ns2.m4s\n"+
+		"#EXT-X-PART:DURATION=1,URI=\"s3.0.m4s\"\n"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	// the parts of s3 do not add up to the segment
+	err = d.Update(context.Background(), parseTestMediaPlaylist(t, server.URL, header+parts(1)+
+		"#EXTINF:2,\ns2.m4s\n"+parts(3)+"#EXT-X-ENDLIST\n"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []string{"init:init.mp4", "s1.0.m4ss1.1.m4s", "s2.0.m4ss2.1.m4s", "s3 was replaced"}, sink.writes)
+	assert.Equal(t, []string{"1.0:s1.0.m4s", "1.1:s1.1.m4s", "2.0:s2.0.m4s", "3.0:s3.0.m4s", "3.1:s3.1.m4s"}, handled)
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, 1, requests["GET s1.1.m4s"])
+	assert.Equal(t, 1, requests["HEAD s1.m4s"])
+	assert.Equal(t, 0, requests["GET s1.m4s"])
+	assert.Equal(t, 0, requests["GET s0.m4s"])
+	assert.Equal(t, 1, requests["GET s2.m4s"])
+	assert.Equal(t, 1, requests["GET s3.m4s"])
+}
+
+func TestPartDownloaderByteRanges(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, r.URL.Path, time.Time{}, strings.NewReader("aaaabbbb"))
+	}))
+	defer server.Close()
+
+	sink := &recordingSink{}
+	d := &PartDownloader{Sink: sink}
+	defer d.Close()
+	header := "#EXT-X-VERSION:9\n#EXT-X-PART-INF:PART-TARGET=1\n#EXTINF:2,\ns0.mp4\n"
+
+	err := d.Update(context.Background(), parseTestMediaPlaylist(t, server.URL, header+
+		"#EXT-X-PART:DURATION=1,URI=\"s1.mp4\",BYTERANGE=\"4@0\"\n"+
+		"#EXT-X-PRELOAD-HINT:TYPE=PART,URI=\"s1.mp4\",BYTERANGE-START=4\n"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	err = d.Update(context.Background(), parseTestMediaPlaylist(t, server.URL, header+
+		"#EXT-X-PART:DURATION=1,URI=\"s1.mp4\",BYTERANGE=\"4@0\"\n"+
+		"#EXT-X-PART:DURATION=1,URI=\"s1.mp4\",BYTERANGE=\"4@4\"\n"+
+		"#EXT-X-BYTERANGE:8@0\n#EXTINF:2,\ns1.mp4\n"+
+		"#EXT-X-PART:DURATION=1,URI=\"s2.mp4\",BYTERANGE=\"2@0\"\n"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []string{"aaaabbbb"}, sink.writes)
+
+	// the part does not add up to the segment, which is downloaded in full
+	err = d.Update(context.Background(), parseTestMediaPlaylist(t, server.URL, header+
+		"#EXT-X-BYTERANGE:8@0\n#EXTINF:2,\ns1.mp4\n"+
+		"#EXT-X-PART:DURATION=1,URI=\"s2.mp4\",BYTERANGE=\"2@0\"\n"+
+		"#EXT-X-BYTERANGE:4@0\n#EXTINF:2,\ns2.mp4\n"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"aaaabbbb", "aaaa"}, sink.writes)
+	}
+}
@@ -0,0 +1,248 @@
+package hls
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// PartialSegment is a Partial Segment of a Low-Latency playlist, from an
+// EXT-X-PART tag.
+type PartialSegment struct {
+	Tag         *Tag
+	URI         *url.URL      // [REQUIRED] the Partial Segment URI
+	Duration    time.Duration // [REQUIRED] the duration of the Partial Segment
+	Independent bool          // [OPTIONAL][DEFAULT=false] the Partial Segment contains an independent frame
+	ByteRange   *ByteRange    // [OPTIONAL] the Partial Segment is a sub-range of the resource identified by its URI
+	IsGap       bool          // [OPTIONAL][DEFAULT=false] the Partial Segment is not available
+}
+
+type PreloadHintType string
+
+const (
+	PreloadHintTypePart PreloadHintType = "PART"
+	PreloadHintTypeMap  PreloadHintType = "MAP"
+)
+
+// PreloadHint is a resource the client will most likely need, from an
+// EXT-X-PRELOAD-HINT tag.
+type PreloadHint struct {
+	Tag             *Tag
+	Type            PreloadHintType // [REQUIRED] PART or MAP
+	URI             *url.URL        // [REQUIRED]
+	ByteRangeStart  uint64          // [OPTIONAL][DEFAULT=0] the byte offset of the first byte of the hinted resource
+	ByteRangeLength *uint64         // [OPTIONAL] the length of the hinted resource, unknown when missing
+}
+
+// RenditionReport carries information about an associated Rendition that is
+// as up-to-date as the Playlist that contains it, from an
+// EXT-X-RENDITION-REPORT tag.
+type RenditionReport struct {
+	Tag      *Tag
+	URI      *url.URL // [REQUIRED] the Media Playlist of the Rendition, relative to the Playlist
+	LastMSN  *uint64  // [OPTIONAL] the Media Sequence Number of the last Media Segment of the Rendition
+	LastPart *uint64  // [OPTIONAL] the Part Index of the last Partial Segment of the Rendition
+}
+
+// Skip replaces the Media Segments of a Playlist Delta Update that were
+// skipped, from an EXT-X-SKIP tag.
+type Skip struct {
+	Tag                       *Tag
+	SkippedSegments           uint64   // [REQUIRED] the number of Media Segments replaced by the tag
+	RecentlyRemovedDateRanges []string // [OPTIONAL] the IDs of the date ranges removed from the Playlist recently
+}
+
+func (p *PartialSegment) ParseTag(tag *Tag, defaultOffset uint64) (err error) {
+	if tag.Name != "EXT-X-PART" {
+		err = fmt.Errorf("parsing partial segment using the wrong tag: %s: %w", tag.Name, ErrFormat)
+		return
+	}
+	p.Tag = tag
+	if _, err = tag.ParseAttributeList(); err != nil {
+		err = fmt.Errorf("failed parsing partial segment attribute list: %w", err)
+		return
+	}
+	return p.ParseAttributeList(tag.AttributeList, defaultOffset)
+}
+
+func (p *PartialSegment) ParseAttributeList(attrs *AttributeList, defaultOffset uint64) (err error) {
+	if attr := attrs.GetLast("URI"); attr == nil {
+		err = fmt.Errorf("EXT-X-PART tag is missing URI attribute: %w", ErrFormat)
+		return
+	} else if p.URI, err = parseURIAttribute(attr); err != nil {
+		return
+	}
+	if attr := attrs.GetLast("DURATION"); attr == nil {
+		err = fmt.Errorf("EXT-X-PART tag is missing DURATION attribute: %w", ErrFormat)
+		return
+	} else {
+		var seconds float64
+		if seconds, err = attr.Number(); err != nil {
+			err = fmt.Errorf("failed getting DURATION attribute: %w", err)
+			return
+		}
+		if p.Duration, err = parseSeconds(seconds); err != nil {
+			err = fmt.Errorf("failed parsing DURATION attribute: %w", err)
+			return
+		}
+	}
+	if attr := attrs.GetLast("INDEPENDENT"); attr != nil {
+		if p.Independent, err = attr.YesNo(); err != nil {
+			err = fmt.Errorf("failed getting INDEPENDENT attribute: %w", err)
+			return
+		}
+	}
+	if attr := attrs.GetLast("BYTERANGE"); attr != nil {
+		var value string
+		if value, err = attr.String(); err != nil {
+			err = fmt.Errorf("failed getting BYTERANGE attribute: %w", err)
+			return
+		}
+		br := &ByteRange{}
+		if err = br.ParseString(value, defaultOffset); err != nil {
+			err = fmt.Errorf("failed parsing BYTERANGE attribute value as ByteRange: %w", err)
+			return
+		}
+		p.ByteRange = br
+	}
+	if attr := attrs.GetLast("GAP"); attr != nil {
+		if p.IsGap, err = attr.YesNo(); err != nil {
+			err = fmt.Errorf("failed getting GAP attribute: %w", err)
+			return
+		}
+	}
+	return
+}
+
+func (h *PreloadHint) ParseTag(tag *Tag) (err error) {
+	if tag.Name != "EXT-X-PRELOAD-HINT" {
+		err = fmt.Errorf("parsing preload hint using the wrong tag: %s: %w", tag.Name, ErrFormat)
+		return
+	}
+	h.Tag = tag
+	if _, err = tag.ParseAttributeList(); err != nil {
+		err = fmt.Errorf("failed parsing preload hint attribute list: %w", err)
+		return
+	}
+	return h.ParseAttributeList(tag.AttributeList)
+}
+
+func (h *PreloadHint) ParseAttributeList(attrs *AttributeList) (err error) {
+	if attr := attrs.GetLast("TYPE"); attr == nil {
+		err = fmt.Errorf("EXT-X-PRELOAD-HINT tag is missing TYPE attribute: %w", ErrFormat)
+		return
+	} else {
+		var value string
+		if value, err = attr.Enum(); err != nil {
+			err = fmt.Errorf("failed getting TYPE attribute: %w", err)
+			return
+		}
+		switch h.Type = PreloadHintType(value); h.Type {
+		case PreloadHintTypePart, PreloadHintTypeMap:
+		default:
+			err = fmt.Errorf("invalid EXT-X-PRELOAD-HINT TYPE: %s: %w", value, ErrFormat)
+			return
+		}
+	}
+	if attr := attrs.GetLast("URI"); attr == nil {
+		err = fmt.Errorf("EXT-X-PRELOAD-HINT tag is missing URI attribute: %w", ErrFormat)
+		return
+	} else if h.URI, err = parseURIAttribute(attr); err != nil {
+		return
+	}
+	if attr := attrs.GetLast("BYTERANGE-START"); attr != nil {
+		if h.ByteRangeStart, err = attr.Uint(); err != nil {
+			err = fmt.Errorf("failed getting BYTERANGE-START attribute: %w", err)
+			return
+		}
+	}
+	if attr := attrs.GetLast("BYTERANGE-LENGTH"); attr != nil {
+		if h.ByteRangeLength, err = attr.UintPtr(); err != nil {
+			err = fmt.Errorf("failed getting BYTERANGE-LENGTH attribute: %w", err)
+			return
+		}
+	}
+	return
+}
+
+func (r *RenditionReport) ParseTag(tag *Tag) (err error) {
+	if tag.Name != "EXT-X-RENDITION-REPORT" {
+		err = fmt.Errorf("parsing rendition report using the wrong tag: %s: %w", tag.Name, ErrFormat)
+		return
+	}
+	r.Tag = tag
+	if _, err = tag.ParseAttributeList(); err != nil {
+		err = fmt.Errorf("failed parsing rendition report attribute list: %w", err)
+		return
+	}
+	return r.ParseAttributeList(tag.AttributeList)
+}
+
+func (r *RenditionReport) ParseAttributeList(attrs *AttributeList) (err error) {
+	if attr := attrs.GetLast("URI"); attr == nil {
+		err = fmt.Errorf("EXT-X-RENDITION-REPORT tag is missing URI attribute: %w", ErrFormat)
+		return
+	} else if r.URI, err = parseURIAttribute(attr); err != nil {
+		return
+	}
+	if attr := attrs.GetLast("LAST-MSN"); attr != nil {
+		if r.LastMSN, err = attr.UintPtr(); err != nil {
+			err = fmt.Errorf("failed getting LAST-MSN attribute: %w", err)
+			return
+		}
+	}
+	if attr := attrs.GetLast("LAST-PART"); attr != nil {
+		if r.LastPart, err = attr.UintPtr(); err != nil {
+			err = fmt.Errorf("failed getting LAST-PART attribute: %w", err)
+			return
+		}
+	}
+	return
+}
+
+func (s *Skip) ParseTag(tag *Tag) (err error) {
+	if tag.Name != "EXT-X-SKIP" {
+		err = fmt.Errorf("parsing skip using the wrong tag: %s: %w", tag.Name, ErrFormat)
+		return
+	}
+	s.Tag = tag
+	if _, err = tag.ParseAttributeList(); err != nil {
+		err = fmt.Errorf("failed parsing skip attribute list: %w", err)
+		return
+	}
+	return s.ParseAttributeList(tag.AttributeList)
+}
+
+func (s *Skip) ParseAttributeList(attrs *AttributeList) (err error) {
+	if attr := attrs.GetLast("SKIPPED-SEGMENTS"); attr == nil {
+		err = fmt.Errorf("EXT-X-SKIP tag is missing SKIPPED-SEGMENTS attribute: %w", ErrFormat)
+		return
+	} else if s.SkippedSegments, err = attr.Uint(); err != nil {
+		err = fmt.Errorf("failed getting SKIPPED-SEGMENTS attribute: %w", err)
+		return
+	}
+	if attr := attrs.GetLast("RECENTLY-REMOVED-DATERANGES"); attr != nil {
+		var value string
+		if value, err = attr.String(); err != nil {
+			err = fmt.Errorf("failed getting RECENTLY-REMOVED-DATERANGES attribute: %w", err)
+			return
+		}
+		if value != "" {
+			s.RecentlyRemovedDateRanges = strings.Split(value, "\t")
+		}
+	}
+	return
+}
+
+func parseURIAttribute(attr *Attribute) (u *url.URL, err error) {
+	var value string
+	if value, err = attr.String(); err != nil {
+		err = fmt.Errorf("failed getting URI attribute: %w", err)
+		return
+	}
+	if u, err = url.Parse(value); err != nil {
+		err = fmt.Errorf("failed parsing URI attribute value as URL: %w", err)
+	}
+	return
+}
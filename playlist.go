@@ -1,15 +1,28 @@
 package hls
 
+import "time"
+
 type Playlist struct {
-	Lines   []*Line
-	Version uint64 // [OPTIONAL][DEFAULT=1] indicates the compatibility version of the Playlist file, its associated media, and its server
+	Lines    []*Line
+	Version  uint64     // [OPTIONAL][DEFAULT=1] indicates the compatibility version of the Playlist file, its associated media, and its server
+	Warnings []*Warning // spec deviations tolerated while parsing
 }
 
 type MediaPlaylist struct {
 	*Playlist
 	MediaSegments         []*MediaSegment
-	MediaSequence         uint64 // [OPTIONAL][DEFAULT=0] indicates the Media Sequence Number of the first Media Segment that appears in a Playlist file
-	DiscontinuitySequence uint64 // [OPTIONAL][DEFAULT=0] allows synchronization between different Renditions of the same Variant Stream or different Variant Streams
+	TargetDuration        time.Duration      // [REQUIRED] the maximum Media Segment duration, rounded to the nearest integer number of seconds
+	MediaSequence         uint64             // [OPTIONAL][DEFAULT=0] indicates the Media Sequence Number of the first Media Segment that appears in a Playlist file
+	DiscontinuitySequence uint64             // [OPTIONAL][DEFAULT=0] allows synchronization between different Renditions of the same Variant Stream or different Variant Streams
+	DateRanges            []*DateRange       // [OPTIONAL] associate a range of time defined by a starting and ending date with a set of attribute/value pairs
+	PlaylistType          *PlaylistType      // [OPTIONAL] EVENT or VOD, the mutability of the playlist
+	EndList               bool               // [OPTIONAL][DEFAULT=false] indicates that no more Media Segments will be added to the playlist
+	ServerControl         *ServerControl     // [OPTIONAL] indicates the server support for Delivery Directives
+	PartTarget            time.Duration      // [OPTIONAL] the maximum Partial Segment duration, from EXT-X-PART-INF
+	Parts                 []*PartialSegment  // [OPTIONAL] the Partial Segments after the last Media Segment, of the segment being produced
+	PreloadHints          []*PreloadHint     // [OPTIONAL] resources the client will most likely need next
+	RenditionReports      []*RenditionReport // [OPTIONAL] the state of associated Renditions
+	Skip                  *Skip              // [OPTIONAL] the Media Segments skipped in a Playlist Delta Update, MediaSequence being that of the first skipped one
 }
 
 type MasterPlaylist struct {
@@ -17,6 +30,7 @@ type MasterPlaylist struct {
 	VariantStreams  []*VariantStream
 	IframeStreams   []*IframeStream
 	RenditionGroups map[RenditionType]map[string][]*Rendition
+	SessionData     []*SessionData // [OPTIONAL] arbitrary session data, from EXT-X-SESSION-DATA tags
 }
 
 func (playlsit *Playlist) Format() (str string) {
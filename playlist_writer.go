@@ -0,0 +1,297 @@
+package hls
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// programDateTimeLayout is the layout EXT-X-PROGRAM-DATE-TIME and
+// EXT-X-DATERANGE dates are written with.
+const programDateTimeLayout = "2006-01-02T15:04:05.000Z07:00"
+
+// WriteTo writes the playlist from its fields rather than its Lines: tags
+// that are not modeled are left out, and URIs are written resolved. EXT-X-KEY
+// and EXT-X-MAP tags are written where the key or the media initialization
+// section of the segments changes.
+func (p *MediaPlaylist) WriteTo(w io.Writer) (n int64, err error) {
+	var b bytes.Buffer
+	b.WriteString("#EXTM3U\n")
+	if p.Playlist != nil && p.Version > 1 {
+		fmt.Fprintf(&b, "#EXT-X-VERSION:%d\n", p.Version)
+	}
+	targetDuration := p.TargetDuration
+	for _, s := range p.MediaSegments {
+		if d := s.Duration.Round(time.Second); d > targetDuration {
+			targetDuration = d
+		}
+	}
+	fmt.Fprintf(&b, "#EXT-X-TARGETDURATION:%d\n", int64(targetDuration/time.Second))
+	if p.MediaSequence > 0 {
+		fmt.Fprintf(&b, "#EXT-X-MEDIA-SEQUENCE:%d\n", p.MediaSequence)
+	}
+	if p.DiscontinuitySequence > 0 {
+		fmt.Fprintf(&b, "#EXT-X-DISCONTINUITY-SEQUENCE:%d\n", p.DiscontinuitySequence)
+	}
+	if p.PlaylistType != nil {
+		fmt.Fprintf(&b, "#EXT-X-PLAYLIST-TYPE:%s\n", *p.PlaylistType)
+	}
+	if p.ServerControl != nil {
+		b.WriteString(formatServerControl(p.ServerControl) + "\n")
+	}
+	if p.PartTarget > 0 {
+		b.WriteString("#EXT-X-PART-INF:PART-TARGET=" + formatSeconds(p.PartTarget) + "\n")
+	}
+	for _, r := range p.DateRanges {
+		b.WriteString(formatDateRange(r) + "\n")
+	}
+	if p.Skip != nil {
+		b.WriteString(formatSkip(p.Skip) + "\n")
+	}
+
+	var (
+		keys    []*Key
+		initMap *MediaInitMap
+		bitrate *uint64
+	)
+	for i, s := range p.MediaSegments {
+		// the first segment of a delta update follows the skipped ones
+		if s.IsDiscontinuity && (i > 0 || p.Skip != nil) {
+			b.WriteString("#EXT-X-DISCONTINUITY\n")
+		}
+		if segmentKeys := s.activeKeys(); !sameKeys(keys, segmentKeys) {
+			if len(segmentKeys) == 0 {
+				b.WriteString("#EXT-X-KEY:METHOD=NONE\n")
+			}
+			for _, k := range segmentKeys {
+				b.WriteString(formatKey(k) + "\n")
+			}
+			keys = segmentKeys
+		}
+		if !sameMediaInitMap(initMap, s.MediaInitMap) && s.MediaInitMap != nil {
+			b.WriteString(formatMediaInitMap(s.MediaInitMap) + "\n")
+		}
+		initMap = s.MediaInitMap
+		if s.Bitrate != nil && (bitrate == nil || *bitrate != *s.Bitrate) {
+			fmt.Fprintf(&b, "#EXT-X-BITRATE:%d\n", *s.Bitrate)
+		}
+		bitrate = s.Bitrate
+		if s.ProgramDateTime != nil {
+			b.WriteString("#EXT-X-PROGRAM-DATE-TIME:" + s.ProgramDateTime.Format(programDateTimeLayout) + "\n")
+		}
+		for _, part := range s.Parts {
+			b.WriteString(formatPartialSegment(part) + "\n")
+		}
+		if s.IsGap {
+			b.WriteString("#EXT-X-GAP\n")
+		}
+		if s.ByteRange != nil {
+			fmt.Fprintf(&b, "#EXT-X-BYTERANGE:%d@%d\n", s.ByteRange.Length, s.ByteRange.Offset)
+		}
+		fmt.Fprintf(&b, "#EXTINF:%s,%s\n", formatSeconds(s.Duration), s.Title)
+		b.WriteString(s.URI.String() + "\n")
+	}
+	for _, part := range p.Parts {
+		b.WriteString(formatPartialSegment(part) + "\n")
+	}
+	for _, hint := range p.PreloadHints {
+		b.WriteString(formatPreloadHint(hint) + "\n")
+	}
+	for _, report := range p.RenditionReports {
+		b.WriteString(formatRenditionReport(report) + "\n")
+	}
+	if p.EndList {
+		b.WriteString("#EXT-X-ENDLIST\n")
+	}
+
+	return b.WriteTo(w)
+}
+
+func formatSeconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', -1, 64)
+}
+
+// activeKeys returns the keys in effect for the segment, none if it is not
+// encrypted.
+func (s *MediaSegment) activeKeys() []*Key {
+	keys := s.Keys
+	if len(keys) == 0 && s.Key != nil {
+		keys = []*Key{s.Key}
+	}
+	if len(keys) == 1 && keys[0].Method == KeyMethodNone {
+		return nil
+	}
+	return keys
+}
+
+func sameKeys(a, b []*Key) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !sameKey(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func sameMediaInitMap(a, b *MediaInitMap) bool {
+	if a == b {
+		return true
+	}
+	if a == nil || b == nil || a.URI.String() != b.URI.String() || (a.ByteRange == nil) != (b.ByteRange == nil) {
+		return false
+	}
+	return a.ByteRange == nil || (a.ByteRange.Offset == b.ByteRange.Offset && a.ByteRange.Length == b.ByteRange.Length)
+}
+
+func formatKey(k *Key) string {
+	attrs := &AttributeList{}
+	attrs.Set("METHOD", Enum(string(k.Method)))
+	if k.URI != nil {
+		attrs.Set("URI", String(k.URI.String()))
+	}
+	if k.IV != nil {
+		attrs.Set("IV", Bytes(k.IV))
+	}
+	if k.KeyFormat != nil {
+		attrs.Set("KEYFORMAT", String(*k.KeyFormat))
+	}
+	if len(k.KeyFormatVersions) > 0 && !(len(k.KeyFormatVersions) == 1 && k.KeyFormatVersions[0] == 1) {
+		// 1 is the default
+		versions := make([]string, len(k.KeyFormatVersions))
+		for i, v := range k.KeyFormatVersions {
+			versions[i] = strconv.FormatUint(v, 10)
+		}
+		attrs.Set("KEYFORMATVERSIONS", String(strings.Join(versions, "/")))
+	}
+	if k.KeyID != nil {
+		attrs.Set("KEYID", Bytes(k.KeyID))
+	}
+	return "#EXT-X-KEY:" + attrs.Format()
+}
+
+func formatMediaInitMap(m *MediaInitMap) string {
+	attrs := &AttributeList{}
+	attrs.Set("URI", String(m.URI.String()))
+	if m.ByteRange != nil {
+		attrs.Set("BYTERANGE", String(fmt.Sprintf("%d@%d", m.ByteRange.Length, m.ByteRange.Offset)))
+	}
+	return "#EXT-X-MAP:" + attrs.Format()
+}
+
+func formatServerControl(c *ServerControl) string {
+	attrs := &AttributeList{}
+	if c.CanSkipUntil != nil {
+		attrs.Set("CAN-SKIP-UNTIL", Float(c.CanSkipUntil.Seconds()))
+		if c.CanSkipDateRanges {
+			attrs.Set("CAN-SKIP-DATERANGES", YesNo(true))
+		}
+	}
+	if c.HoldBack != nil {
+		attrs.Set("HOLD-BACK", Float(c.HoldBack.Seconds()))
+	}
+	if c.PartHoldBack != nil {
+		attrs.Set("PART-HOLD-BACK", Float(c.PartHoldBack.Seconds()))
+	}
+	if c.CanBlockReload {
+		attrs.Set("CAN-BLOCK-RELOAD", YesNo(true))
+	}
+	return "#EXT-X-SERVER-CONTROL:" + attrs.Format()
+}
+
+func formatSkip(s *Skip) string {
+	attrs := &AttributeList{}
+	attrs.Set("SKIPPED-SEGMENTS", Int(int64(s.SkippedSegments)))
+	if len(s.RecentlyRemovedDateRanges) > 0 {
+		attrs.Set("RECENTLY-REMOVED-DATERANGES", String(strings.Join(s.RecentlyRemovedDateRanges, "\t")))
+	}
+	return "#EXT-X-SKIP:" + attrs.Format()
+}
+
+func formatPartialSegment(p *PartialSegment) string {
+	attrs := &AttributeList{}
+	attrs.Set("DURATION", Float(p.Duration.Seconds()))
+	attrs.Set("URI", String(p.URI.String()))
+	if p.Independent {
+		attrs.Set("INDEPENDENT", YesNo(true))
+	}
+	if p.ByteRange != nil {
+		attrs.Set("BYTERANGE", String(fmt.Sprintf("%d@%d", p.ByteRange.Length, p.ByteRange.Offset)))
+	}
+	if p.IsGap {
+		attrs.Set("GAP", YesNo(true))
+	}
+	return "#EXT-X-PART:" + attrs.Format()
+}
+
+func formatPreloadHint(h *PreloadHint) string {
+	attrs := &AttributeList{}
+	attrs.Set("TYPE", Enum(string(h.Type)))
+	attrs.Set("URI", String(h.URI.String()))
+	if h.ByteRangeStart > 0 {
+		attrs.Set("BYTERANGE-START", Int(int64(h.ByteRangeStart)))
+	}
+	if h.ByteRangeLength != nil {
+		attrs.Set("BYTERANGE-LENGTH", Int(int64(*h.ByteRangeLength)))
+	}
+	return "#EXT-X-PRELOAD-HINT:" + attrs.Format()
+}
+
+func formatRenditionReport(r *RenditionReport) string {
+	attrs := &AttributeList{}
+	attrs.Set("URI", String(r.URI.String()))
+	if r.LastMSN != nil {
+		attrs.Set("LAST-MSN", Int(int64(*r.LastMSN)))
+	}
+	if r.LastPart != nil {
+		attrs.Set("LAST-PART", Int(int64(*r.LastPart)))
+	}
+	return "#EXT-X-RENDITION-REPORT:" + attrs.Format()
+}
+
+// formatDateRange writes the attributes of the parsed tag, which carries
+// the client attributes, or the modeled ones for a date range built in code.
+func formatDateRange(r *DateRange) string {
+	if r.Tag != nil && r.Tag.AttributeList != nil {
+		return "#EXT-X-DATERANGE:" + r.Tag.AttributeList.Format()
+	}
+	attrs := &AttributeList{}
+	attrs.Set("ID", String(r.ID))
+	if r.Class != nil {
+		attrs.Set("CLASS", String(*r.Class))
+	}
+	attrs.Set("START-DATE", String(r.StartDate.Format(programDateTimeLayout)))
+	if len(r.Cue) > 0 {
+		attrs.Set("CUE", String(strings.Join(r.Cue, ",")))
+	}
+	if r.EndDate != nil {
+		attrs.Set("END-DATE", String(r.EndDate.Format(programDateTimeLayout)))
+	}
+	if r.Duration != nil {
+		attrs.Set("DURATION", Float(r.Duration.Seconds()))
+	}
+	if r.PlannedDuration != nil {
+		attrs.Set("PLANNED-DURATION", Float(r.PlannedDuration.Seconds()))
+	}
+	for _, attr := range r.ClientAttributes {
+		attrs.Set(attr.Name, &attr.Value)
+	}
+	if r.SCTE35Cmd != nil {
+		attrs.Set("SCTE35-CMD", Bytes(r.SCTE35Cmd))
+	}
+	if r.SCTE35Out != nil {
+		attrs.Set("SCTE35-OUT", Bytes(r.SCTE35Out))
+	}
+	if r.SCTE35In != nil {
+		attrs.Set("SCTE35-IN", Bytes(r.SCTE35In))
+	}
+	if r.EndOnNext {
+		attrs.Set("END-ON-NEXT", YesNo(true))
+	}
+	return "#EXT-X-DATERANGE:" + attrs.Format()
+}
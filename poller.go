@@ -0,0 +1,247 @@
+package hls
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// ErrPlaylistReset is passed to Poller.HandleReset when a reloaded playlist
+// goes back on the one before it.
+var ErrPlaylistReset = errors.New("live playlist was reset")
+
+// Poller reloads a live media playlist until it ends, and hands each of its
+// media segments over once, in order.
+//
+// After a reload bringing new segments, the next one starts a target
+// duration after the previous one started; after a reload bringing nothing
+// new, half a target duration after. When the server supports Blocking
+// Playlist Reload, the next reload is instead requested at once with the
+// _HLS_msn directive of the next segment, and the server holds the response
+// until it is available. A blocking reload bringing nothing new, as from a
+// server ignoring the directive, is followed by half a target duration.
+//
+// A playlist whose Media Sequence Number or Discontinuity Sequence Number
+// goes backwards, or whose segments no longer match those already handed
+// over, is a reset: HandleReset is called and the segments of the new
+// playlist are all handed over. A playlist lagging behind the previous one,
+// as served by a stale cache, is treated as unchanged as long as its segments
+// match those already handed over.
+//
+// A reload failing with a network error or a 5xx, 408 or 429 response is
+// tried again at the next reload.
+type Poller struct {
+	Loader         *Loader                                              // [OPTIONAL][DEFAULT=&Loader{}]
+	HandleSegment  func(s *MediaSegment, p *MediaPlaylist) error        // [REQUIRED] called for every new segment, an error stopping the poller
+	HandlePlaylist func(p *MediaPlaylist)                               // [OPTIONAL] called after every reload
+	HandleReset    func(previous, current *MediaPlaylist, reason error) // [OPTIONAL] called when the playlist was reset
+	HandleMissed   func(first, last uint64)                             // [OPTIONAL] called with the Media Sequence Numbers of segments removed before they could be handed over
+	NoBlocking     bool                                                 // [OPTIONAL][DEFAULT=false] disables Blocking Playlist Reload
+	Wait           func(ctx context.Context, d time.Duration) error     // [OPTIONAL] waits between reloads, for tests
+	Now            func() time.Time                                     // [OPTIONAL][DEFAULT=time.Now]
+}
+
+// pollerState is what a Poller remembers of the playlist between reloads.
+type pollerState struct {
+	previous *MediaPlaylist
+	next     uint64 // the Media Sequence Number of the next segment to hand over
+	// the Discontinuity Sequence Number of the last segment handed over, if
+	// any was
+	lastDiscontinuitySequence uint64
+	handedOver                bool
+}
+
+func (p *Poller) now() time.Time {
+	if p.Now != nil {
+		return p.Now()
+	}
+	return time.Now()
+}
+
+func (p *Poller) wait(ctx context.Context, d time.Duration) error {
+	if p.Wait != nil {
+		return p.Wait(ctx, d)
+	}
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Run polls the media playlist at u until it has an EXT-X-ENDLIST tag or is
+// a VOD playlist, a handler fails, the playlist fails to load other than
+// transiently, or ctx is done.
+func (p *Poller) Run(ctx context.Context, u *url.URL) (err error) {
+	loader := p.Loader
+	if loader == nil {
+		loader = &Loader{}
+	}
+	state := &pollerState{}
+	target := u
+	for {
+		start := p.now()
+		var (
+			playlist *MediaPlaylist
+			finalURL *url.URL
+			blocking bool
+		)
+		reloadURL := target
+		if !p.NoBlocking && state.previous != nil && state.previous.ServerControl != nil && state.previous.ServerControl.CanBlockReload {
+			blocking = true
+			reloadURL = blockingReloadURL(target, state.next)
+		}
+		loadCtx, cancel := ctx, context.CancelFunc(func() {})
+		if blocking {
+			// the server should answer within three target durations
+			loadCtx, cancel = context.WithTimeout(ctx, 3*state.previous.TargetDuration)
+		}
+		playlist, finalURL, err = loader.LoadMediaPlaylist(loadCtx, reloadURL)
+		cancel()
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			if blocking && errors.Is(err, context.DeadlineExceeded) {
+				// a blocking reload that timed out is retried
+				continue
+			}
+			if state.previous == nil || !isTransient(err) {
+				return
+			}
+			if err = p.wait(ctx, state.previous.TargetDuration/2-p.now().Sub(start)); err != nil {
+				return
+			}
+			continue
+		}
+		if state.previous == nil {
+			// later reloads go where the playlist was served from
+			target = finalURL
+		}
+		if p.HandlePlaylist != nil {
+			p.HandlePlaylist(playlist)
+		}
+
+		var changed bool
+		if changed, err = p.update(state, playlist); err != nil {
+			return
+		}
+		if playlist.EndList || (playlist.PlaylistType != nil && *playlist.PlaylistType == PlaylistTypeVOD) {
+			return
+		}
+		if playlist.TargetDuration <= 0 {
+			return fmt.Errorf("live playlist %s has no target duration: %w", finalURL, ErrFormat)
+		}
+		if changed && !p.NoBlocking && playlist.ServerControl != nil && playlist.ServerControl.CanBlockReload {
+			if err = ctx.Err(); err != nil {
+				return
+			}
+			continue
+		}
+		interval := playlist.TargetDuration
+		if !changed {
+			interval /= 2
+		}
+		if err = p.wait(ctx, interval-p.now().Sub(start)); err != nil {
+			return
+		}
+	}
+}
+
+// update hands the new segments of a reloaded playlist over, and reports
+// whether there were any.
+func (p *Poller) update(state *pollerState, playlist *MediaPlaylist) (changed bool, err error) {
+	segments := playlist.MediaSegments
+	if previous := state.previous; previous != nil {
+		regressed := playlist.MediaSequence < previous.MediaSequence || playlist.DiscontinuitySequence < previous.DiscontinuitySequence
+		if regressed && state.lagging(playlist) {
+			return
+		}
+		var reason error
+		switch {
+		case playlist.MediaSequence < previous.MediaSequence:
+			reason = fmt.Errorf("media sequence number went from %d back to %d: %w", previous.MediaSequence, playlist.MediaSequence, ErrPlaylistReset)
+		case playlist.DiscontinuitySequence < previous.DiscontinuitySequence:
+			reason = fmt.Errorf("discontinuity sequence number went from %d back to %d: %w", previous.DiscontinuitySequence, playlist.DiscontinuitySequence, ErrPlaylistReset)
+		default:
+			// the last segment handed over must not have moved to another
+			// discontinuity
+			last := state.next - 1
+			if state.handedOver && last >= playlist.MediaSequence && last < playlist.MediaSequence+uint64(len(segments)) {
+				if s := segments[last-playlist.MediaSequence]; s.DiscontinuitySequence != state.lastDiscontinuitySequence {
+					reason = fmt.Errorf("segment %d moved from discontinuity %d to %d: %w", last, state.lastDiscontinuitySequence, s.DiscontinuitySequence, ErrPlaylistReset)
+				}
+			}
+		}
+		if reason != nil {
+			if p.HandleReset != nil {
+				p.HandleReset(previous, playlist, reason)
+			}
+			state.next = playlist.MediaSequence
+		}
+	} else {
+		state.next = playlist.MediaSequence
+	}
+	state.previous = playlist
+
+	if state.next < playlist.MediaSequence {
+		if p.HandleMissed != nil {
+			p.HandleMissed(state.next, playlist.MediaSequence-1)
+		}
+		state.next = playlist.MediaSequence
+	}
+	for _, s := range segments {
+		if s.MediaSequence < state.next {
+			continue
+		}
+		if err = p.HandleSegment(s, playlist); err != nil {
+			return
+		}
+		changed = true
+		state.next = s.MediaSequence + 1
+		state.lastDiscontinuitySequence = s.DiscontinuitySequence
+		state.handedOver = true
+	}
+	return
+}
+
+// lagging reports whether a playlist going back on the previous one is an
+// older version of it: its window overlaps segments already handed over, and
+// they match.
+func (state *pollerState) lagging(playlist *MediaPlaylist) bool {
+	previous := state.previous.MediaSegments
+	if len(previous) == 0 {
+		return false
+	}
+	first := previous[0].MediaSequence
+	overlaps := false
+	for _, s := range playlist.MediaSegments {
+		if s.MediaSequence < first || s.MediaSequence >= state.next || s.MediaSequence-first >= uint64(len(previous)) {
+			continue
+		}
+		handedOver := previous[s.MediaSequence-first]
+		if s.URI.String() != handedOver.URI.String() || s.DiscontinuitySequence != handedOver.DiscontinuitySequence {
+			return false
+		}
+		overlaps = true
+	}
+	return overlaps
+}
+
+// blockingReloadURL adds the _HLS_msn delivery directive to a playlist URL.
+func blockingReloadURL(u *url.URL, mediaSequence uint64) *url.URL {
+	reload := *u
+	query := reload.Query()
+	query.Set("_HLS_msn", strconv.FormatUint(mediaSequence, 10))
+	reload.RawQuery = query.Encode()
+	return &reload
+}
@@ -0,0 +1,203 @@
+package hls
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// liveOrigin serves a live media playlist of a sliding window of segments.
+type liveOrigin struct {
+	mu                    sync.Mutex
+	mediaSequence         uint64
+	discontinuitySequence uint64
+	segments              []string
+	endList               bool
+	canBlockReload        bool
+	ignoreMSN             bool // answers blocking reloads at once
+	status                int  // answered once instead of the playlist
+	requests              []string
+}
+
+func (o *liveOrigin) append(names ...string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.segments = append(o.segments, names...)
+}
+
+func (o *liveOrigin) slide(n int) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.segments = o.segments[n:]
+	o.mediaSequence += uint64(n)
+}
+
+func (o *liveOrigin) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.requests = append(o.requests, r.URL.RawQuery)
+	if status := o.status; status != 0 {
+		o.status = 0
+		w.WriteHeader(status)
+		return
+	}
+	if msn := r.URL.Query().Get("_HLS_msn"); msn != "" && !o.ignoreMSN {
+		// the segment requested becomes available
+		next, _ := strconv.ParseUint(msn, 10, 64)
+		for last := o.mediaSequence + uint64(len(o.segments)); last <= next; last++ {
+			o.segments = append(o.segments, fmt.Sprintf("s%d.ts", last))
+		}
+		if next >= 5 {
+			o.endList = true
+		}
+	}
+	fmt.Fprintf(w, "#EXTM3U\n#EXT-X-TARGETDURATION:4\n#EXT-X-MEDIA-SEQUENCE:%d\n#EXT-X-DISCONTINUITY-SEQUENCE:%d\n", o.mediaSequence, o.discontinuitySequence)
+	if o.canBlockReload {
+		fmt.Fprint(w, "#EXT-X-SERVER-CONTROL:CAN-BLOCK-RELOAD=YES\n")
+	}
+	for _, name := range o.segments {
+		fmt.Fprintf(w, "#EXTINF:4,\n%s\n", name)
+	}
+	if o.endList {
+		fmt.Fprint(w, "#EXT-X-ENDLIST\n")
+	}
+}
+
+func runPoller(t *testing.T, origin *liveOrigin, steps []func()) (handled []string, waits []time.Duration, resets []error, err error) {
+	server := httptest.NewServer(origin)
+	defer server.Close()
+	u, _ := url.Parse(server.URL + "/live.m3u8")
+	poller := &Poller{
+		HandleSegment: func(s *MediaSegment, p *MediaPlaylist) error {
+			handled = append(handled, fmt.Sprintf("%d:%s", s.MediaSequence, s.URI.Path[1:]))
+			return nil
+		},
+		HandleReset: func(previous, current *MediaPlaylist, reason error) {
+			resets = append(resets, reason)
+		},
+		HandleMissed: func(first, last uint64) {
+			handled = append(handled, fmt.Sprintf("missed %d-%d", first, last))
+		},
+		Wait: func(ctx context.Context, d time.Duration) error {
+			waits = append(waits, d.Round(time.Second))
+			if len(steps) == 0 {
+				return errors.New("no more steps")
+			}
+			steps[0]()
+			steps = steps[1:]
+			return nil
+		},
+	}
+	err = poller.Run(context.Background(), u)
+	return
+}
+
+func TestPoller(t *testing.T) {
+	origin := &liveOrigin{segments: []string{"a.ts", "b.ts", "c.ts"}}
+	handled, waits, resets, err := runPoller(t, origin, []func(){
+		func() { origin.append("d.ts"); origin.slide(1) },
+		func() {},
+		func() { origin.slide(1) }, // a window sliding without new segments is unchanged
+		func() { origin.append("e.ts", "f.ts", "g.ts", "h.ts"); origin.slide(4) },
+		func() { origin.endList = true },
+	})
+	assert.NoError(t, err)
+	assert.Empty(t, resets)
+	assert.Equal(t, []string{"0:a.ts", "1:b.ts", "2:c.ts", "3:d.ts", "missed 4-5", "6:g.ts", "7:h.ts"}, handled)
+	assert.Equal(t, []time.Duration{4 * time.Second, 4 * time.Second, 2 * time.Second, 2 * time.Second, 4 * time.Second}, waits)
+}
+
+func TestPollerReset(t *testing.T) {
+	origin := &liveOrigin{mediaSequence: 10, segments: []string{"a.ts", "b.ts"}}
+	handled, _, resets, err := runPoller(t, origin, []func(){
+		func() {
+			origin.mediaSequence = 0
+			origin.segments = []string{"x.ts"}
+		},
+		func() {
+			origin.discontinuitySequence = 5
+			origin.append("y.ts")
+		},
+		func() {
+			origin.discontinuitySequence = 4
+			origin.endList = true
+		},
+	})
+	assert.NoError(t, err)
+	if assert.Len(t, resets, 3) {
+		assert.ErrorIs(t, resets[0], ErrPlaylistReset)
+		// the new discontinuity sequence moved x.ts, which was handed over
+		// already
+		assert.True(t, strings.Contains(resets[1].Error(), "segment 0 moved"), resets[1].Error())
+		assert.True(t, strings.Contains(resets[2].Error(), "discontinuity sequence number"), resets[2].Error())
+	}
+	assert.Equal(t, []string{"10:a.ts", "11:b.ts", "0:x.ts", "0:x.ts", "1:y.ts", "0:x.ts", "1:y.ts"}, handled)
+}
+
+func TestPollerStaleAndFailedReloads(t *testing.T) {
+	origin := &liveOrigin{segments: []string{"a.ts", "b.ts", "c.ts"}}
+	handled, waits, resets, err := runPoller(t, origin, []func(){
+		func() { origin.append("d.ts"); origin.slide(1) },
+		// a stale cache serves the first playlist again
+		func() { origin.mediaSequence, origin.segments = 0, []string{"a.ts", "b.ts", "c.ts"} },
+		func() { origin.mediaSequence, origin.segments = 1, []string{"b.ts", "c.ts", "d.ts", "e.ts"} },
+		func() { origin.status = http.StatusServiceUnavailable },
+		func() { origin.append("f.ts"); origin.endList = true },
+	})
+	assert.NoError(t, err)
+	assert.Empty(t, resets)
+	assert.Equal(t, []string{"0:a.ts", "1:b.ts", "2:c.ts", "3:d.ts", "4:e.ts", "5:f.ts"}, handled)
+	assert.Equal(t, []time.Duration{4 * time.Second, 4 * time.Second, 2 * time.Second, 4 * time.Second, 2 * time.Second}, waits)
+
+	// a playlist going back with segments that no longer match is a reset
+	origin = &liveOrigin{mediaSequence: 1, segments: []string{"a.ts", "b.ts"}}
+	handled, _, resets, err = runPoller(t, origin, []func(){
+		func() { origin.mediaSequence, origin.segments = 0, []string{"x.ts", "y.ts"} },
+		func() { origin.endList = true },
+	})
+	assert.NoError(t, err)
+	assert.Len(t, resets, 1)
+	assert.Equal(t, []string{"1:a.ts", "2:b.ts", "0:x.ts", "1:y.ts"}, handled)
+
+	// a playlist failing to load otherwise stops the poller
+	origin = &liveOrigin{segments: []string{"a.ts"}}
+	_, _, _, err = runPoller(t, origin, []func(){
+		func() { origin.status = http.StatusNotFound },
+	})
+	var statusErr *HTTPStatusError
+	if assert.ErrorAs(t, err, &statusErr) {
+		assert.Equal(t, http.StatusNotFound, statusErr.StatusCode)
+	}
+}
+
+func TestPollerBlockingReload(t *testing.T) {
+	origin := &liveOrigin{segments: []string{"s0.ts", "s1.ts"}, canBlockReload: true}
+	handled, waits, _, err := runPoller(t, origin, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, waits)
+	assert.Equal(t, []string{"0:s0.ts", "1:s1.ts", "2:s2.ts", "3:s3.ts", "4:s4.ts", "5:s5.ts"}, handled)
+	assert.Equal(t, []string{"", "_HLS_msn=2", "_HLS_msn=3", "_HLS_msn=4", "_HLS_msn=5"}, origin.requests)
+}
+
+func TestPollerBlockingReloadIgnored(t *testing.T) {
+	origin := &liveOrigin{segments: []string{"s0.ts", "s1.ts"}, canBlockReload: true, ignoreMSN: true}
+	handled, waits, _, err := runPoller(t, origin, []func(){
+		func() {},
+		func() { origin.append("s2.ts"); origin.endList = true },
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"0:s0.ts", "1:s1.ts", "2:s2.ts"}, handled)
+	// the reloads answered at once bring nothing new and are not repeated at once
+	assert.Equal(t, []time.Duration{2 * time.Second, 2 * time.Second}, waits)
+	assert.Equal(t, []string{"", "_HLS_msn=2", "_HLS_msn=2", "_HLS_msn=2"}, origin.requests)
+}
@@ -0,0 +1,155 @@
+package hls
+
+import (
+	"io"
+	"sync"
+)
+
+// Recorder accumulates the successive reloads of a live media playlist into
+// a single playlist holding every media segment seen, to be written out as a
+// VOD playlist once recording stops. Its Add method can be used as the
+// HandlePlaylist of a Poller.
+//
+// Segments are recorded once, in order, with the keys, media initialization
+// section, program date time and bitrate they had in the live playlist, and
+// the date ranges of every reload are kept, a later version of a range
+// replacing an earlier one. A discontinuity is recorded wherever the
+// Discontinuity Sequence Number of the live segments changes, so it is kept
+// even when its EXT-X-DISCONTINUITY tag had already slid out of the window,
+// and wherever the live playlist was reset or segments were missed between
+// two reloads.
+//
+// The recorded segments are numbered on from the first one, so after a reset
+// a segment whose IV is its Media Sequence Number gets an explicit IV.
+type Recorder struct {
+	mu            sync.Mutex
+	state         pollerState
+	playlist      *MediaPlaylist
+	dateRanges    map[string]int // index in playlist.DateRanges by ID
+	discontinuity bool           // the next segment follows a reset or missed segments
+	// the Discontinuity Sequence Number of the last segment recorded, in the
+	// live playlist
+	lastDiscontinuitySequence uint64
+}
+
+// Add records the new segments and date ranges of a reload of the live
+// playlist.
+func (r *Recorder) Add(p *MediaPlaylist) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.playlist == nil {
+		r.playlist = &MediaPlaylist{
+			Playlist:              &Playlist{Version: 1},
+			MediaSequence:         p.MediaSequence,
+			DiscontinuitySequence: p.DiscontinuitySequence,
+		}
+		r.dateRanges = make(map[string]int)
+	}
+	if p.Playlist != nil && p.Version > r.playlist.Version {
+		r.playlist.Version = p.Version
+	}
+	if p.TargetDuration > r.playlist.TargetDuration {
+		r.playlist.TargetDuration = p.TargetDuration
+	}
+	for _, dateRange := range p.DateRanges {
+		if i, ok := r.dateRanges[dateRange.ID]; ok {
+			r.playlist.DateRanges[i] = dateRange
+			continue
+		}
+		r.dateRanges[dateRange.ID] = len(r.playlist.DateRanges)
+		r.playlist.DateRanges = append(r.playlist.DateRanges, dateRange)
+	}
+	poller := &Poller{
+		HandleSegment: func(s *MediaSegment, p *MediaPlaylist) error {
+			r.record(s)
+			return nil
+		},
+		HandleReset:  func(previous, current *MediaPlaylist, reason error) { r.discontinuity = true },
+		HandleMissed: func(first, last uint64) { r.discontinuity = true },
+	}
+	// recording never fails
+	_, _ = poller.update(&r.state, p)
+}
+
+func (r *Recorder) record(s *MediaSegment) {
+	segment := *s
+	// a VOD playlist lists no Partial Segments
+	segment.Parts = nil
+	segments := r.playlist.MediaSegments
+	if len(segments) == 0 {
+		// the recording starts with the discontinuity of its first segment
+		segment.IsDiscontinuity = false
+		r.playlist.MediaSequence = s.MediaSequence
+		r.playlist.DiscontinuitySequence = s.DiscontinuitySequence
+	} else {
+		last := segments[len(segments)-1]
+		segment.IsDiscontinuity = r.discontinuity || s.IsDiscontinuity || s.DiscontinuitySequence != r.lastDiscontinuitySequence
+		segment.MediaSequence = last.MediaSequence + 1
+		segment.DiscontinuitySequence = last.DiscontinuitySequence
+		if segment.IsDiscontinuity {
+			segment.DiscontinuitySequence += 1
+		}
+	}
+	if segment.MediaSequence != s.MediaSequence {
+		segment.Key, segment.Keys = r.explicitIVKeys(s)
+	}
+	r.discontinuity = false
+	r.lastDiscontinuitySequence = s.DiscontinuitySequence
+	r.playlist.MediaSegments = append(segments, &segment)
+}
+
+// explicitIVKeys returns the keys of a segment, the IV of those using its
+// Media Sequence Number made explicit.
+func (r *Recorder) explicitIVKeys(s *MediaSegment) (key *Key, keys []*Key) {
+	explicit := func(k *Key) *Key {
+		if k.ivMode() != KeyIVModeMediaSequence {
+			return k
+		}
+		clone := *k
+		clone.Tag = nil
+		clone.IV = make([]byte, 16)
+		k.SegmentIV(s.MediaSequence, clone.IV)
+		if r.playlist.Version < 2 {
+			r.playlist.Version = 2
+		}
+		return &clone
+	}
+	for _, k := range s.Keys {
+		keys = append(keys, explicit(k))
+		if k == s.Key {
+			key = keys[len(keys)-1]
+		}
+	}
+	if key == nil && s.Key != nil {
+		key = explicit(s.Key)
+	}
+	return
+}
+
+// Playlist returns the playlist recorded so far, nil before the first Add.
+func (r *Recorder) Playlist() *MediaPlaylist {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.playlist == nil {
+		return nil
+	}
+	playlist := *r.playlist
+	playlist.Playlist = &Playlist{Version: r.playlist.Version}
+	playlist.MediaSegments = append([]*MediaSegment(nil), r.playlist.MediaSegments...)
+	playlist.DateRanges = append([]*DateRange(nil), r.playlist.DateRanges...)
+	return &playlist
+}
+
+// WriteVOD writes the playlist recorded so far as a VOD playlist, with
+// EXT-X-PLAYLIST-TYPE:VOD and EXT-X-ENDLIST tags.
+func (r *Recorder) WriteVOD(w io.Writer) (err error) {
+	playlist := r.Playlist()
+	if playlist == nil {
+		playlist = &MediaPlaylist{Playlist: &Playlist{Version: 1}}
+	}
+	playlistType := PlaylistTypeVOD
+	playlist.PlaylistType = &playlistType
+	playlist.EndList = true
+	_, err = playlist.WriteTo(w)
+	return
+}
@@ -0,0 +1,122 @@
+package hls
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecorder(t *testing.T) {
+	reloads := []string{
+		"#EXT-X-MEDIA-SEQUENCE:10\n" +
+			`#EXT-X-MAP:URI="init1.mp4"` + "\n" +
+			`#EXT-X-KEY:METHOD=AES-128,URI="k1"` + "\n" +
+			"#EXT-X-PROGRAM-DATE-TIME:2024-01-01T00:00:00.000Z\n" +
+			"#EXTINF:4,\na.m4s\n" +
+			"#EXTINF:4,\nb.m4s\n",
+		// the window slides, c.m4s starts a discontinuity
+		"#EXT-X-MEDIA-SEQUENCE:11\n" +
+			`#EXT-X-DATERANGE:ID="ad",START-DATE="2024-01-01T00:00:08.000Z",X-COM-EXAMPLE="1"` + "\n" +
+			`#EXT-X-MAP:URI="init1.mp4"` + "\n" +
+			`#EXT-X-KEY:METHOD=AES-128,URI="k1"` + "\n" +
+			"#EXTINF:4,\nb.m4s\n" +
+			"#EXT-X-DISCONTINUITY\n" +
+			`#EXT-X-MAP:URI="init2.mp4"` + "\n" +
+			"#EXT-X-KEY:METHOD=NONE\n" +
+			"#EXT-X-PROGRAM-DATE-TIME:2024-01-01T00:01:00.000Z\n" +
+			"#EXTINF:4,\nc.m4s\n",
+		// the EXT-X-DISCONTINUITY tag slid out of the window with c.m4s still
+		// new, which a stale cache can serve
+		"#EXT-X-MEDIA-SEQUENCE:12\n" +
+			"#EXT-X-DISCONTINUITY-SEQUENCE:1\n" +
+			`#EXT-X-DATERANGE:ID="ad",START-DATE="2024-01-01T00:00:08.000Z",DURATION=30,X-COM-EXAMPLE="1"` + "\n" +
+			`#EXT-X-MAP:URI="init2.mp4"` + "\n" +
+			"#EXTINF:4,\nc.m4s\n" +
+			"#EXTINF:4,\nd.m4s\n",
+		// the playlist restarts
+		"#EXT-X-MEDIA-SEQUENCE:0\n" +
+			`#EXT-X-MAP:URI="init3.mp4"` + "\n" +
+			`#EXT-X-KEY:METHOD=AES-128,URI="k2"` + "\n" +
+			"#EXTINF:4,\nx.m4s\n",
+	}
+	recorder := &Recorder{}
+	assert.Nil(t, recorder.Playlist())
+	for _, reload := range reloads {
+		recorder.Add(parseTestMediaPlaylist(t, "https://example.com", "#EXT-X-TARGETDURATION:4\n"+reload))
+	}
+	var b strings.Builder
+	assert.NoError(t, recorder.WriteVOD(&b))
+	assert.Equal(t, "#EXTM3U\n"+
+		"#EXT-X-VERSION:2\n"+
+		"#EXT-X-TARGETDURATION:4\n"+
+		"#EXT-X-MEDIA-SEQUENCE:10\n"+
+		"#EXT-X-PLAYLIST-TYPE:VOD\n"+
+		`#EXT-X-DATERANGE:ID="ad",START-DATE="2024-01-01T00:00:08.000Z",DURATION=30,X-COM-EXAMPLE="1"`+"\n"+
+		`#EXT-X-KEY:METHOD=AES-128,URI="https://example.com/k1"`+"\n"+
+		`#EXT-X-MAP:URI="https://example.com/init1.mp4"`+"\n"+
+		"#EXT-X-PROGRAM-DATE-TIME:2024-01-01T00:00:00.000Z\n"+
+		"#EXTINF:4,\nhttps://example.com/a.m4s\n"+
+		"#EXTINF:4,\nhttps://example.com/b.m4s\n"+
+		"#EXT-X-DISCONTINUITY\n"+
+		"#EXT-X-KEY:METHOD=NONE\n"+
+		`#EXT-X-MAP:URI="https://example.com/init2.mp4"`+"\n"+
+		"#EXT-X-PROGRAM-DATE-TIME:2024-01-01T00:01:00.000Z\n"+
+		"#EXTINF:4,\nhttps://example.com/c.m4s\n"+
+		"#EXTINF:4,\nhttps://example.com/d.m4s\n"+
+		"#EXT-X-DISCONTINUITY\n"+
+		`#EXT-X-KEY:METHOD=AES-128,URI="https://example.com/k2",IV=0x00000000000000000000000000000000`+"\n"+
+		`#EXT-X-MAP:URI="https://example.com/init3.mp4"`+"\n"+
+		"#EXTINF:4,\nhttps://example.com/x.m4s\n"+
+		"#EXT-X-ENDLIST\n", b.String())
+
+	playlist := recorder.Playlist()
+	var sequences []uint64
+	for _, s := range playlist.MediaSegments {
+		sequences = append(sequences, s.MediaSequence, s.DiscontinuitySequence)
+	}
+	assert.Equal(t, []uint64{10, 0, 11, 0, 12, 1, 13, 1, 14, 2}, sequences)
+
+	// the recorded playlist parses back to the same segments
+	parsed := parseTestMediaPlaylist(t, "https://example.com", strings.TrimPrefix(b.String(), "#EXTM3U\n"))
+	if assert.Len(t, parsed.MediaSegments, 5) {
+		assert.True(t, parsed.MediaSegments[2].IsDiscontinuity)
+		assert.Equal(t, "https://example.com/init2.mp4", parsed.MediaSegments[3].MediaInitMap.URI.String())
+		assert.Equal(t, KeyIVModeExplicit, parsed.MediaSegments[4].Key.ivMode())
+	}
+}
+
+func TestRecorderMissedSegments(t *testing.T) {
+	recorder := &Recorder{}
+	recorder.Add(parseTestMediaPlaylist(t, "https://example.com", "#EXT-X-TARGETDURATION:4\n"+
+		"#EXT-X-PROGRAM-DATE-TIME:2024-01-01T00:00:00Z\n#EXTINF:4,\na.ts\n#EXTINF:4,\nb.ts\n"))
+	recorder.Add(parseTestMediaPlaylist(t, "https://example.com", "#EXT-X-TARGETDURATION:4\n#EXT-X-MEDIA-SEQUENCE:3\n"+
+		"#EXT-X-PROGRAM-DATE-TIME:2024-01-01T00:00:12Z\n#EXTINF:4,\nd.ts\n#EXTINF:4,\ne.ts\n"))
+	playlist := recorder.Playlist()
+	var segments []string
+	for _, s := range playlist.MediaSegments {
+		date := ""
+		if s.ProgramDateTime != nil {
+			date = s.ProgramDateTime.Format("15:04:05")
+		}
+		segments = append(segments, fmt.Sprintf("%d %d %s %s", s.MediaSequence, s.DiscontinuitySequence, s.URI.Path, date))
+	}
+	// c.ts was missed, d.ts follows a discontinuity
+	assert.Equal(t, []string{"0 0 /a.ts 00:00:00", "1 0 /b.ts ", "2 1 /d.ts 00:00:12", "3 1 /e.ts "}, segments)
+	assert.True(t, playlist.MediaSegments[2].IsDiscontinuity)
+	assert.Equal(t, uint64(1), playlist.Version)
+}
+
+func TestRecorderPartialSegments(t *testing.T) {
+	recorder := &Recorder{}
+	recorder.Add(parseTestMediaPlaylist(t, "https://example.com", "#EXT-X-VERSION:9\n#EXT-X-PART-INF:PART-TARGET=2\n"+
+		"#EXT-X-PART:DURATION=2,URI=\"a.0.m4s\",INDEPENDENT=YES\n#EXT-X-PART:DURATION=2,URI=\"a.1.m4s\"\n#EXTINF:4,\na.m4s\n"+
+		"#EXT-X-PART:DURATION=2,URI=\"b.0.m4s\"\n"+
+		"#EXT-X-PRELOAD-HINT:TYPE=PART,URI=\"b.1.m4s\"\n"))
+	var b strings.Builder
+	assert.NoError(t, recorder.WriteVOD(&b))
+	assert.False(t, strings.Contains(b.String(), "#EXT-X-PART"), b.String())
+	assert.False(t, strings.Contains(b.String(), "#EXT-X-PRELOAD-HINT"), b.String())
+	assert.True(t, strings.Contains(b.String(), "#EXTINF:4,\nhttps://example.com/a.m4s\n"), b.String())
+}
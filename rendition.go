@@ -8,6 +8,7 @@ import (
 
 type Rendition struct {
 	Tag               *Tag
+	TagLine           *Line              // The Line for the EXT-X-MEDIA tag
 	URI               *url.URL           // [OPTIONAL] identifies the Media Playlist file, only nil for CLOSED-CAPTIONS
 	Type              RenditionType      // [REQUIRED] valid strings are AUDIO, VIDEO, SUBTITLES, and CLOSED-CAPTIONS
 	GroupID           string             // [REQUIRED] specifies the group to which the Rendition belongs
@@ -21,6 +22,8 @@ type Rendition struct {
 	InstreamID        *string            // [OPTIONAL] specifies a Rendition within the segments in the Media Playlist
 	Characteristics   []string           // [OPTIONAL] one or more Media Characteristic Tags (MCTs)
 	Channels          *RenditionChannels // [OPTIONAL] specifies an ordered, slash-separated ("/") list of parameters
+	BitDepth          *uint64            // [OPTIONAL] the audio bit depth of the Rendition
+	SampleRate        *uint64            // [OPTIONAL] the audio sample rate of the Rendition, in samples per second
 }
 
 type RenditionType string
@@ -111,7 +114,7 @@ func (r *Rendition) ParseAttributeList(attrs *AttributeList) (err error) {
 		}
 	}
 	if attr := attrs.GetLast("STABLE-RENDITION-ID"); attr != nil {
-		if r.AssocLanguage, err = attr.StringPtr(); err != nil {
+		if r.StableRenditionID, err = attr.StringPtr(); err != nil {
 			err = fmt.Errorf("failed getting STABLE-RENDITION-ID attribute: %w", err)
 			return
 		}
@@ -160,5 +163,17 @@ func (r *Rendition) ParseAttributeList(attrs *AttributeList) (err error) {
 			return
 		}
 	}
+	if attr := attrs.GetLast("BIT-DEPTH"); attr != nil {
+		if r.BitDepth, err = attr.UintPtr(); err != nil {
+			err = fmt.Errorf("failed getting BIT-DEPTH attribute: %w", err)
+			return
+		}
+	}
+	if attr := attrs.GetLast("SAMPLE-RATE"); attr != nil {
+		if r.SampleRate, err = attr.UintPtr(); err != nil {
+			err = fmt.Errorf("failed getting SAMPLE-RATE attribute: %w", err)
+			return
+		}
+	}
 	return
 }
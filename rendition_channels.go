@@ -7,9 +7,18 @@ import (
 
 type RenditionChannels struct {
 	AudioChannelsCount           *uint64
-	AudioObjectCodingIdentifiers []string
+	AudioObjectCodingIdentifiers []string       // such as JOC for Dolby Atmos, nil if absent or "-"
+	AudioChannelUsage            []ChannelUsage // audio channel usage indicators, nil if absent
 }
 
+type ChannelUsage string
+
+const (
+	ChannelUsageBinaural  ChannelUsage = "BINAURAL"  // the audio is binaurally rendered for headphones
+	ChannelUsageImmersive ChannelUsage = "IMMERSIVE" // the audio is rendered for speakers at different heights
+	ChannelUsageDownmix   ChannelUsage = "DOWNMIX"   // the audio is a downmix of content with more channels
+)
+
 func (c *RenditionChannels) ParseString(renditionType RenditionType, value string) (err error) {
 	slashParts := strings.Split(value, "/")
 	if renditionType == Audio {
@@ -20,9 +29,34 @@ func (c *RenditionChannels) ParseString(renditionType RenditionType, value strin
 			}
 			c.AudioChannelsCount = &channelsCount
 		}
-		if len(slashParts) >= 2 {
+		if len(slashParts) >= 2 && slashParts[1] != "-" {
 			c.AudioObjectCodingIdentifiers = strings.Split(slashParts[1], ",")
 		}
+		if len(slashParts) >= 3 && slashParts[2] != "-" {
+			for _, usage := range strings.Split(slashParts[2], ",") {
+				c.AudioChannelUsage = append(c.AudioChannelUsage, ChannelUsage(usage))
+			}
+		}
 	}
 	return
 }
+
+func (c *RenditionChannels) HasUsage(usage ChannelUsage) bool {
+	for _, u := range c.AudioChannelUsage {
+		if u == usage {
+			return true
+		}
+	}
+	return false
+}
+
+// IsSpatial reports whether the Rendition carries spatial audio, either
+// object based (JOC) or flagged as binaural or immersive.
+func (c *RenditionChannels) IsSpatial() bool {
+	for _, id := range c.AudioObjectCodingIdentifiers {
+		if id == "JOC" {
+			return true
+		}
+	}
+	return c.HasUsage(ChannelUsageBinaural) || c.HasUsage(ChannelUsageImmersive)
+}
@@ -0,0 +1,164 @@
+package hls
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// DanglingGroupReference is a rendition group referenced by a Variant Stream,
+// or by an I-frame Stream, that no EXT-X-MEDIA tag defines.
+type DanglingGroupReference struct {
+	VariantStream *VariantStream
+	IframeStream  *IframeStream // set instead of VariantStream for an EXT-X-I-FRAME-STREAM-INF tag
+	Type          RenditionType
+	GroupID       string
+}
+
+func (ref DanglingGroupReference) lineNum() int {
+	var line *Line
+	if ref.VariantStream != nil {
+		line = ref.VariantStream.TagLine
+	} else if ref.IframeStream != nil {
+		line = ref.IframeStream.TagLine
+	}
+	if line == nil {
+		return 0
+	}
+	return line.LineNum
+}
+
+func (ref DanglingGroupReference) String() string {
+	return fmt.Sprintf("line %d: %s group %q is not defined", ref.lineNum(), ref.Type, ref.GroupID)
+}
+
+// InvalidRendition is a rendition violating the constraints that apply to its
+// TYPE.
+type InvalidRendition struct {
+	Rendition *Rendition
+	Reason    string
+}
+
+func (r InvalidRendition) String() string {
+	lineNum := 0
+	if r.Rendition.TagLine != nil {
+		lineNum = r.Rendition.TagLine.LineNum
+	}
+	return fmt.Sprintf("line %d: %s rendition %q in group %q %s", lineNum, r.Rendition.Type, r.Rendition.Name, r.Rendition.GroupID, r.Reason)
+}
+
+// RenditionGroupError lists every problem found by
+// MasterPlaylist.ValidateRenditionGroups. It wraps ErrFormat.
+type RenditionGroupError struct {
+	Dangling []DanglingGroupReference
+	Invalid  []InvalidRendition
+}
+
+func (e *RenditionGroupError) Error() string {
+	var problems []string
+	for _, ref := range e.Dangling {
+		problems = append(problems, ref.String())
+	}
+	for _, r := range e.Invalid {
+		problems = append(problems, r.String())
+	}
+	return fmt.Sprintf("invalid rendition groups: %s", strings.Join(problems, "; "))
+}
+
+func (e *RenditionGroupError) Unwrap() error {
+	return ErrFormat
+}
+
+var instreamIDPattern = regexp.MustCompile(`^(CC[1-4]|SERVICE([1-9]|[1-5][0-9]|6[0-3]))$`)
+
+// GroupID returns the group of the given rendition type the Variant Stream
+// references, or nil. CLOSED-CAPTIONS=NONE references no group.
+func (s *VariantStream) GroupID(renditionType RenditionType) *string {
+	switch renditionType {
+	case Audio:
+		return s.Audio
+	case Video:
+		return s.Video
+	case Subtitles:
+		return s.Subtitles
+	case ClosedCaptions:
+		if s.ClosedCaptionsNone {
+			return nil
+		}
+		return s.ClosedCaptions
+	}
+	return nil
+}
+
+// RenditionGroup returns the renditions of the given type and group.
+func (p *MasterPlaylist) RenditionGroup(renditionType RenditionType, groupID string) []*Rendition {
+	return p.RenditionGroups[renditionType][groupID]
+}
+
+// Renditions resolves the group of the given type referenced by the Variant
+// Stream to its renditions, nil if the stream references no group of that
+// type or the group is not defined.
+func (p *MasterPlaylist) Renditions(s *VariantStream, renditionType RenditionType) []*Rendition {
+	groupID := s.GroupID(renditionType)
+	if groupID == nil {
+		return nil
+	}
+	return p.RenditionGroup(renditionType, *groupID)
+}
+
+// ValidateRenditionGroups checks that every group referenced by a Variant
+// Stream or an I-frame Stream is defined and that CLOSED-CAPTIONS renditions
+// have no URI and a valid INSTREAM-ID. It returns a *RenditionGroupError
+// listing every problem, or nil.
+func (p *MasterPlaylist) ValidateRenditionGroups() error {
+	e := &RenditionGroupError{}
+	for _, s := range p.VariantStreams {
+		for _, renditionType := range []RenditionType{Audio, Video, Subtitles, ClosedCaptions} {
+			groupID := s.GroupID(renditionType)
+			if groupID == nil {
+				continue
+			}
+			if _, ok := p.RenditionGroups[renditionType][*groupID]; !ok {
+				e.Dangling = append(e.Dangling, DanglingGroupReference{VariantStream: s, Type: renditionType, GroupID: *groupID})
+			}
+		}
+	}
+	for _, s := range p.IframeStreams {
+		if s.Video == nil {
+			continue
+		}
+		if _, ok := p.RenditionGroups[Video][*s.Video]; !ok {
+			e.Dangling = append(e.Dangling, DanglingGroupReference{IframeStream: s, Type: Video, GroupID: *s.Video})
+		}
+	}
+	for _, renditionType := range []RenditionType{Audio, Video, Subtitles, ClosedCaptions} {
+		groups := p.RenditionGroups[renditionType]
+		groupIDs := make([]string, 0, len(groups))
+		for groupID := range groups {
+			groupIDs = append(groupIDs, groupID)
+		}
+		// for the problems to be listed in the same order every time
+		sort.Strings(groupIDs)
+		for _, groupID := range groupIDs {
+			for _, r := range groups[groupID] {
+				if r.Type == ClosedCaptions {
+					if r.URI != nil {
+						e.Invalid = append(e.Invalid, InvalidRendition{r, "must not have a URI"})
+					}
+					if r.InstreamID == nil {
+						e.Invalid = append(e.Invalid, InvalidRendition{r, "is missing INSTREAM-ID"})
+					} else if !instreamIDPattern.MatchString(*r.InstreamID) {
+						e.Invalid = append(e.Invalid, InvalidRendition{r, fmt.Sprintf("has invalid INSTREAM-ID %q", *r.InstreamID)})
+					}
+				} else if r.InstreamID != nil {
+					e.Invalid = append(e.Invalid, InvalidRendition{r, "must not have INSTREAM-ID"})
+				}
+			}
+		}
+	}
+	if len(e.Dangling) == 0 && len(e.Invalid) == 0 {
+		return nil
+	}
+	return e
+}
@@ -0,0 +1,166 @@
+package hls
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ReadResource reads a resource, or the given byte range of it. data: URIs
+// are decoded without any request, other URIs are fetched with a GET
+// request. Servers ignoring the Range header are tolerated by cutting the
+// range out of the full response.
+func ReadResource(ctx context.Context, client Doer, header http.Header, u *url.URL, br *ByteRange) (data []byte, err error) {
+	if br == nil {
+		return readResource(ctx, client, header, u, 0, -1)
+	}
+	return readResource(ctx, client, header, u, br.Offset, int64(br.Length))
+}
+
+// readResourceFrom reads a resource from offset to its end, which for a
+// resource still being produced, as hinted by EXT-X-PRELOAD-HINT, is where
+// the server ends the response.
+func readResourceFrom(ctx context.Context, client Doer, header http.Header, u *url.URL, offset uint64) (data []byte, err error) {
+	return readResource(ctx, client, header, u, offset, -1)
+}
+
+// readResource reads length bytes of a resource from offset, or up to its end
+// if length is negative.
+func readResource(ctx context.Context, client Doer, header http.Header, u *url.URL, offset uint64, length int64) (data []byte, err error) {
+	if u.Scheme == "data" {
+		if _, data, err = decodeDataURI(u); err != nil {
+			return
+		}
+		end := uint64(len(data))
+		if length >= 0 {
+			end = offset + uint64(length)
+		}
+		if offset > uint64(len(data)) || end > uint64(len(data)) {
+			return nil, fmt.Errorf("%s exceeds the %d bytes of the data URI: %w", describeRange(offset, length), len(data), ErrFormat)
+		}
+		return data[offset:end], nil
+	}
+	var rangeHeader string
+	if length >= 0 {
+		rangeHeader = fmt.Sprintf("bytes=%d-%d", offset, offset+uint64(length)-1)
+	} else if offset > 0 {
+		rangeHeader = fmt.Sprintf("bytes=%d-", offset)
+	}
+	var resp *http.Response
+	if resp, err = requestResource(ctx, client, header, http.MethodGet, u, rangeHeader); err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case rangeHeader != "" && resp.StatusCode == http.StatusPartialContent:
+	case resp.StatusCode == http.StatusOK:
+		_, err = io.CopyN(io.Discard, resp.Body, int64(offset))
+	default:
+		return nil, &HTTPStatusError{URL: u.String(), StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	if err == nil {
+		if length >= 0 {
+			data = make([]byte, length)
+			_, err = io.ReadFull(resp.Body, data)
+		} else {
+			data, err = io.ReadAll(resp.Body)
+		}
+	}
+	if err != nil {
+		err = fmt.Errorf("GET %s: reading %s: %w", u, describeRange(offset, length), err)
+	}
+	return
+}
+
+// resourceLength returns the length of a resource, as answered to a HEAD
+// request, or -1 if the server does not tell.
+func resourceLength(ctx context.Context, client Doer, header http.Header, u *url.URL) (length int64, err error) {
+	if u.Scheme == "data" {
+		var data []byte
+		_, data, err = decodeDataURI(u)
+		return int64(len(data)), err
+	}
+	var resp *http.Response
+	if resp, err = requestResource(ctx, client, header, http.MethodHead, u, ""); err != nil {
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return -1, &HTTPStatusError{URL: u.String(), StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	return resp.ContentLength, nil
+}
+
+// requestResource sends a request for a resource with the given headers and,
+// unless empty, Range header.
+func requestResource(ctx context.Context, client Doer, header http.Header, method string, u *url.URL, rangeHeader string) (resp *http.Response, err error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	var req *http.Request
+	if req, err = http.NewRequestWithContext(ctx, method, u.String(), nil); err != nil {
+		return
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if rangeHeader != "" {
+		req.Header.Set("Range", rangeHeader)
+	}
+	return client.Do(req)
+}
+
+func describeRange(offset uint64, length int64) string {
+	if length < 0 {
+		return fmt.Sprintf("from %d", offset)
+	}
+	return fmt.Sprintf("range %d@%d", length, offset)
+}
+
+// decodeDataURI decodes the content of a data: URI (RFC 2397).
+func decodeDataURI(u *url.URL) (mediaType string, data []byte, err error) {
+	if u == nil || u.Scheme != "data" {
+		err = fmt.Errorf("not a data URI: %w", ErrFormat)
+		return
+	}
+	raw := u.Opaque
+	if raw == "" {
+		raw = strings.TrimPrefix(u.String(), "data:")
+	} else if u.RawQuery != "" || u.ForceQuery {
+		// url.Parse takes whatever follows a ? for the query
+		raw += "?" + u.RawQuery
+	}
+	comma := strings.IndexByte(raw, ',')
+	if comma < 0 {
+		err = fmt.Errorf("data URI is missing a comma: %w", ErrFormat)
+		return
+	}
+	mediaType, content := raw[:comma], raw[comma+1:]
+	isBase64 := strings.HasSuffix(strings.ToLower(mediaType), ";base64")
+	if isBase64 {
+		mediaType = mediaType[:len(mediaType)-len(";base64")]
+	}
+	if mediaType == "" {
+		mediaType = "text/plain;charset=US-ASCII"
+	}
+	if content, err = url.PathUnescape(content); err != nil {
+		err = fmt.Errorf("failed unescaping data URI: %v: %w", err, ErrFormat)
+		return
+	}
+	if !isBase64 {
+		data = []byte(content)
+		return
+	}
+	// padding is often left out
+	if data, err = base64.StdEncoding.DecodeString(content); err != nil {
+		if data, err = base64.RawStdEncoding.DecodeString(strings.TrimRight(content, "=")); err != nil {
+			err = fmt.Errorf("failed decoding base64 data URI: %v: %w", err, ErrFormat)
+		}
+	}
+	return
+}
@@ -0,0 +1,92 @@
+package hls
+
+import (
+	"context"
+	"encoding/base64"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadResourceDataURI(t *testing.T) {
+	for uri, expected := range map[string]string{
+		"data:,hello%20world":                           "hello world",
+		"data:text/plain;charset=utf-8,a%2Cb,c":         "a,b,c",
+		"data:application/octet-stream;base64,aGVsbG8=": "hello",
+		"data:;base64,aGVsbG8":                          "hello",
+		"data:text/plain;BASE64,aGk%3D":                 "hi",
+		"data:,a?b":                                     "a?b",
+		"data:,what?":                                   "what?",
+	} {
+		u, err := url.Parse(uri)
+		if !assert.NoError(t, err, uri) {
+			continue
+		}
+		data, err := ReadResource(context.Background(), nil, nil, u, nil)
+		assert.NoError(t, err, uri)
+		assert.Equal(t, expected, string(data), uri)
+	}
+
+	u, _ := url.Parse("data:,0123456789")
+	data, err := ReadResource(context.Background(), nil, nil, u, &ByteRange{Length: 4, Offset: 3})
+	assert.NoError(t, err)
+	assert.Equal(t, "3456", string(data))
+	_, err = ReadResource(context.Background(), nil, nil, u, &ByteRange{Length: 4, Offset: 8})
+	assert.ErrorIs(t, err, ErrFormat)
+
+	for _, uri := range []string{"data:text/plain", "data:;base64,!!!"} {
+		u, _ := url.Parse(uri)
+		_, err := ReadResource(context.Background(), nil, nil, u, nil)
+		assert.ErrorIs(t, err, ErrFormat, uri)
+	}
+}
+
+func TestDownloaderDataURIs(t *testing.T) {
+	iv := make([]byte, 16)
+	(&Key{}).SegmentIV(0, iv)
+	encoded := func(data []byte) string {
+		return "data:application/octet-stream;base64," + base64.StdEncoding.EncodeToString(data)
+	}
+	playlist := parseTestMediaPlaylist(t, "https://example.com", ""+
+		`#EXT-X-MAP:URI="data:,init"`+"\n"+
+		`#EXT-X-KEY:METHOD=AES-128,URI="`+encoded(testKey)+`"`+"\n"+
+		"#EXTINF:4,\n"+encoded(encryptAES128([]byte("segment"), testKey, iv))+"\n")
+	sink := &recordingSink{}
+	d := &Downloader{KeyProvider: &HTTPKeyProvider{}}
+	_, err := d.Download(context.Background(), playlist, sink, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"init:init", "segment"}, sink.writes)
+}
+
+func TestLoaderSessionData(t *testing.T) {
+	master := "#EXTM3U\n" +
+		`#EXT-X-SESSION-DATA:DATA-ID="com.example.title",VALUE="Title",LANGUAGE="en"` + "\n" +
+		`#EXT-X-SESSION-DATA:DATA-ID="com.example.meta",URI="data:application/json,%7B%22a%22%3A1%7D"` + "\n" +
+		`#EXT-X-SESSION-DATA:DATA-ID="com.example.raw",URI="data:,not%20json",FORMAT=RAW` + "\n" +
+		`#EXT-X-SESSION-DATA:DATA-ID="com.example.bad",URI="data:,not%20json"` + "\n" +
+		"#EXT-X-STREAM-INF:BANDWIDTH=1000\n" +
+		"data:,%23EXTM3U%0A%23EXT-X-TARGETDURATION:4%0A%23EXT-X-ENDLIST%0A\n"
+	u, _ := url.Parse("data:," + url.PathEscape(master))
+	p, err := (&Loader{}).Load(context.Background(), u)
+	if !assert.NoError(t, err) || !assert.NotNil(t, p.Master) || !assert.Len(t, p.Master.SessionData, 4) {
+		return
+	}
+	var values []string
+	for _, d := range p.Master.SessionData {
+		data, err := (&Loader{}).ReadSessionData(context.Background(), d)
+		if d.DataID == "com.example.bad" {
+			assert.ErrorIs(t, err, ErrFormat)
+			continue
+		}
+		assert.NoError(t, err, d.DataID)
+		values = append(values, string(data))
+	}
+	assert.Equal(t, []string{"Title", `{"a":1}`, "not json"}, values)
+	assert.Equal(t, "en", *p.Master.SessionData[0].Language)
+	assert.Equal(t, SessionDataFormatRaw, p.Master.SessionData[2].Format)
+
+	err = Parse(strings.NewReader("#EXTM3U\n"+`#EXT-X-SESSION-DATA:DATA-ID="x",VALUE="a",URI="b"`+"\n"), testBaseURL, &ParserHandler{})
+	assert.ErrorIs(t, err, ErrFormat)
+}
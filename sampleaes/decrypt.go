@@ -0,0 +1,251 @@
+// Package sampleaes decrypts MPEG-TS segments encrypted with the SAMPLE-AES
+// method of HLS, as specified by Apple's "MPEG-2 Stream Encryption Format
+// for HTTP Live Streaming".
+package sampleaes
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrInvalidTS    = errors.New("invalid MPEG-TS stream")
+	ErrInvalidFrame = errors.New("invalid elementary stream frame")
+	ErrUnsupported  = errors.New("unsupported MPEG-TS stream")
+	ErrInvalidKey   = errors.New("SAMPLE-AES key and IV must be 16 bytes")
+)
+
+const (
+	privateDataIndicatorDescriptor = 0x0F
+	registrationDescriptor         = 0x05
+)
+
+// pesUnit is a PES packet of an encrypted stream and the TS packets
+// carrying it.
+type pesUnit struct {
+	pid     uint16
+	packets []int
+	payload []byte
+}
+
+// Decrypt decrypts a SAMPLE-AES encrypted MPEG-TS segment and returns a
+// clear one. The PMT is rewritten with the clear stream types and without
+// the SAMPLE-AES descriptors. Since decrypted H.264 may need a different
+// number of emulation prevention bytes, encrypted PES packets are
+// repacketized: the TS packets keep their adaptation fields, PCRs included,
+// shorter payloads are padded with stuffing, longer ones continue in packets
+// inserted after the last one, and the continuity counters of the affected
+// PIDs are renumbered.
+func Decrypt(ts []byte, key, iv []byte) (clear []byte, err error) {
+	if len(key) != aes.BlockSize || len(iv) != aes.BlockSize {
+		return nil, ErrInvalidKey
+	}
+	if len(ts)%packetSize != 0 {
+		return nil, fmt.Errorf("segment of %d bytes is not made of %d-byte packets: %w", len(ts), packetSize, ErrInvalidTS)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return
+	}
+
+	packets := make([]packet, len(ts)/packetSize)
+	for i := range packets {
+		if packets[i], err = parsePacket(ts[i*packetSize : (i+1)*packetSize]); err != nil {
+			return nil, fmt.Errorf("packet %d: %w", i, err)
+		}
+	}
+
+	pmtPIDs := map[uint16]bool{}
+	encrypted := map[uint16]byte{}
+	for _, p := range packets {
+		if !p.pusi || (p.pid != patPID && !pmtPIDs[p.pid]) {
+			continue
+		}
+		var s []byte
+		if s, err = section(p.payload()); err != nil {
+			return
+		}
+		switch {
+		case p.pid == patPID && s[0] == patTableID:
+			for _, pid := range parsePAT(s) {
+				pmtPIDs[pid] = true
+			}
+		case s[0] == pmtTableID:
+			var streams []pmtStream
+			if _, streams, err = parsePMT(s); err != nil {
+				return
+			}
+			for _, stream := range streams {
+				if _, ok := clearStreamTypes[stream.streamType]; ok {
+					encrypted[stream.pid] = stream.streamType
+				}
+			}
+		}
+	}
+
+	var units []*pesUnit
+	unitOf := make([]*pesUnit, len(packets))
+	current := map[uint16]*pesUnit{}
+	for i, p := range packets {
+		if _, ok := encrypted[p.pid]; !ok {
+			continue
+		}
+		if p.pusi {
+			current[p.pid] = &pesUnit{pid: p.pid}
+			units = append(units, current[p.pid])
+		}
+		// packets before the first PES start of a PID are left as they are
+		if u := current[p.pid]; u != nil {
+			u.packets = append(u.packets, i)
+			unitOf[i] = u
+		}
+	}
+	for _, u := range units {
+		var pes []byte
+		for _, i := range u.packets {
+			pes = append(pes, packets[i].payload()...)
+		}
+		if u.payload, err = decryptPES(block, iv, encrypted[u.pid], pes); err != nil {
+			return nil, fmt.Errorf("PID %d: %w", u.pid, err)
+		}
+	}
+
+	clear = make([]byte, 0, len(ts))
+	nextCC := map[uint16]byte{}
+	consumed := map[*pesUnit]int{}
+	for i, p := range packets {
+		if _, ok := encrypted[p.pid]; !ok {
+			if pmtPIDs[p.pid] && p.pusi {
+				var rewritten []byte
+				if rewritten, err = rewritePMT(p); err != nil {
+					return
+				}
+				clear = append(clear, rewritten...)
+			} else {
+				clear = append(clear, p.data...)
+			}
+			continue
+		}
+		if _, ok := nextCC[p.pid]; !ok {
+			nextCC[p.pid] = p.cc
+		}
+		cc := func(hasPayload bool) (value byte) {
+			if !hasPayload {
+				return nextCC[p.pid] - 1
+			}
+			value = nextCC[p.pid]
+			nextCC[p.pid] = (value + 1) & 0x0F
+			return
+		}
+		u := unitOf[i]
+		if u == nil {
+			b := append([]byte(nil), p.data...)
+			b[3] = b[3]&0xF0 | cc(p.payloadOffset < packetSize)&0x0F
+			clear = append(clear, b...)
+			continue
+		}
+		offset := consumed[u]
+		take := len(p.payload())
+		if remaining := len(u.payload) - offset; take > remaining {
+			take = remaining
+		}
+		chunk := u.payload[offset : offset+take]
+		clear = append(clear, buildPacket(p.pid, p.pusi, p.adaptationField(), chunk, cc(take > 0))...)
+		offset += take
+		if i == u.packets[len(u.packets)-1] {
+			for offset < len(u.payload) {
+				take = len(u.payload) - offset
+				if take > payloadSize {
+					take = payloadSize
+				}
+				clear = append(clear, buildPacket(p.pid, false, nil, u.payload[offset:offset+take], cc(true))...)
+				offset += take
+			}
+		}
+		consumed[u] = offset
+	}
+	return
+}
+
+// decryptPES decrypts the elementary stream data of a PES packet, updating
+// PES_packet_length if it changed and was set.
+func decryptPES(block cipher.Block, iv []byte, streamType byte, pes []byte) (out []byte, err error) {
+	if len(pes) < 9 || pes[0] != 0 || pes[1] != 0 || pes[2] != 1 {
+		return nil, fmt.Errorf("missing PES start code: %w", ErrInvalidTS)
+	}
+	headerLength := 9 + int(pes[8])
+	if headerLength > len(pes) {
+		return nil, fmt.Errorf("truncated PES header: %w", ErrInvalidTS)
+	}
+	es := pes[headerLength:]
+	switch streamType {
+	case StreamTypeH264Encrypted:
+		es = decryptH264(block, iv, es)
+	case StreamTypeAACEncrypted:
+		err = decryptADTS(block, iv, es)
+	case StreamTypeAC3Encrypted, StreamTypeEAC3Encrypted:
+		err = decryptAC3(block, iv, es)
+	}
+	if err != nil {
+		return
+	}
+	out = append(append(make([]byte, 0, headerLength+len(es)), pes[:headerLength]...), es...)
+	if binary.BigEndian.Uint16(out[4:6]) != 0 {
+		length := len(out) - 6
+		if length > 0xFFFF {
+			length = 0
+		}
+		binary.BigEndian.PutUint16(out[4:6], uint16(length))
+	}
+	return
+}
+
+// rewritePMT returns a copy of a PMT packet with the clear stream types and
+// without the SAMPLE-AES descriptors of the encrypted streams.
+func rewritePMT(p packet) (b []byte, err error) {
+	payload := p.payload()
+	s, err := section(payload)
+	if err != nil || s[0] != pmtTableID {
+		return p.data, err
+	}
+	header, streams, err := parsePMT(s)
+	if err != nil {
+		return
+	}
+	for i, stream := range streams {
+		if clearType, ok := clearStreamTypes[stream.streamType]; ok {
+			streams[i].streamType = clearType
+			streams[i].descriptors = stripSampleAESDescriptors(stream.descriptors)
+		}
+	}
+	b = append([]byte(nil), p.data...)
+	rewritten := b[p.payloadOffset+1+int(payload[0]):]
+	n := copy(rewritten, buildPMT(header, streams))
+	for i := n; i < len(rewritten); i++ {
+		rewritten[i] = 0xFF
+	}
+	return
+}
+
+// stripSampleAESDescriptors removes the private data indicator descriptors,
+// such as "zavc" or "aacd", and the "apad" registration descriptor carrying
+// the audio setup information.
+func stripSampleAESDescriptors(descriptors []byte) (kept []byte) {
+	for i := 0; i+2 <= len(descriptors); {
+		tag, length := descriptors[i], int(descriptors[i+1])
+		end := i + 2 + length
+		if end > len(descriptors) {
+			end = len(descriptors)
+		}
+		d := descriptors[i:end]
+		if tag != privateDataIndicatorDescriptor && !(tag == registrationDescriptor && bytes.HasPrefix(d[2:], []byte("apad"))) {
+			kept = append(kept, d...)
+		}
+		i = end
+	}
+	return
+}
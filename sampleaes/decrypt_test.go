@@ -0,0 +1,274 @@
+package sampleaes
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	testKey = []byte("0123456789abcdef")
+	testIV  = []byte("fedcba9876543210")
+)
+
+const (
+	testPMTPID   = 0x1000
+	testVideoPID = 0x100
+	testAudioPID = 0x101
+	testAC3PID   = 0x102
+)
+
+func psiSection(tableID byte, idExtension uint16, body []byte) []byte {
+	s := []byte{tableID, 0xB0, 0, byte(idExtension >> 8), byte(idExtension), 0xC1, 0, 0}
+	s = append(s, body...)
+	length := len(s) + 4 - 3
+	s[1] |= byte(length >> 8)
+	s[2] = byte(length)
+	crc := make([]byte, 4)
+	binary.BigEndian.PutUint32(crc, crc32MPEG(s))
+	return append(s, crc...)
+}
+
+func psiPacket(pid uint16, s []byte) []byte {
+	payload := append([]byte{0}, s...)
+	for len(payload) < payloadSize {
+		payload = append(payload, 0xFF)
+	}
+	return buildPacket(pid, true, nil, payload, 0)
+}
+
+func testPMT(types [3]byte) []byte {
+	var body []byte
+	body = append(body, 0xE0|byte(testVideoPID>>8), byte(testVideoPID&0xFF), 0xF0, 0)
+	streams := []pmtStream{
+		{types[0], testVideoPID, []byte{0x0F, 4, 'z', 'a', 'v', 'c'}},
+		{types[1], testAudioPID, []byte{0x0F, 4, 'a', 'a', 'c', 'd', 0x05, 8, 'a', 'p', 'a', 'd', 1, 2, 3, 4, 0x0A, 4, 'e', 'n', 'g', 0}},
+		{types[2], testAC3PID, []byte{0x0F, 4, 'a', 'c', '3', 'd'}},
+	}
+	for _, s := range streams {
+		body = append(body, s.streamType, 0xE0|byte(s.pid>>8), byte(s.pid), 0xF0, byte(len(s.descriptors)))
+		body = append(body, s.descriptors...)
+	}
+	return psiSection(pmtTableID, 1, body)
+}
+
+func pesPacket(streamID byte, es []byte, setLength bool) []byte {
+	pes := []byte{0, 0, 1, streamID, 0, 0, 0x80, 0x80, 5, 0x21, 0, 1, 0, 1}
+	if setLength {
+		binary.BigEndian.PutUint16(pes[4:6], uint16(len(pes)-6+len(es)))
+	}
+	return append(pes, es...)
+}
+
+// packetize splits a PES into packets, the first one carrying a PCR.
+func packetize(pid uint16, pes []byte, cc *byte) (out []byte) {
+	pcr := []byte{0x10, 0, 0, 0, 0x7E, 0, 0}
+	first := true
+	for len(pes) > 0 {
+		var af []byte
+		capacity := payloadSize
+		if first && pid == testVideoPID {
+			af = pcr
+			capacity -= 1 + len(af)
+		}
+		take := len(pes)
+		if take > capacity {
+			take = capacity
+		}
+		out = append(out, buildPacket(pid, first, af, pes[:take], *cc)...)
+		*cc = (*cc + 1) & 0x0F
+		pes = pes[take:]
+		first = false
+	}
+	return
+}
+
+func randomRBSP(r *rand.Rand, n int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		if r.Intn(3) == 0 {
+			b[i] = 0
+		} else {
+			b[i] = byte(r.Intn(256))
+		}
+	}
+	b[n-1] = 0x80
+	return b
+}
+
+func encryptNAL(block cipher.Block, raw []byte) []byte {
+	enc := append([]byte(nil), raw...)
+	mode := cipher.NewCBCEncrypter(block, testIV)
+	for pos := unencryptedLeader; pos+aes.BlockSize < len(enc); pos += patternStride {
+		mode.CryptBlocks(enc[pos:pos+aes.BlockSize], enc[pos:pos+aes.BlockSize])
+	}
+	return escapeRBSP(enc)
+}
+
+func encryptFrame(block cipher.Block, frame []byte) []byte {
+	enc := append([]byte(nil), frame...)
+	if len(enc) > audioLeader {
+		n := (len(enc) - audioLeader) / aes.BlockSize * aes.BlockSize
+		cipher.NewCBCEncrypter(block, testIV).CryptBlocks(enc[audioLeader:audioLeader+n], enc[audioLeader:audioLeader+n])
+	}
+	return enc
+}
+
+// testSegment builds the clear and the encrypted elementary streams and
+// segments.
+func testSegment(t *testing.T) (encrypted []byte, clearES map[uint16][]byte) {
+	r := rand.New(rand.NewSource(1))
+	block, _ := aes.NewCipher(testKey)
+	clearES = map[uint16][]byte{}
+	encryptedES := map[uint16][]byte{}
+
+	startCode := []byte{0, 0, 0, 1}
+	// the last NAL unit ends with exactly one block after its last stride,
+	// which is left clear
+	for _, nal := range []struct {
+		header byte
+		size   int
+	}{{0x67, 20}, {0x65, 700}, {0x41, 30}, {0x41, 333}, {0x41, 2000}, {0x41, 207}} {
+		raw := append([]byte{nal.header}, randomRBSP(r, nal.size)...)
+		clearES[testVideoPID] = append(append(clearES[testVideoPID], startCode...), escapeRBSP(raw)...)
+		if nal.size > 100 && nal.header&0x1F != 7 {
+			encryptedES[testVideoPID] = append(append(encryptedES[testVideoPID], startCode...), encryptNAL(block, raw)...)
+		} else {
+			encryptedES[testVideoPID] = append(append(encryptedES[testVideoPID], startCode...), escapeRBSP(raw)...)
+		}
+	}
+	// the escaping of the encrypted and clear streams must differ for the
+	// test to cover repacketization
+	assert.NotEqual(t, len(clearES[testVideoPID]), len(encryptedES[testVideoPID]))
+
+	for _, size := range []int{10, 100, 203} {
+		frameLength := 7 + size
+		header := []byte{0xFF, 0xF1, 0x50, 0x80 | byte(frameLength>>11), byte(frameLength >> 3), byte(frameLength<<5) | 0x1F, 0xFC}
+		payload := randomRBSP(r, size)
+		clearES[testAudioPID] = append(append(clearES[testAudioPID], header...), payload...)
+		encryptedES[testAudioPID] = append(append(encryptedES[testAudioPID], header...), encryptFrame(block, payload)...)
+	}
+
+	for i := 0; i < 3; i++ {
+		// 48 kHz, 32 kbit/s: 128 bytes
+		frame := append([]byte{0x0B, 0x77, 0, 0, 0x00, 8 << 3}, randomRBSP(r, 122)...)
+		clearES[testAC3PID] = append(clearES[testAC3PID], frame...)
+		encryptedES[testAC3PID] = append(encryptedES[testAC3PID], encryptFrame(block, frame)...)
+	}
+
+	pat := psiSection(patTableID, 1, []byte{0, 1, 0xE0 | byte(testPMTPID>>8), byte(testPMTPID & 0xFF)})
+	encrypted = append(encrypted, psiPacket(patPID, pat)...)
+	encrypted = append(encrypted, psiPacket(testPMTPID, testPMT([3]byte{StreamTypeH264Encrypted, StreamTypeAACEncrypted, StreamTypeAC3Encrypted}))...)
+	ccs := map[uint16]*byte{testVideoPID: new(byte), testAudioPID: new(byte), testAC3PID: new(byte)}
+	for i := 0; i < 2; i++ {
+		encrypted = append(encrypted, packetize(testVideoPID, pesPacket(0xE0, encryptedES[testVideoPID], false), ccs[testVideoPID])...)
+		encrypted = append(encrypted, packetize(testAudioPID, pesPacket(0xC0, encryptedES[testAudioPID], true), ccs[testAudioPID])...)
+		encrypted = append(encrypted, packetize(testAC3PID, pesPacket(0xBD, encryptedES[testAC3PID], true), ccs[testAC3PID])...)
+	}
+	return
+}
+
+func TestDecrypt(t *testing.T) {
+	encrypted, clearES := testSegment(t)
+	clear, err := Decrypt(encrypted, testKey, testIV)
+	if !assert.NoError(t, err) {
+		return
+	}
+	if !assert.Zero(t, len(clear)%packetSize) {
+		return
+	}
+
+	pes := map[uint16][][]byte{}
+	lastCC := map[uint16]byte{}
+	var pmt []byte
+	for i := 0; i < len(clear); i += packetSize {
+		p, err := parsePacket(clear[i : i+packetSize])
+		if !assert.NoError(t, err) {
+			return
+		}
+		switch p.pid {
+		case testPMTPID:
+			pmt, err = section(p.payload())
+			assert.NoError(t, err)
+		case testVideoPID, testAudioPID, testAC3PID:
+			if last, ok := lastCC[p.pid]; ok && p.payloadOffset < packetSize {
+				assert.Equal(t, (last+1)&0x0F, p.cc, "continuity counter of PID %d", p.pid)
+			}
+			lastCC[p.pid] = p.cc
+			if p.pusi {
+				pes[p.pid] = append(pes[p.pid], nil)
+				if p.pid == testVideoPID {
+					assert.Equal(t, byte(0x10), p.adaptationField()[0], "PCR flag")
+				}
+			}
+			n := len(pes[p.pid]) - 1
+			pes[p.pid][n] = append(pes[p.pid][n], p.payload()...)
+		}
+	}
+
+	assert.Equal(t, uint32(0), crc32MPEG(pmt))
+	_, streams, err := parsePMT(pmt)
+	assert.NoError(t, err)
+	if assert.Len(t, streams, 3) {
+		assert.Equal(t, StreamTypeH264, streams[0].streamType)
+		assert.Empty(t, streams[0].descriptors)
+		assert.Equal(t, StreamTypeAAC, streams[1].streamType)
+		assert.Equal(t, []byte{0x0A, 4, 'e', 'n', 'g', 0}, streams[1].descriptors)
+		assert.Equal(t, StreamTypeAC3, streams[2].streamType)
+	}
+
+	expected := map[uint16][]byte{
+		testVideoPID: pesPacket(0xE0, clearES[testVideoPID], false),
+		testAudioPID: pesPacket(0xC0, clearES[testAudioPID], true),
+		testAC3PID:   pesPacket(0xBD, clearES[testAC3PID], true),
+	}
+	for pid, units := range pes {
+		if assert.Len(t, units, 2) {
+			for _, unit := range units {
+				assert.True(t, bytes.Equal(expected[pid], unit), "PES of PID %d", pid)
+			}
+		}
+	}
+}
+
+func TestDecryptErrors(t *testing.T) {
+	encrypted, _ := testSegment(t)
+	_, err := Decrypt(encrypted, testKey[:8], testIV)
+	assert.ErrorIs(t, err, ErrInvalidKey)
+	_, err = Decrypt(encrypted[:100], testKey, testIV)
+	assert.ErrorIs(t, err, ErrInvalidTS)
+	corrupted := append([]byte(nil), encrypted...)
+	corrupted[packetSize*2] = 0
+	_, err = Decrypt(corrupted, testKey, testIV)
+	assert.ErrorIs(t, err, ErrInvalidTS)
+}
+
+func TestAC3FrameSize(t *testing.T) {
+	for _, c := range []struct {
+		header []byte
+		size   int
+	}{
+		{[]byte{0x0B, 0x77, 0, 0, 0x00, 8 << 3}, 128},       // 48 kHz, 32 kbit/s
+		{[]byte{0x0B, 0x77, 0, 0, 0x40 | 37, 8 << 3}, 2788}, // 44.1 kHz, 640 kbit/s, padded
+		{[]byte{0x0B, 0x77, 0, 0, 0x80 | 36, 6 << 3}, 3840}, // 32 kHz, 640 kbit/s
+		{[]byte{0x0B, 0x77, 0x02, 0xFF, 0, 16 << 3}, 1536},  // E-AC-3, frmsiz 767
+	} {
+		size, err := ac3FrameSize(c.header)
+		assert.NoError(t, err)
+		assert.Equal(t, c.size, size)
+	}
+	_, err := ac3FrameSize([]byte{0x0B, 0x77, 0, 0, 0xC0, 8 << 3})
+	assert.ErrorIs(t, err, ErrInvalidFrame)
+}
+
+func TestEscapeRBSP(t *testing.T) {
+	raw := []byte{0x65, 0, 0, 0, 0, 0, 1, 0, 0, 3, 0, 0, 4}
+	escaped := escapeRBSP(raw)
+	assert.Equal(t, []byte{0x65, 0, 0, 3, 0, 0, 3, 0, 1, 0, 0, 3, 3, 0, 0, 4}, escaped)
+	assert.Equal(t, raw, unescapeRBSP(escaped))
+}
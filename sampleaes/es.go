@@ -0,0 +1,215 @@
+package sampleaes
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"fmt"
+)
+
+// Stream types of SAMPLE-AES encrypted elementary streams in the PMT, and
+// the types of their clear counterparts.
+const (
+	StreamTypeH264Encrypted byte = 0xDB
+	StreamTypeAACEncrypted  byte = 0xCF
+	StreamTypeAC3Encrypted  byte = 0xC1
+	StreamTypeEAC3Encrypted byte = 0xC2
+
+	StreamTypeH264 byte = 0x1B
+	StreamTypeAAC  byte = 0x0F
+	StreamTypeAC3  byte = 0x81
+	StreamTypeEAC3 byte = 0x87
+)
+
+var clearStreamTypes = map[byte]byte{
+	StreamTypeH264Encrypted: StreamTypeH264,
+	StreamTypeAACEncrypted:  StreamTypeAAC,
+	StreamTypeAC3Encrypted:  StreamTypeAC3,
+	StreamTypeEAC3Encrypted: StreamTypeEAC3,
+}
+
+const (
+	// unencryptedLeader is the number of clear bytes at the start of every
+	// encrypted NAL unit and audio frame.
+	unencryptedLeader = 32
+	audioLeader       = 16
+	// minEncryptedNAL is the NAL unit length below which NAL units are
+	// left clear.
+	minEncryptedNAL = 48
+	// patternStride is one encrypted block followed by nine clear blocks.
+	patternStride = 10 * aes.BlockSize
+)
+
+// decryptH264 decrypts the Annex B byte stream of a PES. Slice NAL units
+// (types 1 and 5) longer than 48 bytes are encrypted after their 32-byte
+// leader, one 16-byte block out of every ten, with the CBC chain restarting
+// from the IV for every NAL unit. Emulation prevention bytes were inserted
+// after encryption, so they are removed before decrypting and inserted again
+// afterwards, which may change the length of the stream.
+func decryptH264(block cipher.Block, iv []byte, es []byte) []byte {
+	out := make([]byte, 0, len(es))
+	last := 0
+	for start := nextStartCode(es, 0); start >= 0; {
+		nalStart := start + 3
+		next := nextStartCode(es, nalStart)
+		nalEnd := next
+		if next < 0 {
+			nalEnd = len(es)
+		}
+		// zeros before a start code belong to it, not to the NAL unit
+		for nalEnd > nalStart && es[nalEnd-1] == 0 {
+			nalEnd--
+		}
+		nal := es[nalStart:nalEnd]
+		out = append(out, es[last:nalStart]...)
+		if nalType := nal[0] & 0x1F; len(nal) > minEncryptedNAL && (nalType == 1 || nalType == 5) {
+			raw := unescapeRBSP(nal)
+			mode := cipher.NewCBCDecrypter(block, iv)
+			// a last block with nothing after it is left clear
+			for pos := unencryptedLeader; pos+aes.BlockSize < len(raw); pos += patternStride {
+				mode.CryptBlocks(raw[pos:pos+aes.BlockSize], raw[pos:pos+aes.BlockSize])
+			}
+			out = append(out, escapeRBSP(raw)...)
+		} else {
+			out = append(out, nal...)
+		}
+		last = nalEnd
+		start = next
+	}
+	return append(out, es[last:]...)
+}
+
+// nextStartCode returns the index of the next 0x000001 start code from i,
+// or -1.
+func nextStartCode(b []byte, i int) int {
+	for ; i+3 <= len(b); i++ {
+		if b[i] == 0 && b[i+1] == 0 && b[i+2] == 1 {
+			return i
+		}
+	}
+	return -1
+}
+
+// unescapeRBSP removes the emulation prevention bytes of a NAL unit.
+func unescapeRBSP(nal []byte) []byte {
+	raw := make([]byte, 0, len(nal))
+	zeros := 0
+	for _, b := range nal {
+		if zeros >= 2 && b == 0x03 {
+			zeros = 0
+			continue
+		}
+		raw = append(raw, b)
+		if b == 0 {
+			zeros++
+		} else {
+			zeros = 0
+		}
+	}
+	return raw
+}
+
+// escapeRBSP inserts emulation prevention bytes into a NAL unit.
+func escapeRBSP(raw []byte) []byte {
+	nal := make([]byte, 0, len(raw)+len(raw)/64)
+	zeros := 0
+	for _, b := range raw {
+		if zeros >= 2 && b <= 0x03 {
+			nal = append(nal, 0x03)
+			zeros = 0
+		}
+		nal = append(nal, b)
+		if b == 0 {
+			zeros++
+		} else {
+			zeros = 0
+		}
+	}
+	return nal
+}
+
+// decryptAudioFrame decrypts the encrypted part of an audio frame in place:
+// after its 16-byte leader, every whole 16-byte block, the CBC chain
+// restarting from the IV.
+func decryptAudioFrame(block cipher.Block, iv []byte, frame []byte) {
+	if len(frame) <= audioLeader {
+		return
+	}
+	encrypted := frame[audioLeader:]
+	n := len(encrypted) / aes.BlockSize * aes.BlockSize
+	if n > 0 {
+		cipher.NewCBCDecrypter(block, iv).CryptBlocks(encrypted[:n], encrypted[:n])
+	}
+}
+
+// decryptADTS decrypts the ADTS frames of a PES in place. The ADTS header is
+// clear and not part of the leader.
+func decryptADTS(block cipher.Block, iv []byte, es []byte) (err error) {
+	for pos := 0; pos < len(es); {
+		if pos+7 > len(es) || es[pos] != 0xFF || es[pos+1]&0xF0 != 0xF0 {
+			return fmt.Errorf("ADTS sync word not found at offset %d: %w", pos, ErrInvalidFrame)
+		}
+		headerLength := 7
+		if es[pos+1]&0x01 == 0 {
+			// followed by a CRC
+			headerLength = 9
+		}
+		frameLength := int(es[pos+3]&0x03)<<11 | int(es[pos+4])<<3 | int(es[pos+5])>>5
+		if frameLength < headerLength || pos+frameLength > len(es) {
+			return fmt.Errorf("ADTS frame of %d bytes at offset %d exceeds the PES: %w", frameLength, pos, ErrInvalidFrame)
+		}
+		decryptAudioFrame(block, iv, es[pos+headerLength:pos+frameLength])
+		pos += frameLength
+	}
+	return
+}
+
+// ac3Bitrates are the AC-3 bit rates in kbit/s by frmsizecod / 2.
+var ac3Bitrates = [...]int{32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384, 448, 512, 576, 640}
+
+// ac3FrameSize returns the size in bytes of the AC-3 or E-AC-3 syncframe
+// starting b.
+func ac3FrameSize(b []byte) (size int, err error) {
+	if len(b) < 6 || b[0] != 0x0B || b[1] != 0x77 {
+		return 0, fmt.Errorf("AC-3 sync word not found: %w", ErrInvalidFrame)
+	}
+	bsid := b[5] >> 3
+	switch {
+	case bsid <= 10:
+		fscod, frmsizecod := b[4]>>6, int(b[4]&0x3F)
+		if fscod == 3 || frmsizecod >= 2*len(ac3Bitrates) {
+			return 0, fmt.Errorf("invalid AC-3 fscod %d or frmsizecod %d: %w", fscod, frmsizecod, ErrInvalidFrame)
+		}
+		bitrate := ac3Bitrates[frmsizecod/2]
+		var words int
+		switch fscod {
+		case 0: // 48 kHz
+			words = bitrate * 2
+		case 1: // 44.1 kHz
+			words = bitrate*96000/44100 + frmsizecod&1
+		case 2: // 32 kHz
+			words = bitrate * 3
+		}
+		return words * 2, nil
+	case bsid <= 16:
+		frmsiz := int(b[2]&0x07)<<8 | int(b[3])
+		return (frmsiz + 1) * 2, nil
+	}
+	return 0, fmt.Errorf("unsupported AC-3 bsid %d: %w", bsid, ErrInvalidFrame)
+}
+
+// decryptAC3 decrypts the AC-3 or E-AC-3 syncframes of a PES in place. The
+// leader starts with the sync word.
+func decryptAC3(block cipher.Block, iv []byte, es []byte) (err error) {
+	for pos := 0; pos < len(es); {
+		var size int
+		if size, err = ac3FrameSize(es[pos:]); err != nil {
+			return fmt.Errorf("offset %d: %w", pos, err)
+		}
+		if pos+size > len(es) {
+			return fmt.Errorf("AC-3 frame of %d bytes at offset %d exceeds the PES: %w", size, pos, ErrInvalidFrame)
+		}
+		decryptAudioFrame(block, iv, es[pos:pos+size])
+		pos += size
+	}
+	return
+}
@@ -0,0 +1,191 @@
+package sampleaes
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	packetSize  = 188
+	syncByte    = 0x47
+	patPID      = 0x0000
+	patTableID  = 0x00
+	pmtTableID  = 0x02
+	payloadSize = packetSize - 4
+)
+
+// packet is a parsed MPEG-TS packet.
+type packet struct {
+	data          []byte // the 188 bytes of the packet
+	pid           uint16
+	pusi          bool // payload_unit_start_indicator
+	cc            byte // continuity_counter
+	payloadOffset int  // offset of the payload in data, packetSize if there is none
+}
+
+func parsePacket(b []byte) (p packet, err error) {
+	if b[0] != syncByte {
+		err = fmt.Errorf("packet does not start with the sync byte: %w", ErrInvalidTS)
+		return
+	}
+	p.data = b
+	p.pid = binary.BigEndian.Uint16(b[1:3]) & 0x1FFF
+	p.pusi = b[1]&0x40 != 0
+	p.cc = b[3] & 0x0F
+	afc := (b[3] >> 4) & 0x3
+	p.payloadOffset = 4
+	if afc&0x2 != 0 {
+		p.payloadOffset += 1 + int(b[4])
+		if p.payloadOffset > packetSize {
+			err = fmt.Errorf("adaptation field of PID %d exceeds the packet: %w", p.pid, ErrInvalidTS)
+			return
+		}
+	}
+	if afc&0x1 == 0 {
+		p.payloadOffset = packetSize
+	}
+	return
+}
+
+func (p packet) payload() []byte {
+	return p.data[p.payloadOffset:]
+}
+
+// adaptationField returns the adaptation field of the packet after its
+// length byte, nil if it has none.
+func (p packet) adaptationField() []byte {
+	if (p.data[3]>>4)&0x2 == 0 {
+		return nil
+	}
+	return p.data[5 : 5+int(p.data[4])]
+}
+
+// buildPacket assembles a packet of the given PID carrying payload, which
+// must not exceed payloadSize minus the adaptation field. The adaptation
+// field, without its length byte, is kept and stuffed with 0xFF to fill the
+// packet. A packet without payload keeps the continuity counter of the
+// previous packet, as the counter only increments with payload.
+func buildPacket(pid uint16, pusi bool, af []byte, payload []byte, cc byte) []byte {
+	b := make([]byte, packetSize)
+	b[0] = syncByte
+	binary.BigEndian.PutUint16(b[1:3], pid)
+	if pusi {
+		b[1] |= 0x40
+	}
+	var afc byte
+	if len(payload) > 0 {
+		afc |= 0x1
+	}
+	afTotal := payloadSize - len(payload)
+	if afTotal > 0 || af != nil {
+		afc |= 0x2
+		b[4] = byte(afTotal - 1)
+		if afTotal > 1 {
+			n := copy(b[5:], af)
+			if n == 0 {
+				// flags byte without any flag set
+				b[5] = 0x00
+				n = 1
+			}
+			for i := 5 + n; i < 4+afTotal; i++ {
+				b[i] = 0xFF
+			}
+		}
+	}
+	b[3] = afc<<4 | cc&0x0F
+	copy(b[4+afTotal:], payload)
+	return b
+}
+
+// crc32MPEG computes the CRC-32/MPEG-2 checksum of PSI sections.
+func crc32MPEG(data []byte) uint32 {
+	crc := uint32(0xFFFFFFFF)
+	for _, b := range data {
+		crc ^= uint32(b) << 24
+		for i := 0; i < 8; i++ {
+			if crc&0x80000000 != 0 {
+				crc = crc<<1 ^ 0x04C11DB7
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+// section returns the PSI section carried by the payload of a packet
+// starting one, verifying its length.
+func section(payload []byte) (s []byte, err error) {
+	if len(payload) < 1 || int(payload[0])+1 > len(payload) {
+		err = fmt.Errorf("invalid pointer field: %w", ErrInvalidTS)
+		return
+	}
+	s = payload[1+int(payload[0]):]
+	if len(s) < 3 {
+		err = fmt.Errorf("truncated section header: %w", ErrInvalidTS)
+		return
+	}
+	length := 3 + int(binary.BigEndian.Uint16(s[1:3])&0x0FFF)
+	if length > len(s) || length < 12 {
+		err = fmt.Errorf("section of %d bytes does not fit in one packet: %w", length, ErrUnsupported)
+		return
+	}
+	s = s[:length]
+	return
+}
+
+// parsePAT returns the PMT PIDs listed by a PAT section.
+func parsePAT(s []byte) (pids []uint16) {
+	for i := 8; i+4 <= len(s)-4; i += 4 {
+		if binary.BigEndian.Uint16(s[i:]) != 0 {
+			pids = append(pids, binary.BigEndian.Uint16(s[i+2:])&0x1FFF)
+		}
+	}
+	return
+}
+
+// pmtStream is an elementary stream listed in a PMT.
+type pmtStream struct {
+	streamType  byte
+	pid         uint16
+	descriptors []byte
+}
+
+func parsePMT(s []byte) (header []byte, streams []pmtStream, err error) {
+	programInfoLength := int(binary.BigEndian.Uint16(s[10:12]) & 0x0FFF)
+	i := 12 + programInfoLength
+	if i > len(s)-4 {
+		err = fmt.Errorf("truncated PMT: %w", ErrInvalidTS)
+		return
+	}
+	header = s[:i]
+	for i+5 <= len(s)-4 {
+		stream := pmtStream{
+			streamType: s[i],
+			pid:        binary.BigEndian.Uint16(s[i+1:]) & 0x1FFF,
+		}
+		infoLength := int(binary.BigEndian.Uint16(s[i+3:]) & 0x0FFF)
+		if i+5+infoLength > len(s)-4 {
+			err = fmt.Errorf("truncated PMT ES info: %w", ErrInvalidTS)
+			return
+		}
+		stream.descriptors = s[i+5 : i+5+infoLength]
+		streams = append(streams, stream)
+		i += 5 + infoLength
+	}
+	return
+}
+
+func buildPMT(header []byte, streams []pmtStream) []byte {
+	s := append([]byte(nil), header...)
+	for _, stream := range streams {
+		s = append(s, stream.streamType, 0xE0|byte(stream.pid>>8), byte(stream.pid), 0xF0|byte(len(stream.descriptors)>>8), byte(len(stream.descriptors)))
+		s = append(s, stream.descriptors...)
+	}
+	length := len(s) + 4 - 3
+	s[1] = s[1]&0xF0 | byte(length>>8)&0x0F
+	s[2] = byte(length)
+	crc := make([]byte, 4)
+	binary.BigEndian.PutUint32(crc, crc32MPEG(s))
+	return append(s, crc...)
+}
@@ -0,0 +1,259 @@
+package hls
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-webdl/hls/codecs"
+)
+
+var ErrNoVariant = errors.New("no variant stream matches the selection criteria")
+
+// accessibilityCharacteristicPrefix prefixes the Media Characteristic Tags of
+// renditions meant for accessibility, such as
+// "public.accessibility.describes-video". Such renditions are only selected
+// when their characteristics are requested.
+const accessibilityCharacteristicPrefix = "public.accessibility."
+
+// SelectionCriteria are the constraints and preferences of
+// MasterPlaylist.Select. The zero value accepts every Variant Stream.
+type SelectionCriteria struct {
+	MaxBandwidth      uint64                  // [OPTIONAL] the maximum BANDWIDTH, 0 for no limit
+	MinResolution     *Resolution             // [OPTIONAL] the minimum RESOLUTION, streams without RESOLUTION are accepted
+	MaxResolution     *Resolution             // [OPTIONAL] the maximum RESOLUTION, streams without RESOLUTION are accepted
+	SupportedCodecs   func(codecs.Codec) bool // [OPTIONAL] reports whether a codec listed in CODECS can be decoded
+	VideoRanges       []string                // [OPTIONAL] the supported VIDEO-RANGE values, such as SDR and PQ
+	HDCPLevel         *string                 // [OPTIONAL] the highest supported HDCP-LEVEL: NONE, TYPE-0 or TYPE-1
+	MaxFrameRate      float64                 // [OPTIONAL] the maximum FRAME-RATE, 0 for no limit
+	AudioLanguages    []string                // [OPTIONAL] preferred audio languages as BCP 47 tags, most preferred first
+	SubtitleLanguages []string                // [OPTIONAL] preferred subtitle and closed caption languages as BCP 47 tags, most preferred first
+	Characteristics   []string                // [OPTIONAL] wanted Media Characteristic Tags, such as "public.accessibility.describes-video"
+}
+
+// Rejection explains why a Variant Stream was not selected.
+type Rejection struct {
+	VariantStream *VariantStream
+	Reasons       []string
+}
+
+func (r Rejection) String() string {
+	return fmt.Sprintf("%s: %s", r.VariantStream.URI, strings.Join(r.Reasons, "; "))
+}
+
+// Selection is the result of MasterPlaylist.Select.
+type Selection struct {
+	VariantStream  *VariantStream
+	Audio          *Rendition // nil if the Variant Stream has no audio group
+	Video          *Rendition // nil if the Variant Stream has no video group
+	Subtitles      *Rendition // nil if no subtitles match SubtitleLanguages
+	ClosedCaptions *Rendition // nil if no closed captions match SubtitleLanguages
+	Rejected       []Rejection
+}
+
+// Select picks the Variant Stream satisfying the criteria with the highest
+// SCORE, or BANDWIDTH when SCORE is absent, and the renditions of its groups
+// that best match the preferred languages and characteristics. Every other
+// Variant Stream is listed in Selection.Rejected. If no Variant Stream is
+// acceptable, the returned error wraps ErrNoVariant and Selection.Rejected is
+// still populated.
+func (p *MasterPlaylist) Select(criteria *SelectionCriteria) (selection *Selection, err error) {
+	if criteria == nil {
+		criteria = &SelectionCriteria{}
+	}
+	selection = &Selection{}
+	var candidates []*VariantStream
+	for _, s := range p.VariantStreams {
+		if reasons := criteria.check(s); len(reasons) > 0 {
+			selection.Rejected = append(selection.Rejected, Rejection{s, reasons})
+			continue
+		}
+		candidates = append(candidates, s)
+	}
+	if len(candidates) == 0 {
+		err = fmt.Errorf("%d variant streams rejected: %w", len(selection.Rejected), ErrNoVariant)
+		return
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return betterVariant(candidates[i], candidates[j])
+	})
+	selected := candidates[0]
+	for _, s := range candidates[1:] {
+		selection.Rejected = append(selection.Rejected, Rejection{s, []string{"ranked below the selected variant stream"}})
+	}
+	selection.VariantStream = selected
+	selection.Audio = criteria.pickRendition(p.Renditions(selected, Audio), criteria.AudioLanguages, true)
+	selection.Video = criteria.pickRendition(p.Renditions(selected, Video), nil, true)
+	selection.Subtitles = criteria.pickRendition(p.Renditions(selected, Subtitles), criteria.SubtitleLanguages, false)
+	selection.ClosedCaptions = criteria.pickRendition(p.Renditions(selected, ClosedCaptions), criteria.SubtitleLanguages, false)
+	return
+}
+
+func (criteria *SelectionCriteria) check(s *VariantStream) (reasons []string) {
+	if criteria.MaxBandwidth > 0 && s.Bandwidth > criteria.MaxBandwidth {
+		reasons = append(reasons, fmt.Sprintf("BANDWIDTH %d exceeds %d", s.Bandwidth, criteria.MaxBandwidth))
+	}
+	if s.Resolution != nil {
+		if r := criteria.MaxResolution; r != nil && (s.Resolution.Width > r.Width || s.Resolution.Height > r.Height) {
+			reasons = append(reasons, fmt.Sprintf("RESOLUTION %s exceeds %s", s.Resolution.Format(), r.Format()))
+		}
+		if r := criteria.MinResolution; r != nil && (s.Resolution.Width < r.Width || s.Resolution.Height < r.Height) {
+			reasons = append(reasons, fmt.Sprintf("RESOLUTION %s is below %s", s.Resolution.Format(), r.Format()))
+		}
+	}
+	if criteria.SupportedCodecs != nil {
+		list, err := s.ParsedCodecs()
+		if err != nil {
+			reasons = append(reasons, err.Error())
+		}
+		for _, c := range list {
+			if !criteria.SupportedCodecs(c) {
+				reasons = append(reasons, fmt.Sprintf("codec %s is not supported", c))
+			}
+		}
+	}
+	if criteria.VideoRanges != nil && s.VideoRange != nil && !containsFold(criteria.VideoRanges, *s.VideoRange) {
+		reasons = append(reasons, fmt.Sprintf("VIDEO-RANGE %s is not supported", *s.VideoRange))
+	}
+	if criteria.HDCPLevel != nil && s.HDCPLevel != nil && hdcpRank(*s.HDCPLevel) > hdcpRank(*criteria.HDCPLevel) {
+		reasons = append(reasons, fmt.Sprintf("HDCP-LEVEL %s is not supported", *s.HDCPLevel))
+	}
+	if criteria.MaxFrameRate > 0 && s.FrameRate != nil && *s.FrameRate > criteria.MaxFrameRate {
+		reasons = append(reasons, fmt.Sprintf("FRAME-RATE %g exceeds %g", *s.FrameRate, criteria.MaxFrameRate))
+	}
+	return
+}
+
+// pickRendition chooses a rendition of a group. A rendition matching the
+// earliest preferred language wins, then one marked DEFAULT, then one marked
+// AUTOSELECT. Without a language match nothing is picked unless fallback is
+// set. Accessibility renditions are avoided unless their characteristics are
+// requested.
+func (criteria *SelectionCriteria) pickRendition(group []*Rendition, languages []string, fallback bool) *Rendition {
+	var eligible []*Rendition
+	for _, r := range group {
+		if criteria.wantsCharacteristics(r) {
+			eligible = append(eligible, r)
+		}
+	}
+	if len(eligible) == 0 {
+		eligible = group
+	}
+	var best *Rendition
+	bestRank := 0
+	for _, r := range eligible {
+		rank := 0
+		if r.Language != nil {
+			rank = languageRank(languages, *r.Language)
+		}
+		if rank > 0 {
+			rank = rank*4 + criteria.characteristicsRank(r)*2
+			if r.Default {
+				rank++
+			}
+		}
+		if rank > bestRank {
+			best, bestRank = r, rank
+		}
+	}
+	if best != nil || !fallback {
+		return best
+	}
+	for _, r := range eligible {
+		if r.Default {
+			return r
+		}
+	}
+	for _, r := range eligible {
+		if r.Autoselect {
+			return r
+		}
+	}
+	if len(eligible) > 0 {
+		return eligible[0]
+	}
+	return nil
+}
+
+// wantsCharacteristics reports whether every accessibility characteristic of
+// the rendition was requested.
+func (criteria *SelectionCriteria) wantsCharacteristics(r *Rendition) bool {
+	for _, c := range r.Characteristics {
+		if strings.HasPrefix(c, accessibilityCharacteristicPrefix) && !containsFold(criteria.Characteristics, c) {
+			return false
+		}
+	}
+	return true
+}
+
+// characteristicsRank is 1 if the rendition has all requested
+// characteristics, 0 otherwise.
+func (criteria *SelectionCriteria) characteristicsRank(r *Rendition) int {
+	for _, c := range criteria.Characteristics {
+		if !containsFold(r.Characteristics, c) {
+			return 0
+		}
+	}
+	return 1
+}
+
+// languageRank scores how well a BCP 47 language tag matches the preferred
+// languages, 0 meaning no match. Earlier preferences score higher, and for
+// each preference an exact match beats a match of the more general tag, such
+// as "en" for "en-US", which beats a match by truncating the preference, as
+// in RFC 4647 lookup.
+func languageRank(preferred []string, tag string) int {
+	tag = strings.ToLower(tag)
+	for i, p := range preferred {
+		p = strings.ToLower(p)
+		base := 3 * (len(preferred) - i)
+		switch {
+		case p == tag:
+			return base
+		case strings.HasPrefix(tag, p+"-"):
+			return base - 1
+		}
+		for j := strings.LastIndex(p, "-"); j > 0; j = strings.LastIndex(p, "-") {
+			p = p[:j]
+			if p == tag || strings.HasPrefix(tag, p+"-") {
+				return base - 2
+			}
+		}
+	}
+	return 0
+}
+
+func betterVariant(a, b *VariantStream) bool {
+	if a.Score != nil && b.Score != nil && *a.Score != *b.Score {
+		return *a.Score > *b.Score
+	}
+	if a.Bandwidth != b.Bandwidth {
+		return a.Bandwidth > b.Bandwidth
+	}
+	if a.Resolution != nil && b.Resolution != nil {
+		return a.Resolution.Width*a.Resolution.Height > b.Resolution.Width*b.Resolution.Height
+	}
+	return false
+}
+
+func hdcpRank(level string) int {
+	switch strings.ToUpper(level) {
+	case "NONE":
+		return 0
+	case "TYPE-0":
+		return 1
+	case "TYPE-1":
+		return 2
+	}
+	return 3
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,92 @@
+package hls
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-webdl/hls/codecs"
+	"github.com/stretchr/testify/assert"
+)
+
+const selectTestPlaylist = "#EXTM3U\n" +
+	`#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aac",NAME="English",LANGUAGE="en",DEFAULT=YES,AUTOSELECT=YES,URI="en.m3u8"` + "\n" +
+	`#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aac",NAME="English (AD)",LANGUAGE="en",CHARACTERISTICS="public.accessibility.describes-video",URI="en-ad.m3u8"` + "\n" +
+	`#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aac",NAME="Português",LANGUAGE="pt-BR",URI="pt.m3u8"` + "\n" +
+	`#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID="subs",NAME="Deutsch",LANGUAGE="de",URI="de.m3u8"` + "\n" +
+	`#EXT-X-STREAM-INF:BANDWIDTH=800000,RESOLUTION=640x360,CODECS="avc1.64001e,mp4a.40.2",AUDIO="aac",SUBTITLES="subs"` + "\n" +
+	"360p.m3u8\n" +
+	`#EXT-X-STREAM-INF:BANDWIDTH=3000000,RESOLUTION=1280x720,CODECS="avc1.64001f,mp4a.40.2",AUDIO="aac",SUBTITLES="subs"` + "\n" +
+	"720p.m3u8\n" +
+	`#EXT-X-STREAM-INF:BANDWIDTH=6000000,RESOLUTION=1920x1080,FRAME-RATE=60.000,CODECS="avc1.640028,mp4a.40.2",AUDIO="aac",SUBTITLES="subs"` + "\n" +
+	"1080p60.m3u8\n" +
+	`#EXT-X-STREAM-INF:BANDWIDTH=5000000,RESOLUTION=1920x1080,CODECS="hvc1.2.4.L120.B0,mp4a.40.2",VIDEO-RANGE=PQ,HDCP-LEVEL=TYPE-1,AUDIO="aac",SUBTITLES="subs"` + "\n" +
+	"1080p-hdr.m3u8\n"
+
+func TestSelect(t *testing.T) {
+	var playlist *MasterPlaylist
+	err := Parse(strings.NewReader(selectTestPlaylist), testBaseURL, &ParserHandler{
+		HandleMasterPlaylist: func(p *MasterPlaylist) { playlist = p },
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	selection, err := playlist.Select(nil)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "1080p60.m3u8", selection.VariantStream.URILine.URL)
+		assert.Equal(t, "English", selection.Audio.Name)
+		assert.Nil(t, selection.Subtitles)
+		assert.Len(t, selection.Rejected, 3)
+	}
+
+	hdcpNone := "NONE"
+	selection, err = playlist.Select(&SelectionCriteria{
+		MaxResolution: &Resolution{1920, 1080},
+		MaxFrameRate:  30,
+		SupportedCodecs: func(c codecs.Codec) bool {
+			return c.FourCC == codecs.FourCCAVC1 || c.FourCC == codecs.FourCCMP4A
+		},
+		VideoRanges:       []string{"SDR"},
+		HDCPLevel:         &hdcpNone,
+		AudioLanguages:    []string{"pt-PT", "en"},
+		SubtitleLanguages: []string{"de-AT"},
+	})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "720p.m3u8", selection.VariantStream.URILine.URL)
+		assert.Equal(t, "Português", selection.Audio.Name)
+		assert.Equal(t, "Deutsch", selection.Subtitles.Name)
+	}
+	reasons := map[string][]string{}
+	for _, r := range selection.Rejected {
+		reasons[r.VariantStream.URILine.URL] = r.Reasons
+	}
+	assert.Equal(t, []string{"FRAME-RATE 60 exceeds 30"}, reasons["1080p60.m3u8"])
+	assert.Equal(t, []string{
+		"codec hvc1.2.4.L120.B0 is not supported",
+		"VIDEO-RANGE PQ is not supported",
+		"HDCP-LEVEL TYPE-1 is not supported",
+	}, reasons["1080p-hdr.m3u8"])
+	assert.Equal(t, []string{"ranked below the selected variant stream"}, reasons["360p.m3u8"])
+
+	selection, err = playlist.Select(&SelectionCriteria{
+		MaxBandwidth:    1000000,
+		AudioLanguages:  []string{"en-US"},
+		Characteristics: []string{"public.accessibility.describes-video"},
+	})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "360p.m3u8", selection.VariantStream.URILine.URL)
+		assert.Equal(t, "English (AD)", selection.Audio.Name)
+	}
+
+	selection, err = playlist.Select(&SelectionCriteria{MaxBandwidth: 100000})
+	assert.ErrorIs(t, err, ErrNoVariant)
+	assert.Len(t, selection.Rejected, 4)
+}
+
+func TestLanguageRank(t *testing.T) {
+	preferred := []string{"zh-Hant-TW", "en"}
+	assert.Greater(t, languageRank(preferred, "zh-Hant-TW"), languageRank(preferred, "zh-Hant"))
+	assert.Greater(t, languageRank(preferred, "zh-Hant"), languageRank(preferred, "en"))
+	assert.Greater(t, languageRank(preferred, "en-GB"), 0)
+	assert.Equal(t, 0, languageRank(preferred, "fr"))
+}
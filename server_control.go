@@ -0,0 +1,70 @@
+package hls
+
+import (
+	"fmt"
+	"time"
+)
+
+type ServerControl struct {
+	Tag               *Tag
+	CanSkipUntil      *time.Duration // [OPTIONAL] the Skip Boundary, indicating that the server can produce Playlist Delta Updates
+	CanSkipDateRanges bool           // [OPTIONAL][DEFAULT=false] the server can skip EXT-X-DATERANGE tags in Playlist Delta Updates
+	HoldBack          *time.Duration // [OPTIONAL] the server-recommended minimum distance from the end of the Playlist at which clients should begin to play
+	PartHoldBack      *time.Duration // [OPTIONAL] the same as HoldBack for Low-Latency playback
+	CanBlockReload    bool           // [OPTIONAL][DEFAULT=false] the server supports Blocking Playlist Reload
+}
+
+type PlaylistType string
+
+const (
+	PlaylistTypeEvent PlaylistType = "EVENT"
+	PlaylistTypeVOD   PlaylistType = "VOD"
+)
+
+func (c *ServerControl) ParseTag(tag *Tag) (err error) {
+	if tag.Name != "EXT-X-SERVER-CONTROL" {
+		err = fmt.Errorf("parsing server control using the wrong tag: %s: %w", tag.Name, ErrFormat)
+		return
+	}
+	c.Tag = tag
+	if _, err = tag.ParseAttributeList(); err != nil {
+		err = fmt.Errorf("failed parsing server control attribute list: %w", err)
+		return
+	}
+	return c.ParseAttributeList(tag.AttributeList)
+}
+
+func (c *ServerControl) ParseAttributeList(attrs *AttributeList) (err error) {
+	for name, target := range map[string]**time.Duration{
+		"CAN-SKIP-UNTIL": &c.CanSkipUntil,
+		"HOLD-BACK":      &c.HoldBack,
+		"PART-HOLD-BACK": &c.PartHoldBack,
+	} {
+		if attr := attrs.GetLast(name); attr != nil {
+			var seconds float64
+			if seconds, err = attr.Number(); err != nil {
+				err = fmt.Errorf("failed getting %s attribute: %w", name, err)
+				return
+			}
+			var duration time.Duration
+			if duration, err = parseSeconds(seconds); err != nil {
+				err = fmt.Errorf("failed parsing %s attribute: %w", name, err)
+				return
+			}
+			*target = &duration
+		}
+	}
+	if attr := attrs.GetLast("CAN-SKIP-DATERANGES"); attr != nil {
+		if c.CanSkipDateRanges, err = attr.YesNo(); err != nil {
+			err = fmt.Errorf("failed getting CAN-SKIP-DATERANGES attribute: %w", err)
+			return
+		}
+	}
+	if attr := attrs.GetLast("CAN-BLOCK-RELOAD"); attr != nil {
+		if c.CanBlockReload, err = attr.YesNo(); err != nil {
+			err = fmt.Errorf("failed getting CAN-BLOCK-RELOAD attribute: %w", err)
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,91 @@
+package hls
+
+import (
+	"fmt"
+	"net/url"
+)
+
+type SessionData struct {
+	Tag      *Tag
+	DataID   string            // [REQUIRED] identifies the data value, by reverse DNS convention
+	Value    *string           // [OPTIONAL] the data value, present when URI is not
+	URI      *url.URL          // [OPTIONAL] the resource holding the data, present when VALUE is not
+	Format   SessionDataFormat // [OPTIONAL][DEFAULT=JSON] the format of the resource of the URI
+	Language *string           // [OPTIONAL] the language of the data value [RFC5646]
+}
+
+type SessionDataFormat string
+
+const (
+	SessionDataFormatJSON SessionDataFormat = "JSON"
+	SessionDataFormatRaw  SessionDataFormat = "RAW"
+)
+
+func (d *SessionData) ParseTag(tag *Tag) (err error) {
+	if tag.Name != "EXT-X-SESSION-DATA" {
+		err = fmt.Errorf("parsing session data using the wrong tag: %s: %w", tag.Name, ErrFormat)
+		return
+	}
+	d.Tag = tag
+	if _, err = tag.ParseAttributeList(); err != nil {
+		err = fmt.Errorf("failed parsing session data attribute list: %w", err)
+		return
+	}
+	return d.ParseAttributeList(tag.AttributeList)
+}
+
+func (d *SessionData) ParseAttributeList(attrs *AttributeList) (err error) {
+	if attr := attrs.GetLast("DATA-ID"); attr == nil {
+		err = fmt.Errorf("%s tag is missing DATA-ID attribute: %w", d.Tag.Name, ErrFormat)
+		return
+	} else {
+		if d.DataID, err = attr.String(); err != nil {
+			err = fmt.Errorf("failed getting DATA-ID attribute: %w", err)
+			return
+		}
+	}
+	if attr := attrs.GetLast("VALUE"); attr != nil {
+		if d.Value, err = attr.StringPtr(); err != nil {
+			err = fmt.Errorf("failed getting VALUE attribute: %w", err)
+			return
+		}
+	}
+	if attr := attrs.GetLast("URI"); attr != nil {
+		var value string
+		if value, err = attr.String(); err != nil {
+			err = fmt.Errorf("failed getting URI attribute: %w", err)
+			return
+		}
+		if d.URI, err = url.Parse(value); err != nil {
+			err = fmt.Errorf("failed parsing URI attribute value as URL: %w", err)
+			return
+		}
+	}
+	if (d.Value == nil) == (d.URI == nil) {
+		err = fmt.Errorf("%s tag must have exactly one of the VALUE and URI attributes: %w", d.Tag.Name, ErrFormat)
+		return
+	}
+	d.Format = SessionDataFormatJSON
+	if attr := attrs.GetLast("FORMAT"); attr != nil {
+		var value string
+		if value, err = attr.Enum(); err != nil {
+			err = fmt.Errorf("failed getting FORMAT attribute: %w", err)
+			return
+		}
+		format := SessionDataFormat(value)
+		switch format {
+		case SessionDataFormatJSON, SessionDataFormatRaw:
+			d.Format = format
+		default:
+			err = fmt.Errorf("%s tag has invalid FORMAT enum value: %s: %w", d.Tag.Name, value, ErrFormat)
+			return
+		}
+	}
+	if attr := attrs.GetLast("LANGUAGE"); attr != nil {
+		if d.Language, err = attr.StringPtr(); err != nil {
+			err = fmt.Errorf("failed getting LANGUAGE attribute: %w", err)
+			return
+		}
+	}
+	return
+}
@@ -1,6 +1,7 @@
 package hls
 
 import (
+	"bytes"
 	"encoding/hex"
 	"errors"
 	"fmt"
@@ -275,3 +276,53 @@ func (v *Value) Format() string {
 	}
 	return "UNKNOWN_VALUE"
 }
+
+// clone returns a deep copy of the value.
+func (v Value) clone() Value {
+	if v.StringValue != nil {
+		value := *v.StringValue
+		v.StringValue = &value
+	}
+	if v.EnumValue != nil {
+		value := *v.EnumValue
+		v.EnumValue = &value
+	}
+	if v.IntegerValue != nil {
+		value := *v.IntegerValue
+		v.IntegerValue = &value
+	}
+	if v.FloatValue != nil {
+		value := *v.FloatValue
+		v.FloatValue = &value
+	}
+	if v.BytesValue != nil {
+		v.BytesValue = append([]byte{}, v.BytesValue...)
+	}
+	if v.ResolutionValue != nil {
+		value := *v.ResolutionValue
+		v.ResolutionValue = &value
+	}
+	return v
+}
+
+// Equal reports whether both values have the same type and content.
+func (v *Value) Equal(other *Value) bool {
+	if v.Type != other.Type {
+		return false
+	}
+	switch v.Type {
+	case StringType:
+		return v.StringValue != nil && other.StringValue != nil && *v.StringValue == *other.StringValue
+	case EnumType:
+		return v.EnumValue != nil && other.EnumValue != nil && *v.EnumValue == *other.EnumValue
+	case IntegerType:
+		return v.IntegerValue != nil && other.IntegerValue != nil && *v.IntegerValue == *other.IntegerValue
+	case FloatType:
+		return v.FloatValue != nil && other.FloatValue != nil && *v.FloatValue == *other.FloatValue
+	case BytesType:
+		return bytes.Equal(v.BytesValue, other.BytesValue)
+	case ResolutionType:
+		return v.ResolutionValue != nil && other.ResolutionValue != nil && *v.ResolutionValue == *other.ResolutionValue
+	}
+	return false
+}
@@ -0,0 +1,90 @@
+package hls
+
+import (
+	"strings"
+)
+
+// VideoChannelLayout is a Video Channel Specifier of the REQ-VIDEO-LAYOUT
+// attribute.
+type VideoChannelLayout string
+
+const (
+	VideoChannelStereo VideoChannelLayout = "CH-STEREO" // stereoscopic video, such as MV-HEVC
+	VideoChannelMono   VideoChannelLayout = "CH-MONO"   // monoscopic video
+)
+
+// VideoProjection is a Video Projection Specifier of the REQ-VIDEO-LAYOUT
+// attribute.
+type VideoProjection string
+
+const (
+	VideoProjectionRectilinear         VideoProjection = "PROJ-RECT" // rectilinear, the default
+	VideoProjectionEquirectangular     VideoProjection = "PROJ-EQUI" // 360 degree equirectangular
+	VideoProjectionHalfEquirectangular VideoProjection = "PROJ-HEQU" // 180 degree half equirectangular
+	VideoProjectionPrimary             VideoProjection = "PROJ-PRIM" // primary, format specific projection
+	VideoProjectionAppleImmersive      VideoProjection = "PROJ-AIV"  // Apple Immersive Video
+)
+
+// VideoLayout is the parsed value of the REQ-VIDEO-LAYOUT attribute. The
+// specifiers are separated by "/" and each lists its values, most preferred
+// first, separated by ",".
+type VideoLayout struct {
+	Channels    []VideoChannelLayout
+	Projections []VideoProjection
+	Other       []string // specifier values not defined by the specification
+}
+
+func (l *VideoLayout) ParseString(value string) (err error) {
+	for _, specifier := range strings.Split(value, "/") {
+		for _, v := range strings.Split(specifier, ",") {
+			v = strings.TrimSpace(v)
+			switch {
+			case v == "":
+			case strings.HasPrefix(v, "CH-"):
+				l.Channels = append(l.Channels, VideoChannelLayout(v))
+			case strings.HasPrefix(v, "PROJ-"):
+				l.Projections = append(l.Projections, VideoProjection(v))
+			default:
+				l.Other = append(l.Other, v)
+			}
+		}
+	}
+	return
+}
+
+func (l VideoLayout) String() string {
+	var specifiers []string
+	if len(l.Channels) > 0 {
+		values := make([]string, len(l.Channels))
+		for i, v := range l.Channels {
+			values[i] = string(v)
+		}
+		specifiers = append(specifiers, strings.Join(values, ","))
+	}
+	if len(l.Projections) > 0 {
+		values := make([]string, len(l.Projections))
+		for i, v := range l.Projections {
+			values[i] = string(v)
+		}
+		specifiers = append(specifiers, strings.Join(values, ","))
+	}
+	if len(l.Other) > 0 {
+		specifiers = append(specifiers, strings.Join(l.Other, ","))
+	}
+	return strings.Join(specifiers, "/")
+}
+
+// Stereo reports whether the stream requires stereoscopic display, that is
+// whether its most preferred channel layout is CH-STEREO.
+func (l VideoLayout) Stereo() bool {
+	return len(l.Channels) > 0 && l.Channels[0] == VideoChannelStereo
+}
+
+// Projection returns the most preferred projection, defaulting to
+// PROJ-RECT.
+func (l VideoLayout) Projection() VideoProjection {
+	if len(l.Projections) > 0 {
+		return l.Projections[0]
+	}
+	return VideoProjectionRectilinear
+}
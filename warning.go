@@ -0,0 +1,80 @@
+package hls
+
+import "fmt"
+
+// WarningCode identifies a kind of spec deviation that Parse tolerates.
+type WarningCode string
+
+const (
+	WarnMissingHeader                WarningCode = "MISSING-HEADER"                  // the playlist does not start with #EXTM3U (lenient mode only)
+	WarnByteOrderMark                WarningCode = "BYTE-ORDER-MARK"                 // the playlist starts with a UTF-8 byte order mark (lenient mode only)
+	WarnControlCharacter             WarningCode = "CONTROL-CHARACTER"               // a line contains control characters or invalid UTF-8 (lenient mode only)
+	WarnUnknownTag                   WarningCode = "UNKNOWN-TAG"                     // the tag is not defined by the specification
+	WarnDeprecatedTag                WarningCode = "DEPRECATED-TAG"                  // the tag was removed from the specification, such as EXT-X-ALLOW-CACHE
+	WarnIgnoredTag                   WarningCode = "IGNORED-TAG"                     // the tag does not apply to the type of the playlist and was ignored
+	WarnDuplicateAttribute           WarningCode = "DUPLICATE-ATTRIBUTE"             // an attribute name appears more than once, the last one is used
+	WarnMissingTargetDuration        WarningCode = "MISSING-TARGET-DURATION"         // a media playlist has no EXT-X-TARGETDURATION tag
+	WarnSegmentExceedsTargetDuration WarningCode = "SEGMENT-EXCEEDS-TARGET-DURATION" // the rounded EXTINF duration is larger than the target duration
+	WarnDanglingRenditionGroup       WarningCode = "DANGLING-RENDITION-GROUP"        // a Variant Stream references an undefined rendition group (lenient mode only)
+	WarnInvalidRendition             WarningCode = "INVALID-RENDITION"               // a rendition violates the constraints of its TYPE (lenient mode only)
+)
+
+type Warning struct {
+	Code    WarningCode
+	LineNum int
+	Message string
+}
+
+func (w Warning) String() string {
+	return fmt.Sprintf("line %d: %s: %s", w.LineNum, w.Code, w.Message)
+}
+
+// knownTags are the tags defined by the specification, mapped to the
+// playlist type they apply to.
+var knownTags = map[string]tagScope{
+	"EXTM3U":                       anyPlaylistTag,
+	"EXT-X-VERSION":                anyPlaylistTag,
+	"EXT-X-INDEPENDENT-SEGMENTS":   anyPlaylistTag,
+	"EXT-X-START":                  anyPlaylistTag,
+	"EXT-X-DEFINE":                 anyPlaylistTag,
+	"EXT-X-TARGETDURATION":         mediaPlaylistTag,
+	"EXT-X-MEDIA-SEQUENCE":         mediaPlaylistTag,
+	"EXT-X-DISCONTINUITY-SEQUENCE": mediaPlaylistTag,
+	"EXT-X-ENDLIST":                mediaPlaylistTag,
+	"EXT-X-PLAYLIST-TYPE":          mediaPlaylistTag,
+	"EXT-X-I-FRAMES-ONLY":          mediaPlaylistTag,
+	"EXT-X-PART-INF":               mediaPlaylistTag,
+	"EXT-X-SERVER-CONTROL":         mediaPlaylistTag,
+	"EXTINF":                       mediaPlaylistTag,
+	"EXT-X-BYTERANGE":              mediaPlaylistTag,
+	"EXT-X-DISCONTINUITY":          mediaPlaylistTag,
+	"EXT-X-KEY":                    mediaPlaylistTag,
+	"EXT-X-MAP":                    mediaPlaylistTag,
+	"EXT-X-PROGRAM-DATE-TIME":      mediaPlaylistTag,
+	"EXT-X-GAP":                    mediaPlaylistTag,
+	"EXT-X-BITRATE":                mediaPlaylistTag,
+	"EXT-X-PART":                   mediaPlaylistTag,
+	"EXT-X-DATERANGE":              mediaPlaylistTag,
+	"EXT-X-SKIP":                   mediaPlaylistTag,
+	"EXT-X-PRELOAD-HINT":           mediaPlaylistTag,
+	"EXT-X-RENDITION-REPORT":       mediaPlaylistTag,
+	"EXT-X-ALLOW-CACHE":            mediaPlaylistTag,
+	"EXT-X-MEDIA":                  masterPlaylistTag,
+	"EXT-X-STREAM-INF":             masterPlaylistTag,
+	"EXT-X-I-FRAME-STREAM-INF":     masterPlaylistTag,
+	"EXT-X-SESSION-DATA":           masterPlaylistTag,
+	"EXT-X-SESSION-KEY":            masterPlaylistTag,
+	"EXT-X-CONTENT-STEERING":       masterPlaylistTag,
+}
+
+var deprecatedTags = map[string]bool{
+	"EXT-X-ALLOW-CACHE": true,
+}
+
+type tagScope int
+
+const (
+	anyPlaylistTag tagScope = iota
+	mediaPlaylistTag
+	masterPlaylistTag
+)